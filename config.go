@@ -1,10 +1,17 @@
 package TM_EC
 
 import (
+	"time"
+
 	//  The fantastic ORM library for Golang, aims to be developer friendly.
 	"github.com/jinzhu/gorm"
 )
 
+//	'Config' ec config
+//	'Locales' is supported locales, Accept-Language header of requests is negotiated with them, any locale is acceptable if it is blank
+//	'TimeLocation' is the default time zone of users, it is time.Local if blank
 type Config struct {
-	DB *gorm.DB
+	DB           *gorm.DB
+	Locales      []string
+	TimeLocation *time.Location
 }
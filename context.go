@@ -2,6 +2,7 @@ package TM_EC
 
 import (
 	"net/http"
+	"time"
 
 	//  The fantastic ORM library for Golang, aims to be developer friendly.
 	"github.com/jinzhu/gorm"
@@ -13,14 +14,17 @@ type CurrentUser interface {
 }
 
 //	'Context' is ec context, which is used for many ec components, used to share infoation between them
+//	'Locale', 'TimeLocation' are resolved by 'utils.GetLocale', 'utils.GetTimeLocation' if they are blank, set them to overwrite the resolved ones
 type Context struct {
-	Request     *http.Request
-	Writer      http.ResponseWriter
-	ResourceID  string
-	Config      *Config
-	Roles       []string
-	DB          *gorm.DB
-	CurrentUser CurrentUser
+	Request      *http.Request
+	Writer       http.ResponseWriter
+	ResourceID   string
+	Config       *Config
+	Roles        []string
+	DB           *gorm.DB
+	CurrentUser  CurrentUser
+	Locale       string
+	TimeLocation *time.Location
 	Errors
 }
 
@@ -0,0 +1,246 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+//	'ErrInvalidDecimal' returned when parsing an invalid decimal string
+var ErrInvalidDecimal = errors.New("decimal: invalid decimal")
+
+//	'MaxScale' max absolute value of exponents and scales accepted by 'NewFromString', decimals like "1e300000000" are rejected, so they won't exhaust CPU and memory when rescaled
+var MaxScale = 1000
+
+//	'Decimal' a fixed point decimal number, it is saved as string to database, so there is no float rounding
+//	The zero value is 0
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+//	'New' create decimal from unscaled value and scale, e.g. New(1250, 2) is 12.50
+func New(unscaled int64, scale int) Decimal {
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+//	'NewFromString' parse decimal from string like "-1234.50", "1.5e3", it doesn't accept group separators, refer 'Parse' for localized strings
+func NewFromString(str string) (Decimal, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return Decimal{}, ErrInvalidDecimal
+	}
+
+	exponent := 0
+	if idx := strings.IndexAny(str, "eE"); idx >= 0 {
+		exp, err := strconv.Atoi(str[idx+1:])
+		if err != nil || exp > MaxScale || exp < -MaxScale {
+			return Decimal{}, ErrInvalidDecimal
+		}
+		exponent, str = exp, str[:idx]
+	}
+
+	var scale int
+	if idx := strings.Index(str, "."); idx >= 0 {
+		scale = len(str) - idx - 1
+		str = str[:idx] + str[idx+1:]
+	}
+
+	digits := strings.TrimLeft(str, "+-")
+	if digits == "" || len(str)-len(digits) > 1 || strings.Trim(digits, "0123456789") != "" {
+		return Decimal{}, ErrInvalidDecimal
+	}
+
+	unscaled, ok := new(big.Int).SetString(str, 10)
+	if !ok {
+		return Decimal{}, ErrInvalidDecimal
+	}
+
+	d := Decimal{unscaled: unscaled, scale: scale - exponent}
+	if d.scale > MaxScale || d.scale < -MaxScale {
+		return Decimal{}, ErrInvalidDecimal
+	}
+
+	if d.scale < 0 {
+		d = d.Rescale(0)
+	}
+	return d, nil
+}
+
+//	'MustFromString' like 'NewFromString', but panic if the string is invalid
+func MustFromString(str string) Decimal {
+	d, err := NewFromString(str)
+	if err != nil {
+		panic(fmt.Sprintf("decimal: invalid decimal %q", str))
+	}
+	return d
+}
+
+//	'NewFromFloat' create decimal from float with shortest representation, e.g. 0.1 is "0.1" not "0.1000000000000000055511151231257827"
+func NewFromFloat(f float64) Decimal {
+	d, _ := NewFromString(strconv.FormatFloat(f, 'f', -1, 64))
+	return d
+}
+
+func (d Decimal) value() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+//	'Scale' number of digits after decimal point
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+//	'Precision' number of significant digits, including digits after decimal point
+func (d Decimal) Precision() int {
+	digits := len(new(big.Int).Abs(d.value()).String())
+	if digits < d.scale {
+		return d.scale
+	}
+	return digits
+}
+
+//	'Rescale' change scale of the decimal, it is rounded half away from zero if scale is decreased
+func (d Decimal) Rescale(scale int) Decimal {
+	value := d.value()
+	if scale >= d.scale {
+		multiplier := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale-d.scale)), nil)
+		return Decimal{unscaled: new(big.Int).Mul(value, multiplier), scale: scale}
+	}
+
+	var (
+		divisor      = new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.scale-scale)), nil)
+		quo, rem     = new(big.Int).QuoRem(value, divisor, new(big.Int))
+		doubledRem   = new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2))
+		roundingUnit = big.NewInt(int64(value.Sign()))
+	)
+
+	if doubledRem.Cmp(divisor) >= 0 {
+		quo.Add(quo, roundingUnit)
+	}
+	return Decimal{unscaled: quo, scale: scale}
+}
+
+//	'Round' round the decimal to places digits after decimal point, it is an alias of 'Rescale'
+func (d Decimal) Round(places int) Decimal {
+	return d.Rescale(places)
+}
+
+func align(d1, d2 Decimal) (*big.Int, *big.Int, int) {
+	if d1.scale > d2.scale {
+		return d1.value(), d2.Rescale(d1.scale).value(), d1.scale
+	}
+	return d1.Rescale(d2.scale).value(), d2.value(), d2.scale
+}
+
+//	'Add' return d + d2
+func (d Decimal) Add(d2 Decimal) Decimal {
+	v1, v2, scale := align(d, d2)
+	return Decimal{unscaled: new(big.Int).Add(v1, v2), scale: scale}
+}
+
+//	'Sub' return d - d2
+func (d Decimal) Sub(d2 Decimal) Decimal {
+	v1, v2, scale := align(d, d2)
+	return Decimal{unscaled: new(big.Int).Sub(v1, v2), scale: scale}
+}
+
+//	'Mul' return d * d2
+func (d Decimal) Mul(d2 Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.value(), d2.value()), scale: d.scale + d2.scale}
+}
+
+//	'Cmp' compare d and d2, returns -1 if d < d2, 0 if d == d2, +1 if d > d2
+func (d Decimal) Cmp(d2 Decimal) int {
+	v1, v2, _ := align(d, d2)
+	return v1.Cmp(v2)
+}
+
+//	'Equal' check d equals d2, scale is ignored, e.g. 1.50 equals 1.5
+func (d Decimal) Equal(d2 Decimal) bool {
+	return d.Cmp(d2) == 0
+}
+
+//	'Sign' returns -1 if d < 0, 0 if d == 0, +1 if d > 0
+func (d Decimal) Sign() int {
+	return d.value().Sign()
+}
+
+//	'IsZero' check the decimal is zero
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+//	'Float64' convert to float, precision may be lost
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+//	'String' string of the decimal with all digits of its scale, e.g. "-1234.50"
+func (d Decimal) String() string {
+	var (
+		value = d.value()
+		str   = new(big.Int).Abs(value).String()
+		sign  string
+	)
+
+	if value.Sign() < 0 {
+		sign = "-"
+	}
+
+	if d.scale <= 0 {
+		return sign + str
+	}
+
+	if len(str) <= d.scale {
+		str = strings.Repeat("0", d.scale-len(str)+1) + str
+	}
+	return sign + str[:len(str)-d.scale] + "." + str[len(str)-d.scale:]
+}
+
+//	'Scan' implements sql.Scanner
+func (d *Decimal) Scan(value interface{}) (err error) {
+	switch v := value.(type) {
+	case nil:
+		*d = Decimal{}
+	case []byte:
+		*d, err = NewFromString(string(v))
+	case string:
+		*d, err = NewFromString(v)
+	case int64:
+		*d = New(v, 0)
+	case float64:
+		*d = NewFromFloat(v)
+	default:
+		*d, err = NewFromString(fmt.Sprint(v))
+	}
+	return
+}
+
+//	'Value' implements driver.Valuer, decimal is saved as string
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+//	'MarshalJSON' marshal decimal to JSON number
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+//	'UnmarshalJSON' unmarshal decimal from JSON number or string
+func (d *Decimal) UnmarshalJSON(data []byte) (err error) {
+	str := strings.Trim(string(data), `"`)
+	if str == "null" || str == "" {
+		*d = Decimal{}
+		return nil
+	}
+	*d, err = NewFromString(str)
+	return
+}
@@ -0,0 +1,81 @@
+package decimal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	if sum := MustFromString("0.1").Add(MustFromString("0.2")); sum.String() != "0.3" {
+		t.Errorf("0.1 + 0.2 should be 0.3, but got %v", sum)
+	}
+
+	if diff := MustFromString("10").Sub(MustFromString("0.01")); diff.String() != "9.99" {
+		t.Errorf("10 - 0.01 should be 9.99, but got %v", diff)
+	}
+
+	if product := MustFromString("19.99").Mul(New(3, 0)); product.String() != "59.97" {
+		t.Errorf("19.99 * 3 should be 59.97, but got %v", product)
+	}
+
+	cases := map[string]string{"1.005": "1.01", "-1.005": "-1.01", "1.004": "1.00", "2": "2.00", "0.5e1": "5.00"}
+	for str, want := range cases {
+		if got := MustFromString(str).Round(2).String(); got != want {
+			t.Errorf("round %v should be %v, but got %v", str, want, got)
+		}
+	}
+
+	for _, str := range []string{"", "abc", "1.2.3", "--1", "1,000", "1e300000000", "1e-300000000", "1e9223372036854775807", "0." + strings.Repeat("0", 1200) + "1"} {
+		if _, err := NewFromString(str); err == nil {
+			t.Errorf("%q should be invalid", str)
+		}
+	}
+}
+
+func TestLocalizedDecimal(t *testing.T) {
+	cases := []struct {
+		str       string
+		locale    string
+		want      string
+		formatted string
+	}{
+		{"1,234.50", "en-US", "1234.50", "1,234.50"},
+		{"1.234,50", "de", "1234.50", "1.234,50"},
+		{"1234,5", "de-AT", "1234.5", "1.234,5"},
+		{"1 234,5", "fr", "1234.5", "1\u202f234,5"},
+		{"1234.5", "ru", "1234.5", "1\u00a0234,5"},
+		{"-1'234.5", "de-CH", "-1234.5", "-1'234.5"},
+		{"0.75", "zh-CN", "0.75", "0.75"},
+	}
+
+	for _, c := range cases {
+		d, err := Parse(c.str, c.locale)
+		if err != nil || d.String() != c.want {
+			t.Errorf("Parse(%q, %v) should be %v, but got %v, %v", c.str, c.locale, c.want, d, err)
+			continue
+		}
+
+		if formatted := d.Format(c.locale); formatted != c.formatted {
+			t.Errorf("Format(%v, %v) should be %v, but got %v", d, c.locale, c.formatted, formatted)
+		}
+	}
+
+	for _, str := range []string{"1,5", "12,34.5", "1,234,5"} {
+		if _, err := Parse(str, "en"); err == nil {
+			t.Errorf("%q should be invalid for en", str)
+		}
+	}
+}
+
+func TestMoney(t *testing.T) {
+	money, err := ParseMoney("EUR 1.234,50", "de")
+	if err != nil || money.Currency != "EUR" || money.Amount.String() != "1234.50" {
+		t.Fatalf("failed to parse money, got %+v, %v", money, err)
+	}
+
+	var scanned Money
+	value, _ := money.Value()
+	if err := scanned.Scan(value); err != nil || scanned.String() != "EUR 1234.50" {
+		t.Errorf("failed to scan money, got %v, %v", scanned, err)
+	}
+}
@@ -0,0 +1,123 @@
+package decimal
+
+import (
+	"strings"
+)
+
+//	'Separators' decimal and group separators of a locale
+type Separators struct {
+	Decimal string
+	Group   string
+}
+
+//	'LocaleSeparators' separators of locales, locales are matched by full name first, then by language, e.g. "de-AT" -> "de"
+//	Locales not in the map use "." as decimal separator, "," as group separator
+var LocaleSeparators = map[string]Separators{
+	"en":    {Decimal: ".", Group: ","},
+	"zh":    {Decimal: ".", Group: ","},
+	"ja":    {Decimal: ".", Group: ","},
+	"ko":    {Decimal: ".", Group: ","},
+	"de":    {Decimal: ",", Group: "."},
+	"de-CH": {Decimal: ".", Group: "'"},
+	"es":    {Decimal: ",", Group: "."},
+	"it":    {Decimal: ",", Group: "."},
+	"nl":    {Decimal: ",", Group: "."},
+	"pt":    {Decimal: ",", Group: "."},
+	"id":    {Decimal: ",", Group: "."},
+	"tr":    {Decimal: ",", Group: "."},
+	"fr":    {Decimal: ",", Group: "\u202f"},
+	"ru":    {Decimal: ",", Group: "\u00a0"},
+	"pl":    {Decimal: ",", Group: "\u00a0"},
+	"sv":    {Decimal: ",", Group: "\u00a0"},
+}
+
+//	'GetSeparators' get separators of the locale
+func GetSeparators(locale string) Separators {
+	locale = strings.Replace(locale, "_", "-", -1)
+	if separators, ok := LocaleSeparators[locale]; ok {
+		return separators
+	}
+
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		if separators, ok := LocaleSeparators[strings.ToLower(locale[:idx])]; ok {
+			return separators
+		}
+	}
+	return Separators{Decimal: ".", Group: ","}
+}
+
+//	'Parse' parse localized decimal string, e.g. "1.234,5" for "de", group separators should separate every 3 digits
+//	Plain strings with "." as decimal separator are accepted for all locales when they have no group separators, e.g. "1234.5"
+func Parse(str string, locale string) (Decimal, error) {
+	var (
+		separators = GetSeparators(locale)
+		sign       string
+	)
+
+	str = strings.TrimSpace(str)
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		sign, str = str[:1], strings.TrimSpace(str[1:])
+	}
+
+	//	spaces are used as group separators by some locales, accept all kinds of them
+	if strings.TrimSpace(separators.Group) == "" {
+		str = strings.NewReplacer(" ", separators.Group, "\u00a0", separators.Group, "\u202f", separators.Group).Replace(str)
+	}
+
+	if !strings.Contains(str, separators.Group) && !strings.Contains(str, separators.Decimal) {
+		return NewFromString(sign + str)
+	}
+
+	if separators.Decimal != "." && strings.Contains(str, ".") && !strings.Contains(str, separators.Decimal) && separators.Group != "." {
+		return NewFromString(sign + str)
+	}
+
+	integer, fraction := str, ""
+	if idx := strings.LastIndex(str, separators.Decimal); idx >= 0 {
+		integer, fraction = str[:idx], str[idx+len(separators.Decimal):]
+		if fraction == "" || strings.Contains(fraction, separators.Group) {
+			return Decimal{}, ErrInvalidDecimal
+		}
+	}
+
+	if strings.Contains(integer, separators.Group) {
+		groups := strings.Split(integer, separators.Group)
+		for idx, group := range groups {
+			if (idx == 0 && (len(group) == 0 || len(group) > 3)) || (idx > 0 && len(group) != 3) {
+				return Decimal{}, ErrInvalidDecimal
+			}
+		}
+		integer = strings.Join(groups, "")
+	}
+
+	if fraction != "" {
+		return NewFromString(sign + integer + "." + fraction)
+	}
+	return NewFromString(sign + integer)
+}
+
+//	'Format' format the decimal with separators of the locale, e.g. "1,234.50" for "en", "1.234,50" for "de"
+func (d Decimal) Format(locale string) string {
+	var (
+		separators = GetSeparators(locale)
+		str        = d.String()
+		sign       string
+		fraction   string
+	)
+
+	if strings.HasPrefix(str, "-") {
+		sign, str = "-", str[1:]
+	}
+
+	if idx := strings.Index(str, "."); idx >= 0 {
+		str, fraction = str[:idx], separators.Decimal+str[idx+1:]
+	}
+
+	var groups []string
+	for len(str) > 3 {
+		groups = append([]string{str[len(str)-3:]}, groups...)
+		str = str[:len(str)-3]
+	}
+	groups = append([]string{str}, groups...)
+	return sign + strings.Join(groups, separators.Group) + fraction
+}
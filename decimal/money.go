@@ -0,0 +1,99 @@
+package decimal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var moneyRegexp = regexp.MustCompile(`^([A-Za-z]{3})?\s*(.*?)\s*([A-Za-z]{3})?$`)
+
+//	'Money' an amount with its currency, it is saved as string like "USD 12.50" to database
+type Money struct {
+	Amount   Decimal
+	Currency string
+}
+
+//	'ParseMoney' parse localized money string, currency code could be before or after the amount, e.g. "USD 1,234.50", "1.234,50 EUR"
+func ParseMoney(str string, locale string) (Money, error) {
+	matches := moneyRegexp.FindStringSubmatch(strings.TrimSpace(str))
+	if matches == nil {
+		return Money{}, ErrInvalidDecimal
+	}
+
+	amount, err := Parse(matches[2], locale)
+	if err != nil {
+		return Money{}, err
+	}
+
+	currency := matches[1]
+	if currency == "" {
+		currency = matches[3]
+	} else if matches[3] != "" {
+		return Money{}, ErrInvalidDecimal
+	}
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}, nil
+}
+
+//	'String' string of money, e.g. "USD 12.50"
+func (money Money) String() string {
+	if money.Currency == "" {
+		return money.Amount.String()
+	}
+	return money.Currency + " " + money.Amount.String()
+}
+
+//	'Format' format money with separators of the locale, e.g. "USD 1,234.50"
+func (money Money) Format(locale string) string {
+	if money.Currency == "" {
+		return money.Amount.Format(locale)
+	}
+	return money.Currency + " " + money.Amount.Format(locale)
+}
+
+//	'Scan' implements sql.Scanner
+func (money *Money) Scan(value interface{}) (err error) {
+	switch v := value.(type) {
+	case nil:
+		*money = Money{}
+	case []byte:
+		*money, err = ParseMoney(string(v), "")
+	case string:
+		*money, err = ParseMoney(v, "")
+	default:
+		*money, err = ParseMoney(fmt.Sprint(v), "")
+	}
+	return
+}
+
+//	'Value' implements driver.Valuer
+func (money Money) Value() (driver.Value, error) {
+	return money.String(), nil
+}
+
+//	'MarshalJSON' marshal money to {"amount": 12.50, "currency": "USD"}
+func (money Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"amount": money.Amount, "currency": money.Currency})
+}
+
+//	'UnmarshalJSON' unmarshal money from {"amount": 12.50, "currency": "USD"} or string "USD 12.50"
+func (money *Money) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		return money.Scan(str)
+	}
+
+	var value struct {
+		Amount   Decimal `json:"amount"`
+		Currency string  `json:"currency"`
+	}
+
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	money.Amount, money.Currency = value.Amount, value.Currency
+	return nil
+}
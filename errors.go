@@ -18,7 +18,7 @@ func (errs Errors) Error() string {
 }
 
 func (errs *Errors) AddError(errors ...error) {
-	for _, err := range errs.errors {
+	for _, err := range errors {
 		if err != nil {
 			if e, ok := err.(errorsInterface); ok {
 				errs.errors = append(errs.errors, e.GetErrors()...)
@@ -0,0 +1,209 @@
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+//	'Translator' translate key to message format of the locale, returns false if the key is not translated for the locale
+type Translator interface {
+	Translate(locale string, key string) (string, bool)
+}
+
+//	'Catalog' messages of a locale, key is translation key, value is message format like "%v can't be blank"
+type Catalog map[string]string
+
+//	'Catalogs' translator that translates keys with catalogs of locales
+type Catalogs struct {
+	catalogs map[string]Catalog
+	mutex    sync.RWMutex
+}
+
+//	'NewCatalogs' initialize catalogs translator, locale of catalogs is normalized, e.g. "zh_cn" -> "zh-CN"
+func NewCatalogs(catalogs map[string]Catalog) *Catalogs {
+	translator := &Catalogs{catalogs: map[string]Catalog{}}
+	for locale, catalog := range catalogs {
+		translator.AddMessages(locale, catalog)
+	}
+	return translator
+}
+
+//	'AddMessages' add messages to catalog of the locale, existing messages with same keys will be replaced
+func (translator *Catalogs) AddMessages(locale string, messages Catalog) {
+	locale = Normalize(locale)
+
+	translator.mutex.Lock()
+	defer translator.mutex.Unlock()
+	catalog, ok := translator.catalogs[locale]
+	if !ok {
+		catalog = Catalog{}
+		translator.catalogs[locale] = catalog
+	}
+
+	for key, message := range messages {
+		catalog[key] = message
+	}
+}
+
+//	'Translate' implements 'Translator'
+func (translator *Catalogs) Translate(locale string, key string) (string, bool) {
+	translator.mutex.RLock()
+	defer translator.mutex.RUnlock()
+	message, ok := translator.catalogs[locale][key]
+	return message, ok
+}
+
+var (
+	//	'DefaultLocale' last locale of fallback chains
+	DefaultLocale = "en"
+	//	'Fallbacks' locales tried before parent locale, e.g. "zh" falls back to "zh-CN" before the default locale
+	Fallbacks = map[string][]string{
+		"zh":      {"zh-CN"},
+		"zh-Hans": {"zh-CN"},
+		"zh-SG":   {"zh-CN"},
+	}
+
+	//	'DefaultCatalogs' catalogs of the default translator, messages could be added or overwritten with 'DefaultCatalogs.AddMessages'
+	DefaultCatalogs = NewCatalogs(map[string]Catalog{"en": English, "zh-CN": SimplifiedChinese})
+
+	translator      Translator = DefaultCatalogs
+	translatorMutex sync.RWMutex
+)
+
+//	'SetTranslator' replace the translator, e.g. translator backed by database, use 'DefaultCatalogs' in it to keep built-in messages
+func SetTranslator(t Translator) {
+	translatorMutex.Lock()
+	translator = t
+	translatorMutex.Unlock()
+}
+
+//	'GetTranslator' get current translator
+func GetTranslator() Translator {
+	translatorMutex.RLock()
+	defer translatorMutex.RUnlock()
+	return translator
+}
+
+//	'Normalize' normalize locale to language-Region form, e.g. "zh_cn" -> "zh-CN", "en-us" -> "en-US"
+func Normalize(locale string) string {
+	parts := strings.Split(strings.Replace(strings.TrimSpace(locale), "_", "-", -1), "-")
+	for idx, part := range parts {
+		switch {
+		case idx == 0:
+			parts[idx] = strings.ToLower(part)
+		case len(part) == 2:
+			parts[idx] = strings.ToUpper(part)
+		case len(part) == 4:
+			parts[idx] = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+//	'FallbackChain' locales tried when translating for the locale, e.g. "zh-Hans-CN" -> ["zh-Hans-CN", "zh-Hans", "zh-CN", "zh", "en"]
+func FallbackChain(locale string) (chain []string) {
+	var appendLocale func(string)
+	appendLocale = func(locale string) {
+		if locale == "" {
+			return
+		}
+
+		for _, l := range chain {
+			if l == locale {
+				return
+			}
+		}
+
+		chain = append(chain, locale)
+		for _, fallback := range Fallbacks[locale] {
+			appendLocale(fallback)
+		}
+	}
+
+	locale = Normalize(locale)
+	for locale != "" {
+		appendLocale(locale)
+		if idx := strings.LastIndex(locale, "-"); idx > 0 {
+			locale = locale[:idx]
+		} else {
+			locale = ""
+		}
+	}
+
+	appendLocale(Normalize(DefaultLocale))
+	return
+}
+
+//	'T' translate key to message of the locale with args, the key is used as message format if it is not translated in any locale of the fallback chain
+func T(locale string, key string, args ...interface{}) string {
+	format := key
+	t := GetTranslator()
+	for _, l := range FallbackChain(locale) {
+		if message, ok := t.Translate(l, key); ok {
+			format = message
+			break
+		}
+	}
+
+	if len(args) == 0 || !strings.Contains(format, "%") {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+//	'Translate' translate key to message of the context's locale got with 'utils.GetLocale'
+func Translate(context *TM_EC.Context, key string, args ...interface{}) string {
+	return T(utils.GetLocale(context), key, args...)
+}
+
+//	'Error' an error carries translation key and args, so it could be localized when presenting
+type Error struct {
+	Key  string
+	Args []interface{}
+}
+
+//	'NewError' new localizable error
+func NewError(key string, args ...interface{}) *Error {
+	return &Error{Key: key, Args: args}
+}
+
+//	'Error' message of the default locale
+func (err *Error) Error() string {
+	return T(DefaultLocale, err.Key, err.Args...)
+}
+
+//	'Localize' message of the locale
+func (err *Error) Localize(locale string) string {
+	return T(locale, err.Key, err.Args...)
+}
+
+//	'Localize' localize the error if it carries translation key, otherwise returns its message
+func Localize(err error, locale string) string {
+	if e, ok := err.(interface {
+		Localize(string) string
+	}); ok {
+		return e.Localize(locale)
+	}
+	return err.Error()
+}
+
+//	'LocalizeErrors' localize errors, errors like 'TM_EC.Errors' are flattened
+func LocalizeErrors(err error, locale string) (messages []string) {
+	if err == nil {
+		return nil
+	}
+
+	if errs, ok := err.(interface {
+		GetErrors() []error
+	}); ok {
+		for _, e := range errs.GetErrors() {
+			messages = append(messages, LocalizeErrors(e, locale)...)
+		}
+		return
+	}
+	return []string{Localize(err, locale)}
+}
@@ -0,0 +1,72 @@
+package i18n_test
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+)
+
+func TestFallbackChain(t *testing.T) {
+	cases := []struct {
+		locale string
+		chain  []string
+	}{
+		{"", []string{"en"}},
+		{"en-us", []string{"en-US", "en"}},
+		{"zh_cn", []string{"zh-CN", "zh", "en"}},
+		{"zh-hans-cn", []string{"zh-Hans-CN", "zh-Hans", "zh-CN", "zh", "en"}},
+	}
+
+	for _, c := range cases {
+		if chain := i18n.FallbackChain(c.locale); !reflect.DeepEqual(chain, c.chain) {
+			t.Errorf("fallback chain of %v should be %v, but got %v", c.locale, c.chain, chain)
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	i18n.DefaultCatalogs.AddMessages("en", i18n.Catalog{"test.only_english": "only %v"})
+
+	cases := []struct {
+		locale  string
+		key     string
+		args    []interface{}
+		message string
+	}{
+		{"en-US", "ec.errors.blank", []interface{}{"Name"}, "Name can't be blank"},
+		{"zh-CN", "ec.errors.blank", []interface{}{"Name"}, "Name不能为空"},
+		{"zh", "ec.errors.not_found", nil, "未找到记录"},
+		{"zh-CN", "test.only_english", []interface{}{"english"}, "only english"},
+		{"zh-CN", "%v is not translated", []interface{}{"message"}, "message is not translated"},
+	}
+
+	for _, c := range cases {
+		if message := i18n.T(c.locale, c.key, c.args...); message != c.message {
+			t.Errorf("%v of %v should be %v, but got %v", c.key, c.locale, c.message, message)
+		}
+	}
+
+	request, _ := http.NewRequest("GET", "/?locale=zh-CN", nil)
+	context := &TM_EC.Context{Request: request}
+	if message := i18n.Translate(context, "ec.errors.taken", "Email"); message != "Email已经被使用" {
+		t.Errorf("should translate with locale of the context, but got %v", message)
+	}
+}
+
+func TestLocalizeErrors(t *testing.T) {
+	var errs TM_EC.Errors
+	errs.AddError(i18n.NewError("ec.errors.invalid_option", "red"), errors.New("plain error"))
+
+	if errs.Error() != "red is not a valid option;plain error" {
+		t.Errorf("errors should use messages of the default locale, but got %v", errs.Error())
+	}
+
+	messages := i18n.LocalizeErrors(errs, "zh-CN")
+	if !reflect.DeepEqual(messages, []string{"red 不是有效的选项", "plain error"}) {
+		t.Errorf("errors should be localized, but got %v", messages)
+	}
+}
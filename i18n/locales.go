@@ -0,0 +1,71 @@
+package i18n
+
+// 'English' built-in English messages
+var English = Catalog{
+	"ec.errors.not_found":                "failed to find",
+	"ec.errors.invalid_value":            "Can't set value %v",
+	"ec.errors.invalid_option":           "%v is not a valid option",
+	"ec.errors.invalid_amount":           "%v is not a valid amount",
+	"ec.errors.invalid_number":           "%v is not a valid number",
+	"ec.errors.invalid_format":           "%v is not a valid %v",
+	"ec.errors.not_a_number":             "%v is not a number",
+	"ec.errors.too_many_decimal_places":  "%v should have at most %v decimal places",
+	"ec.errors.out_of_range":             "%v is out of range, it should have at most %v integer digits",
+	"ec.errors.unsupported_currency":     "currency %v is not supported",
+	"ec.errors.blank":                    "%v can't be blank",
+	"ec.errors.greater_than_or_equal_to": "%v must be greater than or equal to %v",
+	"ec.errors.less_than_or_equal_to":    "%v must be less than or equal to %v",
+	"ec.errors.too_short":                "%v is too short (minimum is %v characters)",
+	"ec.errors.too_long":                 "%v is too long (maximum is %v characters)",
+	"ec.errors.invalid":                  "%v is invalid",
+	"ec.errors.inclusion":                "%v is not included in the list",
+	"ec.errors.taken":                    "%v has already been taken",
+	"ec.errors.invalid_patch":            "%v operation of %v is invalid",
+	"ec.errors.unsupported_patch":        "patch operation %v is not supported",
+	"ec.errors.invalid_patch_index":      "%v is not a valid index",
+	"ec.errors.patch_test_failed":        "%v is not %v",
+	"ec.errors.invalid_record":           "record %v is not a JSON object",
+	"ec.media.invalid_file":              "%v is not a valid file",
+	"ec.media.too_large":                 "%v is too large, it should be less than %v bytes",
+	"ec.media.content_type_not_allowed":  "%v's type %v is not allowed",
+	"ec.media.invalid_crop":              "%v is not a valid crop %v",
+	"ec.media.invalid_crop_area":         "crop area %+v is invalid",
+	"ec.media.in_use":                    "%v is used by %v records, it can't be deleted",
+	"ec.media.not_found":                 "media %v is not found",
+	"ec.media.only_one_media_selectable": "only one media could be selected",
+}
+
+// 'SimplifiedChinese' built-in Simplified Chinese messages
+var SimplifiedChinese = Catalog{
+	"ec.errors.not_found":                "未找到记录",
+	"ec.errors.invalid_value":            "无法设置值 %v",
+	"ec.errors.invalid_option":           "%v 不是有效的选项",
+	"ec.errors.invalid_amount":           "%v 不是有效的金额",
+	"ec.errors.invalid_number":           "%v 不是有效的数字",
+	"ec.errors.invalid_format":           "%v 不是有效的%v",
+	"ec.errors.not_a_number":             "%v 不是数字",
+	"ec.errors.too_many_decimal_places":  "%v 最多只能有 %v 位小数",
+	"ec.errors.out_of_range":             "%v 超出范围，整数部分最多 %v 位",
+	"ec.errors.unsupported_currency":     "不支持货币 %v",
+	"ec.errors.blank":                    "%v不能为空",
+	"ec.errors.greater_than_or_equal_to": "%v必须大于或等于 %v",
+	"ec.errors.less_than_or_equal_to":    "%v必须小于或等于 %v",
+	"ec.errors.too_short":                "%v过短（最少 %v 个字符）",
+	"ec.errors.too_long":                 "%v过长（最多 %v 个字符）",
+	"ec.errors.invalid":                  "%v无效",
+	"ec.errors.inclusion":                "%v不在可选范围内",
+	"ec.errors.taken":                    "%v已经被使用",
+	"ec.errors.invalid_patch":            "%v 操作的路径 %v 无效",
+	"ec.errors.unsupported_patch":        "不支持补丁操作 %v",
+	"ec.errors.invalid_patch_index":      "%v 不是有效的索引",
+	"ec.errors.patch_test_failed":        "%v不是 %v",
+	"ec.errors.invalid_record":           "记录 %v 不是 JSON 对象",
+	"ec.media.invalid_file":              "%v 不是有效的文件",
+	"ec.media.too_large":                 "%v 太大，应小于 %v 字节",
+	"ec.media.content_type_not_allowed":  "%v 的类型 %v 不被允许",
+	"ec.media.invalid_crop":              "%v 不是有效的裁剪%v",
+	"ec.media.invalid_crop_area":         "裁剪区域 %+v 无效",
+	"ec.media.in_use":                    "%v 被 %v 条记录使用，无法删除",
+	"ec.media.not_found":                 "媒体 %v 不存在",
+	"ec.media.only_one_media_selectable": "只能选择一个媒体",
+}
@@ -0,0 +1,203 @@
+package media
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+)
+
+//	'Upload' a pending upload, it is decoded from submitted file and stored when the record is saved
+type Upload struct {
+	Header      *multipart.FileHeader
+	ContentType string
+	Storage     string
+	Crop        *Crop
+	Variants    map[string]*Variant
+}
+
+//	'File' an uploaded file field, it is saved as JSON descriptor like {"FileName": "a.png", "Path": "products/image/...", ...} to database
+//	'Variants' is paths of generated image variants, 'Crop' is the crop area used to generate them
+//	Call 'RegisterCallbacks' to store pending uploads when saving records
+type File struct {
+	FileName    string            `json:",omitempty"`
+	Path        string            `json:",omitempty"`
+	Size        int64             `json:",omitempty"`
+	ContentType string            `json:",omitempty"`
+	Storage     string            `json:",omitempty"`
+	Crop        *Crop             `json:",omitempty"`
+	Variants    map[string]string `json:",omitempty"`
+	upload      *Upload
+	variants    map[string]*Variant
+	reprocess   bool
+	cropped     bool
+}
+
+var invalidFileNameRegexp = regexp.MustCompile(`[^\w\-.]+`)
+
+//	'Scan' implements sql.Scanner, it accepts JSON descriptor from database, and pending upload from setter
+func (file *File) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*file = File{}
+	case *Upload:
+		*file = File{FileName: v.Header.Filename, Size: v.Header.Size, ContentType: v.ContentType, Storage: v.Storage, Crop: v.Crop, upload: v, variants: v.Variants}
+	case *File:
+		*file = *v
+	case File:
+		*file = v
+	case []byte:
+		return file.Scan(string(v))
+	case string:
+		if v == "" {
+			*file = File{}
+			return nil
+		}
+
+		var descriptor File
+		if err := json.Unmarshal([]byte(v), &descriptor); err != nil {
+			return err
+		}
+		*file = descriptor
+	default:
+		return fmt.Errorf("media: can't scan %T to file", value)
+	}
+	return nil
+}
+
+//	'Value' implements driver.Valuer, returns nil for blank file
+func (file File) Value() (driver.Value, error) {
+	if file.Path == "" && file.upload == nil {
+		return nil, nil
+	}
+
+	descriptor, err := json.Marshal(file)
+	return string(descriptor), err
+}
+
+//	'ConfigureECMetaBeforeInitialize' use upload meta type for file fields
+func (*File) ConfigureECMetaBeforeInitialize(metaor resource.Metaor) {
+	if meta, ok := metaor.(*resource.Meta); ok && meta.Type == "" {
+		meta.Type = "upload"
+	}
+}
+
+//	'IsPending' check the file is uploaded but not stored yet, or its variants need to be regenerated
+func (file File) IsPending() bool {
+	return file.upload != nil || file.reprocess
+}
+
+//	'SetVariants' set variants of the file, and mark them to be regenerated when the record is saved
+func (file *File) SetVariants(variants map[string]*Variant) {
+	file.variants = variants
+	file.reprocess = file.Path != ""
+}
+
+//	'paths' paths of the stored file and its variants
+func (file File) paths() (paths []string) {
+	if file.Path != "" {
+		paths = append(paths, file.Path)
+	}
+
+	for _, filePath := range file.Variants {
+		paths = append(paths, filePath)
+	}
+	return
+}
+
+//	'URL' URL of the stored file, or URL of its variant if variant name is given
+//	Returns blank string if there is no file or its storage is not registered
+func (file File) URL(variant ...string) string {
+	filePath := file.Path
+	if len(variant) > 0 && variant[0] != "" {
+		filePath = file.Variants[variant[0]]
+	}
+
+	if filePath == "" {
+		return ""
+	}
+
+	storage, err := GetStorage(file.Storage)
+	if err != nil {
+		return ""
+	}
+	return storage.URL(filePath)
+}
+
+//	'String' URL of the file
+func (file File) String() string {
+	return file.URL()
+}
+
+//	'Store' store pending upload to its storage, and generate image variants, dir is used as prefix of the file's path
+func (file *File) Store(dir string) error {
+	if !file.IsPending() {
+		return nil
+	}
+
+	storage, err := GetStorage(file.Storage)
+	if err != nil {
+		return err
+	}
+
+	if file.upload != nil {
+		reader, err := file.upload.Header.Open()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		token := make([]byte, 8)
+		rand.Read(token)
+
+		fileName := invalidFileNameRegexp.ReplaceAllString(path.Base(strings.Replace(file.FileName, "\\", "/", -1)), "_")
+		filePath := path.Join(dir, time.Now().Format("2006/01"), hex.EncodeToString(token), fileName)
+		if err := storage.Put(filePath, reader); err != nil {
+			return err
+		}
+
+		file.Path = filePath
+		file.Variants = nil
+	}
+
+	if len(file.variants) > 0 && IsImage(file.ContentType) {
+		if err := file.generateVariants(storage); err != nil {
+			return err
+		}
+	}
+
+	file.upload, file.reprocess, file.cropped = nil, false, false
+	return nil
+}
+
+func (file *File) generateVariants(storage Storage) error {
+	reader, err := storage.Get(file.Path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	results, format, err := processImage(reader, file.Crop, file.variants)
+	if err != nil {
+		return err
+	}
+
+	file.Variants = map[string]string{}
+	for name, content := range results {
+		filePath := variantPath(file.Path, name, file.variants[name], format)
+		if err := storage.Put(filePath, bytes.NewReader(content)); err != nil {
+			return err
+		}
+		file.Variants[name] = filePath
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+	"strings"
+)
+
+//	'ResizeMode' how images are resized to variants' size
+type ResizeMode int
+
+const (
+	//	'Fit' resize the image to fit in the size, ratio is kept
+	Fit ResizeMode = iota
+	//	'Fill' resize the image to fill the size, ratio is kept, overflowed parts are cropped from center
+	Fill
+	//	'Stretch' resize the image to the size exactly
+	Stretch
+)
+
+//	'Variant' definition of an image variant, like thumbnails
+//	If only one of 'Width', 'Height' is set, the other one is calculated with the image's ratio
+//	'Format' is "jpeg", "png" or "gif", blank means the original format
+//	'Quality' is the quality of jpeg images, it is 85 by default
+type Variant struct {
+	Width   int
+	Height  int
+	Mode    ResizeMode
+	Format  string
+	Quality int
+}
+
+//	'Crop' crop area of the original image, variants are generated from the cropped image
+type Crop struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+//	'IsImage' check the content type is an image type that could be processed
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
+
+//	'MaxImagePixels' max width × height of images that variants could be generated from, images are checked before decoding, so small files that declare huge dimensions won't exhaust memory
+var MaxImagePixels = 50000000
+
+//	'processImage' decode image from reader, crop it, and encode each variant
+func processImage(reader io.Reader, crop *Crop, variants map[string]*Variant) (map[string][]byte, string, error) {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(reader, &header))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if pixels := int64(config.Width) * int64(config.Height); pixels > int64(MaxImagePixels) {
+		return nil, "", fmt.Errorf("media: image of %vx%v is too large, it should have at most %v pixels", config.Width, config.Height, MaxImagePixels)
+	}
+
+	src, format, err := image.Decode(io.MultiReader(&header, reader))
+	if err != nil {
+		return nil, "", err
+	}
+
+	if crop != nil && crop.Width > 0 && crop.Height > 0 {
+		rect := image.Rect(crop.X, crop.Y, crop.X+crop.Width, crop.Y+crop.Height).Add(src.Bounds().Min).Intersect(src.Bounds())
+		if rect.Empty() {
+			return nil, "", fmt.Errorf("media: crop area %+v is out of image", *crop)
+		}
+
+		cropped := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+		draw.Draw(cropped, cropped.Bounds(), src, rect.Min, draw.Src)
+		src = cropped
+	}
+
+	results := map[string][]byte{}
+	for name, variant := range variants {
+		var (
+			buffer  bytes.Buffer
+			encoder = variant.Format
+			dst     = resizeImage(src, variant)
+		)
+
+		if encoder == "" {
+			encoder = format
+		}
+
+		switch encoder {
+		case "jpeg", "jpg":
+			quality := variant.Quality
+			if quality == 0 {
+				quality = 85
+			}
+			err = jpeg.Encode(&buffer, dst, &jpeg.Options{Quality: quality})
+		case "png":
+			err = png.Encode(&buffer, dst)
+		case "gif":
+			err = gif.Encode(&buffer, dst, nil)
+		default:
+			err = fmt.Errorf("media: unsupported image format %v", encoder)
+		}
+
+		if err != nil {
+			return nil, "", err
+		}
+		results[name] = buffer.Bytes()
+	}
+	return results, format, nil
+}
+
+//	'variantPath' path of the variant, it is next to the original file, e.g. "a/b/logo.png" -> "a/b/logo.thumb.jpg"
+func variantPath(originalPath string, name string, variant *Variant, originalFormat string) string {
+	var (
+		ext    = path.Ext(originalPath)
+		format = variant.Format
+	)
+
+	if format == "" {
+		format = originalFormat
+	}
+
+	switch format {
+	case "jpeg", "jpg":
+		ext = ".jpg"
+	case "png", "gif":
+		ext = "." + format
+	}
+	return strings.TrimSuffix(originalPath, path.Ext(originalPath)) + "." + name + ext
+}
+
+//	'resizeImage' resize image to variant's size
+func resizeImage(src image.Image, variant *Variant) image.Image {
+	var (
+		bounds        = src.Bounds()
+		width, height = variant.Width, variant.Height
+		srcRect       = bounds
+	)
+
+	switch {
+	case width == 0 && height == 0:
+		return src
+	case width == 0:
+		width = bounds.Dx() * height / bounds.Dy()
+	case height == 0:
+		height = bounds.Dy() * width / bounds.Dx()
+	case variant.Mode == Fit:
+		if bounds.Dx()*height > bounds.Dy()*width {
+			height = bounds.Dy() * width / bounds.Dx()
+		} else {
+			width = bounds.Dx() * height / bounds.Dy()
+		}
+	case variant.Mode == Fill:
+		//	crop the center part that has the same ratio with the variant
+		if bounds.Dx()*height > bounds.Dy()*width {
+			cropWidth := bounds.Dy() * width / height
+			srcRect.Min.X += (bounds.Dx() - cropWidth) / 2
+			srcRect.Max.X = srcRect.Min.X + cropWidth
+		} else {
+			cropHeight := bounds.Dx() * height / width
+			srcRect.Min.Y += (bounds.Dy() - cropHeight) / 2
+			srcRect.Max.Y = srcRect.Min.Y + cropHeight
+		}
+	}
+
+	if width < 1 {
+		width = 1
+	}
+
+	if height < 1 {
+		height = 1
+	}
+
+	return scale(src, srcRect, width, height)
+}
+
+//	'scale' scale rect of src to width x height with box filter, every target pixel is the average of source pixels it covers
+func scale(src image.Image, rect image.Rectangle, width, height int) *image.RGBA {
+	var (
+		dst    = image.NewRGBA(image.Rect(0, 0, width, height))
+		scaleX = float64(rect.Dx()) / float64(width)
+		scaleY = float64(rect.Dy()) / float64(height)
+	)
+
+	for y := 0; y < height; y++ {
+		y0 := rect.Min.Y + int(float64(y)*scaleY)
+		y1 := rect.Min.Y + int(float64(y+1)*scaleY)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := rect.Min.X + int(float64(x)*scaleX)
+			x1 := rect.Min.X + int(float64(x+1)*scaleX)
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a, count = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca), count+1
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{R: uint16(r / count), G: uint16(g / count), B: uint16(b / count), A: uint16(a / count)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,318 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+//	'MediaLibrary' an asset of the shared media library, it could be referenced from other models with select_media metas
+//	'Hash' is SHA-256 of the file's content, the same file uploaded again will reuse the existing asset
+//	'Tags' is comma separated tags, 'Width', 'Height' are dimensions of images
+type MediaLibrary struct {
+	gorm.Model
+	Title  string
+	File   File
+	Hash   string `gorm:"size:64;index"`
+	Alt    string
+	Tags   string
+	Width  int
+	Height int
+}
+
+//	'TagList' tags of the asset
+func (asset MediaLibrary) TagList() (tags []string) {
+	for _, tag := range strings.Split(asset.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return
+}
+
+//	'Stringify' title of the asset, or its file name if title is blank
+func (asset MediaLibrary) Stringify() string {
+	if asset.Title != "" {
+		return asset.Title
+	}
+	return asset.File.FileName
+}
+
+//	'NewLibraryResource' initialize resource of the media library
+//	Uploaded files are de-duplicated by content hash, and assets can't be deleted while they are referenced by select_media metas
+func NewLibraryResource() *resource.Resource {
+	res := resource.New(&MediaLibrary{})
+	res.Name = "Media Library"
+	res.AddProcessor(deduplicate)
+	res.AddCallback(resource.CallbackBeforeDelete, "media_library:check_usage", func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		asset, ok := record.(*MediaLibrary)
+		if !ok || asset.ID == 0 {
+			return nil
+		}
+
+		usages, err := GetUsages(asset.ID, context)
+		if err != nil {
+			return err
+		}
+
+		var count int
+		for _, usage := range usages {
+			count += len(usage.PrimaryValues)
+		}
+
+		if count > 0 {
+			return i18n.NewError("ec.media.in_use", asset.Stringify(), count)
+		}
+		return nil
+	})
+	return res
+}
+
+//	'deduplicate' processor of the media library, it calculates hash and dimensions of uploaded file
+//	If there is an asset with the same hash, the asset will be reused, submitted alt text fills its blank alt text, and submitted tags are merged to its tags
+func deduplicate(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+	asset, ok := record.(*MediaLibrary)
+	if !ok || asset.File.upload == nil {
+		return nil
+	}
+
+	reader, err := asset.File.upload.Header.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return err
+	}
+	asset.Hash = hex.EncodeToString(hash.Sum(nil))
+
+	if asset.Title == "" {
+		asset.Title = asset.File.FileName
+	}
+
+	var existing MediaLibrary
+	if !context.GetDB().Where("hash = ? AND id <> ?", asset.Hash, asset.ID).First(&existing).RecordNotFound() {
+		tags := existing.TagList()
+		for _, tag := range asset.TagList() {
+			if !hasTag(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+
+		if asset.Alt != "" && existing.Alt == "" {
+			existing.Alt = asset.Alt
+		}
+		existing.Tags = strings.Join(tags, ",")
+		*asset = existing
+		return nil
+	}
+
+	if IsImage(asset.File.ContentType) {
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		if config, _, err := image.DecodeConfig(reader); err == nil {
+			asset.Width, asset.Height = config.Width, config.Height
+		}
+	}
+	return nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+//	'SelectMediaConfig' meta config for select_media metas
+//	The field could be an integer field that references one asset, or a string field that references many assets with comma separated IDs
+//	'Variant' is the image variant used as formatted value, blank means the original file
+type SelectMediaConfig struct {
+	resource.MetaConfig
+	Variant string
+}
+
+//	'Usage' records of a model that reference an asset
+type Usage struct {
+	Table         string
+	Column        string
+	PrimaryValues []string
+}
+
+type reference struct {
+	model    interface{}
+	dbName   string
+	multiple bool
+}
+
+var (
+	references      = map[string]*reference{}
+	referencesMutex sync.RWMutex
+)
+
+//	'RegisterReference' register a field that references media library assets, so deleting referenced assets will be blocked, and it is included in usage reports
+//	select_media metas register their fields automatically
+func RegisterReference(model interface{}, fieldName string) error {
+	var (
+		modelType = utils.ModelType(model)
+		value     = reflect.New(modelType).Interface()
+	)
+
+	field, ok := (&gorm.Scope{Value: value}).FieldByName(fieldName)
+	if !ok {
+		return fmt.Errorf("media: %v doesn't have field %v", modelType, fieldName)
+	}
+
+	referencesMutex.Lock()
+	references[modelType.String()+"."+field.DBName] = &reference{model: value, dbName: field.DBName, multiple: field.Field.Kind() == reflect.String}
+	referencesMutex.Unlock()
+	return nil
+}
+
+//	'GetUsages' get records that reference the asset, grouped by referencing table and column
+func GetUsages(assetID uint, context *TM_EC.Context) (usages []Usage, err error) {
+	var (
+		keys []string
+		refs = map[string]*reference{}
+	)
+
+	referencesMutex.RLock()
+	for key, ref := range references {
+		keys = append(keys, key)
+		refs[key] = ref
+	}
+	referencesMutex.RUnlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		ref := refs[key]
+
+		var (
+			db      = context.GetDB()
+			scope   = db.NewScope(ref.model)
+			column  = scope.Quote(ref.dbName)
+			id      = fmt.Sprint(assetID)
+			records = reflect.New(reflect.SliceOf(reflect.TypeOf(ref.model)))
+		)
+
+		if ref.multiple {
+			db = db.Where(fmt.Sprintf("%v = ? OR %v LIKE ? OR %v LIKE ? OR %v LIKE ?", column, column, column, column), id, id+",%", "%,"+id, "%,"+id+",%")
+		} else {
+			db = db.Where(fmt.Sprintf("%v = ?", column), assetID)
+		}
+
+		if err = db.Find(records.Interface()).Error; err != nil {
+			return
+		}
+
+		if records.Elem().Len() > 0 {
+			usage := Usage{Table: scope.TableName(), Column: ref.dbName}
+			for i := 0; i < records.Elem().Len(); i++ {
+				usage.PrimaryValues = append(usage.PrimaryValues, resource.PrimaryValueOf(records.Elem().Index(i).Interface()))
+			}
+			usages = append(usages, usage)
+		}
+	}
+	return
+}
+
+func init() {
+	resource.RegisterMetaType(&resource.MetaType{
+		Name: "select_media",
+		Configure: func(meta *resource.Meta) {
+			if _, ok := meta.Config.(*SelectMediaConfig); !ok {
+				meta.Config = &SelectMediaConfig{}
+			}
+
+			if meta.FieldStruct != nil && meta.Resource != nil && !strings.Contains(meta.GetFieldName(), ".") {
+				RegisterReference(meta.Resource.GetResource().Value, meta.GetFieldName())
+			}
+		},
+		Decode: func(meta *resource.Meta, record interface{}, metaValue *resource.MetaValue, context *TM_EC.Context) (interface{}, error) {
+			var ids []string
+			for _, value := range utils.ToArray(metaValue.Value) {
+				for _, id := range strings.Split(value, ",") {
+					if id = strings.TrimSpace(id); id != "" {
+						ids = append(ids, id)
+					}
+				}
+			}
+
+			for _, id := range ids {
+				var count int
+				if context.GetDB().Model(&MediaLibrary{}).Where("id = ?", id).Count(&count); count == 0 {
+					return nil, i18n.NewError("ec.media.not_found", id)
+				}
+			}
+
+			if reflect.Indirect(reflect.ValueOf(record)).FieldByName(meta.GetFieldName()).Kind() == reflect.String {
+				return strings.Join(ids, ","), nil
+			}
+
+			if len(ids) > 1 {
+				return nil, i18n.NewError("ec.media.only_one_media_selectable")
+			}
+			return strings.Join(ids, ""), nil
+		},
+		Format: func(meta *resource.Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{} {
+			var (
+				variant = meta.Config.(*SelectMediaConfig).Variant
+				ids     []string
+				urls    []string
+			)
+
+			for _, id := range strings.Split(fmt.Sprint(reflect.Indirect(reflect.ValueOf(value)).Interface()), ",") {
+				if id != "" && id != "0" {
+					ids = append(ids, id)
+				}
+			}
+
+			if len(ids) > 0 {
+				var assets []MediaLibrary
+				context.GetDB().Where("id IN (?)", ids).Find(&assets)
+				for _, id := range ids {
+					for _, asset := range assets {
+						if fmt.Sprint(asset.ID) == id {
+							urls = append(urls, asset.File.URL(variant))
+						}
+					}
+				}
+			}
+
+			if reflect.Indirect(reflect.ValueOf(value)).Kind() == reflect.String {
+				return urls
+			}
+
+			if len(urls) > 0 {
+				return urls[0]
+			}
+			return ""
+		},
+		Schema: func(meta *resource.Meta, schema *resource.MetaSchema, context *TM_EC.Context) {
+			schema.Attributes["resource"] = "Media Library"
+			if meta.FieldStruct != nil && meta.FieldStruct.Struct.Type.Kind() == reflect.String {
+				schema.Attributes["multiple"] = true
+			}
+		},
+	})
+}
@@ -0,0 +1,143 @@
+package media_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/media"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type Banner struct {
+	gorm.Model
+	Title      string
+	ImageID    uint
+	GalleryIDs string
+}
+
+func TestMediaLibrary(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "media")
+	defer os.RemoveAll(dir)
+	media.RegisterStorage("test", media.FileSystem{Dir: dir, URLPrefix: "/uploads"})
+
+	db := utils.TestDB()
+	db.DropTableIfExists(&media.MediaLibrary{}, &Banner{})
+	db.AutoMigrate(&media.MediaLibrary{}, &Banner{})
+	media.RegisterCallbacks(db)
+
+	var (
+		library     = media.NewLibraryResource()
+		libraryMeta = []*resource.Meta{
+			{Name: "Image", FieldName: "File", Resource: library, Config: &media.UploadConfig{Storage: "test"}},
+			{Name: "Alt", Resource: library},
+			{Name: "Tags", Resource: library},
+		}
+		banner      = resource.New(&Banner{})
+		bannerMetas = []*resource.Meta{
+			{Name: "Image", FieldName: "ImageID", Type: "select_media", Resource: banner},
+			{Name: "Gallery", FieldName: "GalleryIDs", Type: "select_media", Resource: banner, Config: &media.SelectMediaConfig{Variant: "thumb"}},
+		}
+		content = newImage(60, 40)
+	)
+
+	for _, meta := range append(libraryMeta, bannerMetas...) {
+		meta.PerInitialize()
+		meta.Initialize()
+	}
+
+	upload := func(fields ...string) *media.MediaLibrary {
+		var (
+			asset   media.MediaLibrary
+			request = newUploadRequest("banner.png", content, fields...)
+			context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}
+		)
+
+		metaValues, _ := resource.ConvertFormToMetaValues(request, []resource.Metaor{libraryMeta[0], libraryMeta[1], libraryMeta[2]}, "ECResource.")
+		if err := resource.DecodeToResource(library, &asset, metaValues, context).Start(); err != nil || context.HasError() {
+			t.Fatalf("failed to decode asset, got %v %v", err, context.GetErrors())
+		}
+
+		if err := library.CallSave(&asset, context); err != nil {
+			t.Fatal(err)
+		}
+		return &asset
+	}
+
+	asset := upload("ECResource.Tags", "home")
+	if asset.Width != 60 || asset.Height != 40 || asset.Hash == "" || asset.Title != "banner.png" {
+		t.Errorf("hash and dimensions of the asset should be set, but got %+v", asset)
+	}
+
+	duplicated := upload("ECResource.Tags", "sale,Home", "ECResource.Alt", "Big sale")
+	var count int
+	db.Model(&media.MediaLibrary{}).Count(&count)
+	if duplicated.ID != asset.ID || count != 1 {
+		t.Fatalf("same file should reuse the existing asset, but got %v assets", count)
+	}
+
+	var saved media.MediaLibrary
+	db.First(&saved, asset.ID)
+	if saved.Tags != "home,sale" || saved.Alt != "Big sale" {
+		t.Errorf("tags and alt should be merged to the existing asset, but got %v, %v", saved.Tags, saved.Alt)
+	}
+
+	//	reference the asset from banners
+	cases := []struct {
+		image    string
+		gallery  string
+		hasError bool
+	}{
+		{"999", "", true},
+		{"1,1", "", true},
+		{"", "1,999", true},
+		{"1", "1", false},
+	}
+
+	var record Banner
+	for _, c := range cases {
+		var (
+			form    = url.Values{"ECResource.Image": {c.image}, "ECResource.Gallery": {c.gallery}}
+			request = &http.Request{Form: form}
+			context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}
+		)
+
+		record = Banner{}
+		metaValues, _ := resource.ConvertFormToMetaValues(request, []resource.Metaor{bannerMetas[0], bannerMetas[1]}, "ECResource.")
+		resource.DecodeToResource(banner, &record, metaValues, context).Start()
+		if context.HasError() != c.hasError {
+			t.Errorf("select media %v, %v: expect has error %v, but got %v", c.image, c.gallery, c.hasError, context.GetErrors())
+		}
+	}
+
+	context := &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+	if err := banner.CallSave(&record, context); err != nil {
+		t.Fatal(err)
+	}
+
+	if url := bannerMetas[0].GetFormattedValuer()(&record, context); !strings.HasPrefix(url.(string), "/uploads/media_libraries/file/") {
+		t.Errorf("formatted value should be URL of the asset, but got %v", url)
+	}
+
+	usages, err := media.GetUsages(asset.ID, context)
+	if err != nil || len(usages) != 2 || usages[0].Table != "banners" || len(usages[0].PrimaryValues) != 1 {
+		t.Errorf("asset should be used by image and gallery of the banner, but got %+v, %v", usages, err)
+	}
+
+	if err := library.CallDelete(&media.MediaLibrary{Model: gorm.Model{ID: asset.ID}}, context); err == nil {
+		t.Errorf("referenced asset should not be deleted")
+	}
+
+	db.Delete(&record)
+	if err := library.CallDelete(&media.MediaLibrary{Model: gorm.Model{ID: asset.ID}}, context); err != nil {
+		t.Errorf("asset should be deleted after references removed, but got %v", err)
+	}
+}
@@ -0,0 +1,456 @@
+package media
+
+import (
+	"encoding/json"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+//	'UploadConfig' meta config for upload metas
+//	'Storage' is name of registered storage, blank means the default storage
+//	'MaxSize' is the max size of files in bytes, 0 means no limit
+//	'ContentTypes' is allowed MIME types, supports wildcard like "image/*", blank means any type
+//	'Variants' is image variants that will be generated when saving, e.g. {"thumb": {Width: 200, Height: 200, Mode: media.Fill}, "large": {Width: 1200}}
+type UploadConfig struct {
+	resource.MetaConfig
+	Storage      string
+	MaxSize      int64
+	ContentTypes []string
+	Variants     map[string]*Variant
+}
+
+func init() {
+	resource.RegisterMetaType(&resource.MetaType{
+		Name: "upload",
+		Configure: func(meta *resource.Meta) {
+			if _, ok := meta.Config.(*UploadConfig); !ok {
+				meta.Config = &UploadConfig{}
+			}
+
+			if meta.Resource != nil && !strings.Contains(meta.GetFieldName(), ".") {
+				registerFileCallbacks(meta)
+			}
+		},
+		Decode: func(meta *resource.Meta, record interface{}, metaValue *resource.MetaValue, context *TM_EC.Context) (interface{}, error) {
+			var header *multipart.FileHeader
+			switch value := metaValue.Value.(type) {
+			case []*multipart.FileHeader:
+				if len(value) > 0 {
+					header = value[0]
+				}
+			case *multipart.FileHeader:
+				header = value
+			case nil:
+				if metaValue.MetaValues == nil {
+					return nil, nil
+				}
+
+				if crop := metaValue.MetaValues.Get("Crop"); crop != nil {
+					return decodeCrop(meta, record, crop, context)
+				}
+
+				//	JSON descriptor submitted as object, only current file is accepted
+				descriptor := map[string]interface{}{}
+				for _, value := range metaValue.MetaValues.Values {
+					descriptor[value.Name] = value.Value
+				}
+				result, _ := json.Marshal(descriptor)
+				return keepCurrentFile(meta, record, string(result), context)
+			default:
+				str := utils.ToString(value)
+				if str == "" {
+					//	file input without file, keep existing file
+					if current := currentFile(meta, record, context); current != nil {
+						return current, nil
+					}
+					return nil, nil
+				}
+				return keepCurrentFile(meta, record, str, context)
+			}
+
+			if header == nil {
+				return nil, nil
+			}
+
+			upload, err := meta.Config.(*UploadConfig).newUpload(header)
+			if err == nil {
+				//	crop submitted with the file
+				if metaValue.MetaValues != nil && metaValue.MetaValues.Get("Crop") != nil {
+					upload.Crop, err = parseCrop(metaValue.MetaValues.Get("Crop"))
+				} else if current := currentFile(meta, record, context); current != nil && current.cropped {
+					upload.Crop = current.Crop
+				}
+			}
+			return upload, err
+		},
+		Format: func(meta *resource.Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{} {
+			if file, ok := reflect.Indirect(reflect.ValueOf(value)).Interface().(File); ok {
+				return file.URL()
+			}
+			return ""
+		},
+		Schema: func(meta *resource.Meta, schema *resource.MetaSchema, context *TM_EC.Context) {
+			config := meta.Config.(*UploadConfig)
+			if config.MaxSize > 0 {
+				schema.Attributes["max_size"] = config.MaxSize
+			}
+
+			if len(config.ContentTypes) > 0 {
+				schema.Attributes["content_types"] = config.ContentTypes
+			}
+
+			if len(config.Variants) > 0 {
+				schema.Attributes["variants"] = config.Variants
+			}
+		},
+	})
+}
+
+//	'newUpload' validate size and MIME type of the file, MIME type is detected from content, and fallback to the submitted one
+func (config *UploadConfig) newUpload(header *multipart.FileHeader) (*Upload, error) {
+	if config.MaxSize > 0 && header.Size > config.MaxSize {
+		return nil, i18n.NewError("ec.media.too_large", header.Filename, config.MaxSize)
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 512)
+	n, _ := file.Read(buffer)
+	contentType := http.DetectContentType(buffer[:n])
+	if submitted := header.Header.Get("Content-Type"); submitted != "" && strings.HasPrefix(contentType, "application/octet-stream") {
+		contentType = submitted
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+
+	if len(config.ContentTypes) > 0 {
+		var allowed bool
+		for _, pattern := range config.ContentTypes {
+			if pattern == contentType || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*"))) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return nil, i18n.NewError("ec.media.content_type_not_allowed", header.Filename, contentType)
+		}
+	}
+
+	if _, err := GetStorage(config.Storage); err != nil {
+		return nil, err
+	}
+
+	return &Upload{Header: header, ContentType: contentType, Storage: config.Storage, Variants: config.Variants}, nil
+}
+
+func currentFile(meta *resource.Meta, record interface{}, context *TM_EC.Context) *File {
+	if value := reflect.Indirect(reflect.ValueOf(meta.GetValuer()(record, context))); value.IsValid() {
+		if file, ok := value.Interface().(File); ok {
+			return &file
+		}
+	}
+	return nil
+}
+
+//	'keepCurrentFile' files could only be changed by uploading, submitted URL or JSON descriptor is accepted only if it is the current file, so files of other records can't be attached
+func keepCurrentFile(meta *resource.Meta, record interface{}, str string, context *TM_EC.Context) (interface{}, error) {
+	if current := currentFile(meta, record, context); current != nil && current.Path != "" {
+		if str == current.URL() {
+			return current, nil
+		}
+
+		var descriptor File
+		if descriptor.Scan(str) == nil && descriptor.Path == current.Path && descriptor.Storage == current.Storage {
+			return current, nil
+		}
+	}
+	return nil, i18n.NewError("ec.media.invalid_file", str)
+}
+
+//	'parseCrop' parse crop area from nested meta values like "Image.Crop.X", returns nil if its width or height is blank
+func parseCrop(metaValue *resource.MetaValue) (*Crop, error) {
+	crop := &Crop{}
+	if metaValue.MetaValues != nil {
+		for _, value := range metaValue.MetaValues.Values {
+			number, err := strconv.Atoi(strings.TrimSpace(utils.ToString(value.Value)))
+			if err != nil {
+				return nil, i18n.NewError("ec.media.invalid_crop", utils.ToString(value.Value), value.Name)
+			}
+
+			switch value.Name {
+			case "X":
+				crop.X = number
+			case "Y":
+				crop.Y = number
+			case "Width":
+				crop.Width = number
+			case "Height":
+				crop.Height = number
+			}
+		}
+	}
+
+	if crop.X < 0 || crop.Y < 0 || crop.Width < 0 || crop.Height < 0 {
+		return nil, i18n.NewError("ec.media.invalid_crop_area", *crop)
+	}
+
+	if crop.Width == 0 || crop.Height == 0 {
+		return nil, nil
+	}
+	return crop, nil
+}
+
+//	'decodeCrop' change crop area of current file, its variants will be regenerated when saving if the crop is changed
+func decodeCrop(meta *resource.Meta, record interface{}, metaValue *resource.MetaValue, context *TM_EC.Context) (interface{}, error) {
+	crop, err := parseCrop(metaValue)
+	if err != nil {
+		return nil, err
+	}
+
+	file := currentFile(meta, record, context)
+	if file == nil {
+		file = &File{}
+	}
+
+	if !reflect.DeepEqual(crop, file.Crop) && !file.IsPending() {
+		file.SetVariants(meta.Config.(*UploadConfig).Variants)
+	}
+
+	//	mark the crop is submitted, so file uploaded in the same request will use it
+	file.Crop, file.cropped = crop, true
+	return file, nil
+}
+
+//	'RegenerateVariants' regenerate image variants of upload metas for all records of the resource, it returns count of processed files
+//	It could be run as a command or a background job after variants are changed
+func RegenerateVariants(res resource.Resourcer, context *TM_EC.Context, metas ...*resource.Meta) (count int, err error) {
+	var (
+		db        = context.GetDB()
+		batchSize = 100
+	)
+
+	for offset := 0; ; offset += batchSize {
+		records := res.NewSlice()
+		if err = db.Order(db.NewScope(res.GetResource().Value).PrimaryKey()).Offset(offset).Limit(batchSize).Find(records).Error; err != nil {
+			return
+		}
+
+		reflectValue := reflect.Indirect(reflect.ValueOf(records))
+		for i := 0; i < reflectValue.Len(); i++ {
+			record := reflectValue.Index(i).Addr().Interface()
+			if reflectValue.Index(i).Kind() == reflect.Ptr {
+				record = reflectValue.Index(i).Interface()
+			}
+
+			for _, meta := range metas {
+				config, ok := meta.Config.(*UploadConfig)
+				if !ok {
+					continue
+				}
+
+				field, ok := db.NewScope(record).FieldByName(meta.GetFieldName())
+				if !ok || !field.Field.CanAddr() {
+					continue
+				}
+
+				file, ok := field.Field.Addr().Interface().(*File)
+				if !ok || file.Path == "" {
+					continue
+				}
+
+				original := *file
+				file.SetVariants(config.Variants)
+				if err = file.Store(""); err != nil {
+					return
+				}
+
+				if err = db.Model(record).UpdateColumn(field.DBName, file).Error; err != nil {
+					return
+				}
+				deleteObsoleteFiles(original, *file)
+				count++
+			}
+		}
+
+		if reflectValue.Len() < batchSize {
+			return
+		}
+	}
+}
+
+//	'RegisterCallbacks' register gorm callbacks to store pending uploads before creating, updating records
+//	Stored files are deleted if the record failed to save, or the transaction started by 'resource.Transaction' is rollbacked
+func RegisterCallbacks(db *gorm.DB) {
+	db.Callback().Create().Before("gorm:create").Register("media:store", storeFiles)
+	db.Callback().Update().Before("gorm:update").Register("media:store", storeFiles)
+	db.Callback().Create().After("gorm:commit_or_rollback_transaction").Register("media:delete_unsaved", deleteUnsavedFiles)
+	db.Callback().Update().After("gorm:commit_or_rollback_transaction").Register("media:delete_unsaved", deleteUnsavedFiles)
+}
+
+//	'storedFiles' files stored for the record, they are deleted if it isn't saved
+type storedFiles struct {
+	files []storedUpload
+}
+
+type storedUpload struct {
+	original File
+	stored   File
+}
+
+func (files *storedFiles) delete(*TM_EC.Context) {
+	for _, file := range files.files {
+		//	files of the original are kept, e.g. variants regenerated in place
+		deleteObsoleteFiles(file.stored, file.original)
+	}
+}
+
+func storeFiles(scope *gorm.Scope) {
+	if scope.HasError() {
+		return
+	}
+
+	var stored *storedFiles
+	for _, field := range scope.Fields() {
+		if !field.Field.CanAddr() {
+			continue
+		}
+
+		var file *File
+		switch value := field.Field.Addr().Interface().(type) {
+		case *File:
+			file = value
+		case **File:
+			file = *value
+		}
+
+		if file != nil && file.IsPending() {
+			original := *file
+			if err := file.Store(scope.TableName() + "/" + field.DBName); err != nil {
+				scope.Err(err)
+				return
+			}
+
+			if stored == nil {
+				stored = &storedFiles{}
+				scope.InstanceSet("media:stored_files", stored)
+				resource.AfterRollback(&TM_EC.Context{Config: &TM_EC.Config{DB: scope.DB()}}, stored.delete)
+			}
+			stored.files = append(stored.files, storedUpload{original: original, stored: *file})
+		}
+	}
+}
+
+func deleteUnsavedFiles(scope *gorm.Scope) {
+	if value, ok := scope.InstanceGet("media:stored_files"); ok && scope.HasError() {
+		value.(*storedFiles).delete(nil)
+	}
+}
+
+//	'registerFileCallbacks' register callbacks to delete stored files of the meta after they are replaced, removed, or their record is deleted
+//	Files of soft deleted records are kept, so they could be restored
+func registerFileCallbacks(meta *resource.Meta) {
+	var (
+		res        = meta.Resource.GetResource()
+		settingKey = "media:stored_file:" + meta.Name
+	)
+
+	res.AddCallback(resource.CallbackBeforeSave, "media:load_stored_file:"+meta.Name, func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		var (
+			primaryValue = res.GetPrimaryValue(record)
+			stored       *File
+		)
+
+		if primaryValue != "" && !resource.IsNewRecord(context) {
+			primaryQuerySQL, primaryParams, err := res.ToPrimaryQueryParams(primaryValue, context)
+			if err != nil {
+				return err
+			}
+
+			original := res.NewStruct()
+			if err := context.GetDB().New().Where(primaryQuerySQL, primaryParams...).First(original).Error; err == nil {
+				stored = storedFile(original, meta, context)
+			}
+		}
+
+		context.SetDB(context.GetDB().Set(settingKey, stored))
+		return nil
+	})
+
+	res.AddCallback(resource.CallbackAfterSave, "media:delete_replaced_files:"+meta.Name, func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		if value, ok := context.GetDB().Get(settingKey); ok {
+			if stored, ok := value.(*File); ok && stored != nil {
+				var current File
+				if file := storedFile(record, meta, context); file != nil {
+					current = *file
+				}
+
+				resource.AfterCommit(context, func(*TM_EC.Context) {
+					deleteObsoleteFiles(*stored, current)
+				})
+			}
+		}
+		return nil
+	})
+
+	res.AddCallback(resource.CallbackAfterDelete, "media:delete_files:"+meta.Name, func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		//	check soft delete with the scope deleted the record, after commit callbacks get the context started the transaction
+		scope := context.GetDB().NewScope(record)
+		if _, ok := scope.FieldByName("DeletedAt"); ok && !scope.Search.Unscoped {
+			return nil
+		}
+
+		if stored := storedFile(record, meta, context); stored != nil {
+			resource.AfterCommit(context, func(*TM_EC.Context) {
+				deleteObsoleteFiles(*stored, File{})
+			})
+		}
+		return nil
+	})
+}
+
+func storedFile(record interface{}, meta *resource.Meta, context *TM_EC.Context) *File {
+	if file := currentFile(meta, record, context); file != nil && file.Path != "" && !file.IsPending() {
+		return file
+	}
+	return nil
+}
+
+//	'deleteObsoleteFiles' delete stored file and variants of original that are not used by current anymore
+func deleteObsoleteFiles(original File, current File) {
+	storage, err := GetStorage(original.Storage)
+	if err != nil {
+		return
+	}
+
+	used := map[string]bool{}
+	if current.Storage == original.Storage {
+		for _, filePath := range current.paths() {
+			used[filePath] = true
+		}
+	}
+
+	for _, filePath := range original.paths() {
+		if !used[filePath] {
+			storage.Delete(filePath)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package media_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/media"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type Product struct {
+	gorm.Model
+	Name  string
+	Image media.File
+}
+
+var pngContent = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 100)...)
+
+func newUploadRequest(fileName string, content []byte, fields ...string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("ECResource.Name", "product")
+	for i := 0; i+1 < len(fields); i += 2 {
+		writer.WriteField(fields[i], fields[i+1])
+	}
+	part, _ := writer.CreateFormFile("ECResource.Image", fileName)
+	part.Write(content)
+	writer.Close()
+
+	request, _ := http.NewRequest("POST", "/products", &body)
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	request.ParseMultipartForm(1 << 20)
+	return request
+}
+
+func TestUpload(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "media")
+	defer os.RemoveAll(dir)
+	media.RegisterStorage("test", media.FileSystem{Dir: dir, URLPrefix: "/uploads"})
+
+	db := utils.TestDB()
+	db.DropTableIfExists(&Product{})
+	db.AutoMigrate(&Product{})
+	media.RegisterCallbacks(db)
+
+	cases := []struct {
+		fileName string
+		content  []byte
+		config   *media.UploadConfig
+		hasError bool
+	}{
+		{"logo.png", pngContent, &media.UploadConfig{Storage: "test", MaxSize: 1024, ContentTypes: []string{"image/*"}}, false},
+		{"logo.png", pngContent, &media.UploadConfig{Storage: "test", MaxSize: 10}, true},
+		{"notes.txt", []byte("hello"), &media.UploadConfig{Storage: "test", ContentTypes: []string{"image/png", "image/jpeg"}}, true},
+	}
+
+	for _, c := range cases {
+		var (
+			res     = resource.New(&Product{})
+			name    = &resource.Meta{Name: "Name", Resource: res}
+			image   = &resource.Meta{Name: "Image", Resource: res, Config: c.config}
+			context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: newUploadRequest(c.fileName, c.content)}
+			product Product
+		)
+
+		for _, meta := range []*resource.Meta{name, image} {
+			meta.PerInitialize()
+			meta.Initialize()
+		}
+
+		if image.Type != "upload" {
+			t.Fatalf("file field should use upload meta type, but got %v", image.Type)
+		}
+
+		metaValues, _ := resource.ConvertFormToMetaValues(context.Request, []resource.Metaor{name, image}, "ECResource.")
+		resource.DecodeToResource(res, &product, metaValues, context).Start()
+		if context.HasError() != c.hasError {
+			t.Errorf("upload %v with config %+v: expect has error %v, but got %v", c.fileName, c.config, c.hasError, context.GetErrors())
+			continue
+		}
+
+		if c.hasError {
+			continue
+		}
+
+		if err := res.CallSave(&product, context); err != nil {
+			t.Fatal(err)
+		}
+
+		var saved Product
+		db.First(&saved, product.ID)
+		if saved.Image.ContentType != "image/png" || saved.Image.FileName != "logo.png" || saved.Image.Size != int64(len(pngContent)) {
+			t.Errorf("file descriptor is not saved correctly, got %+v", saved.Image)
+		}
+
+		if content, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(saved.Image.Path))); err != nil || !bytes.Equal(content, pngContent) {
+			t.Errorf("file is not stored correctly, got %v", err)
+		}
+
+		if url := image.GetFormattedValuer()(&saved, context); !strings.HasPrefix(url.(string), "/uploads/products/image/") {
+			t.Errorf("formatted value should be file's URL, but got %v", url)
+		}
+	}
+}
+
+func newImage(width, height int) []byte {
+	var (
+		buffer bytes.Buffer
+		img    = image.NewRGBA(image.Rect(0, 0, width, height))
+	)
+
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	png.Encode(&buffer, img)
+	return buffer.Bytes()
+}
+
+func imageSize(t *testing.T, fileName string) (int, int) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		t.Fatalf("variant %v is not generated, got %v", fileName, err)
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("variant %v is not a valid image, got %v", fileName, err)
+	}
+	return config.Width, config.Height
+}
+
+func TestImageVariants(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "media")
+	defer os.RemoveAll(dir)
+	media.RegisterStorage("test", media.FileSystem{Dir: dir, URLPrefix: "/uploads"})
+
+	db := utils.TestDB()
+	db.DropTableIfExists(&Product{})
+	db.AutoMigrate(&Product{})
+	media.RegisterCallbacks(db)
+
+	var (
+		res    = resource.New(&Product{})
+		config = &media.UploadConfig{Storage: "test", Variants: map[string]*media.Variant{
+			"thumb": {Width: 100, Height: 100, Mode: media.Fill},
+			"large": {Width: 200, Format: "jpeg"},
+		}}
+		name    = &resource.Meta{Name: "Name", Resource: res}
+		meta    = &resource.Meta{Name: "Image", Resource: res, Config: config}
+		product Product
+	)
+
+	for _, meta := range []*resource.Meta{name, meta} {
+		meta.PerInitialize()
+		meta.Initialize()
+	}
+
+	save := func(request *http.Request) {
+		context := &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}
+		metaValues, _ := resource.ConvertFormToMetaValues(request, []resource.Metaor{name, meta}, "ECResource.")
+		resource.DecodeToResource(res, &product, metaValues, context).Start()
+		if context.HasError() {
+			t.Fatalf("failed to decode product, got %v", context.GetErrors())
+		}
+
+		if err := res.CallSave(&product, context); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkVariants := func(sizes map[string][2]int) {
+		var saved Product
+		db.First(&saved, product.ID)
+		for variant, size := range sizes {
+			filePath := saved.Image.Variants[variant]
+			if variant == "large" && !strings.HasSuffix(filePath, ".large.jpg") {
+				t.Errorf("large variant should be converted to jpeg, but got %v", filePath)
+			}
+
+			if width, height := imageSize(t, filepath.Join(dir, filepath.FromSlash(filePath))); width != size[0] || height != size[1] {
+				t.Errorf("%v variant should be %vx%v, but got %vx%v", variant, size[0], size[1], width, height)
+			}
+
+			if url := saved.Image.URL(variant); url != "/uploads/"+filePath {
+				t.Errorf("URL of %v variant should be /uploads/%v, but got %v", variant, filePath, url)
+			}
+		}
+	}
+
+	save(newUploadRequest("photo.png", newImage(400, 200)))
+	checkVariants(map[string][2]int{"thumb": {100, 100}, "large": {200, 100}})
+
+	//	change crop, variants should be regenerated
+	save(newUploadRequest("", nil, "ECResource.Image.Crop.X", "0", "ECResource.Image.Crop.Y", "0", "ECResource.Image.Crop.Width", "100", "ECResource.Image.Crop.Height", "200"))
+	checkVariants(map[string][2]int{"thumb": {100, 100}, "large": {200, 400}})
+
+	//	upload with crop
+	save(newUploadRequest("photo.png", newImage(300, 300), "ECResource.Image.Crop.X", "50", "ECResource.Image.Crop.Y", "0", "ECResource.Image.Crop.Width", "200", "ECResource.Image.Crop.Height", "100"))
+	checkVariants(map[string][2]int{"thumb": {100, 100}, "large": {200, 100}})
+
+	//	change variants and regenerate them for all records
+	config.Variants["large"] = &media.Variant{Width: 50, Format: "jpeg"}
+	context := &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+	if count, err := media.RegenerateVariants(res, context, meta); err != nil || count != 1 {
+		t.Errorf("should regenerate variants of 1 file, but got %v, %v", count, err)
+	}
+	checkVariants(map[string][2]int{"thumb": {100, 100}, "large": {50, 25}})
+}
+
+func TestUploadedFiles(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "media")
+	defer os.RemoveAll(dir)
+	media.RegisterStorage("test", media.FileSystem{Dir: dir, URLPrefix: "/uploads"})
+
+	db := utils.TestDB()
+	db.DropTableIfExists(&Product{})
+	db.AutoMigrate(&Product{})
+	media.RegisterCallbacks(db)
+
+	var (
+		res   = resource.New(&Product{})
+		name  = &resource.Meta{Name: "Name", Resource: res}
+		image = &resource.Meta{Name: "Image", Resource: res, Config: &media.UploadConfig{Storage: "test"}}
+	)
+
+	for _, meta := range []*resource.Meta{name, image} {
+		meta.PerInitialize()
+		meta.Initialize()
+	}
+
+	save := func(product *Product, request *http.Request) error {
+		context := &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}
+		metaValues, _ := resource.ConvertFormToMetaValues(request, []resource.Metaor{name, image}, "ECResource.")
+		resource.DecodeToResource(res, product, metaValues, context).Start()
+		if context.HasError() {
+			return context.Errors
+		}
+		return res.CallSave(product, context)
+	}
+
+	formRequest := func(values url.Values) *http.Request {
+		return &http.Request{Method: "POST", Form: values}
+	}
+
+	exists := func(file media.File) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file.Path)))
+		return err == nil
+	}
+
+	var product, other Product
+	if err := save(&product, newUploadRequest("a.png", pngContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := save(&other, newUploadRequest("b.png", pngContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	descriptor, _ := json.Marshal(other.Image)
+	cases := []struct {
+		values url.Values
+		valid  bool
+	}{
+		{url.Values{"ECResource.Image": {string(descriptor)}}, false},
+		{url.Values{"ECResource.Image.Path": {other.Image.Path}, "ECResource.Image.Storage": {"test"}}, false},
+		{url.Values{"ECResource.Image": {other.Image.URL()}}, false},
+		{url.Values{"ECResource.Image": {product.Image.URL()}}, true},
+		{url.Values{"ECResource.Image": {""}}, true},
+	}
+
+	for _, c := range cases {
+		var found Product
+		db.First(&found, product.ID)
+		err := save(&found, formRequest(c.values))
+		if (err == nil) != c.valid || found.Image.Path != product.Image.Path && c.valid {
+			t.Errorf("submit %v: expect valid %v, but got %v, %+v", c.values, c.valid, err, found.Image)
+		}
+	}
+
+	//	replaced file is deleted
+	original := product.Image
+	if err := save(&product, newUploadRequest("c.png", pngContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists(original) || !exists(product.Image) {
+		t.Errorf("replaced file should be deleted, and new file should be stored")
+	}
+
+	//	stored file is deleted if the transaction is rollbacked
+	var rollbacked Product
+	resource.Transaction(&TM_EC.Context{Config: &TM_EC.Config{DB: db}}, func(context *TM_EC.Context) error {
+		context.Request = newUploadRequest("d.png", pngContent)
+		metaValues, _ := resource.ConvertFormToMetaValues(context.Request, []resource.Metaor{name, image}, "ECResource.")
+		resource.DecodeToResource(res, &rollbacked, metaValues, context).Start()
+		if err := res.CallSave(&rollbacked, context); err != nil || rollbacked.Image.Path == "" {
+			t.Errorf("file should be stored, but got %v", err)
+		}
+		return errors.New("rollback")
+	})
+
+	if exists(rollbacked.Image) {
+		t.Errorf("file stored in rollbacked transaction should be deleted")
+	}
+
+	//	files of soft deleted records are kept, files of deleted records are deleted
+	context := &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+	if err := res.CallDelete(&product, context); err != nil || !exists(product.Image) {
+		t.Errorf("file of soft deleted record should be kept, but got %v", err)
+	}
+
+	err := resource.Transaction(context, func(context *TM_EC.Context) error {
+		context.SetDB(context.GetDB().Unscoped())
+		return res.CallDelete(&other, context)
+	})
+	if err != nil || exists(other.Image) {
+		t.Errorf("file of deleted record should be deleted, but got %v", err)
+	}
+}
+
+func TestImagePixelsLimit(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "media")
+	defer os.RemoveAll(dir)
+	media.RegisterStorage("test", media.FileSystem{Dir: dir, URLPrefix: "/uploads"})
+
+	db := utils.TestDB()
+	db.DropTableIfExists(&Product{})
+	db.AutoMigrate(&Product{})
+	media.RegisterCallbacks(db)
+
+	//	a small PNG that declares 50000x50000 pixels
+	bomb := newImage(1, 1)
+	binary.BigEndian.PutUint32(bomb[16:], 50000)
+	binary.BigEndian.PutUint32(bomb[20:], 50000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+
+	var (
+		res   = resource.New(&Product{})
+		image = &resource.Meta{Name: "Image", Resource: res, Config: &media.UploadConfig{Storage: "test", Variants: map[string]*media.Variant{"thumb": {Width: 100}}}}
+	)
+	image.PerInitialize()
+	image.Initialize()
+
+	cases := []struct {
+		content []byte
+		valid   bool
+	}{
+		{newImage(20, 10), true},
+		{bomb, false},
+	}
+
+	for _, c := range cases {
+		var (
+			product Product
+			context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: newUploadRequest("photo.png", c.content)}
+		)
+
+		metaValues, _ := resource.ConvertFormToMetaValues(context.Request, []resource.Metaor{image}, "ECResource.")
+		resource.DecodeToResource(res, &product, metaValues, context).Start()
+		if err := res.CallSave(&product, context); (err == nil) != c.valid {
+			t.Errorf("save image of %v bytes: expect valid %v, but got %v", len(c.content), c.valid, err)
+		}
+	}
+}
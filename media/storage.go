@@ -0,0 +1,107 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//	'Storage' storage backend of uploaded files, e.g. local file system, S3 compatible object storages
+type Storage interface {
+	Put(path string, reader io.Reader) error
+	Get(path string) (io.ReadCloser, error)
+	Delete(path string) error
+	URL(path string) string
+}
+
+//	'DefaultStorageName' name of the storage used when upload config doesn't specify one
+const DefaultStorageName = "local"
+
+var (
+	storages      = map[string]Storage{DefaultStorageName: &FileSystem{Dir: "public/system", URLPrefix: "/system"}}
+	storagesMutex sync.RWMutex
+)
+
+//	'RegisterStorage' register storage with name, registered storage with same name will be replaced
+func RegisterStorage(name string, storage Storage) {
+	storagesMutex.Lock()
+	storages[name] = storage
+	storagesMutex.Unlock()
+}
+
+//	'GetStorage' get registered storage by name, blank name means the default storage
+func GetStorage(name string) (Storage, error) {
+	if name == "" {
+		name = DefaultStorageName
+	}
+
+	storagesMutex.RLock()
+	defer storagesMutex.RUnlock()
+	if storage, ok := storages[name]; ok {
+		return storage, nil
+	}
+	return nil, fmt.Errorf("media: storage %v is not registered", name)
+}
+
+//	'FileSystem' storage that saves files to local directory 'Dir', files are served under 'URLPrefix'
+type FileSystem struct {
+	Dir       string
+	URLPrefix string
+}
+
+func (fileSystem FileSystem) fullPath(path string) (string, error) {
+	fullPath := filepath.Join(fileSystem.Dir, filepath.FromSlash(path))
+	if !strings.HasPrefix(fullPath, filepath.Clean(fileSystem.Dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("media: invalid path %v", path)
+	}
+	return fullPath, nil
+}
+
+//	'Put' save content of reader to path
+func (fileSystem FileSystem) Put(path string, reader io.Reader) error {
+	fullPath, err := fileSystem.fullPath(path)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(dst, reader); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+//	'Get' open file of path
+func (fileSystem FileSystem) Get(path string) (io.ReadCloser, error) {
+	fullPath, err := fileSystem.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+//	'Delete' delete file of path
+func (fileSystem FileSystem) Delete(path string) error {
+	fullPath, err := fileSystem.fullPath(path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fullPath)
+}
+
+//	'URL' URL of file path
+func (fileSystem FileSystem) URL(path string) string {
+	return strings.TrimSuffix(fileSystem.URLPrefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//	'Entries' list entries with the status, blank status means all entries, newest entries are returned first
+func (outbox *Outbox) Entries(status string, limit, offset int) (entries []Entry, err error) {
+	db := outbox.Config.DB.Order("id DESC")
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	if limit > 0 {
+		db = db.Limit(limit).Offset(offset)
+	}
+
+	err = db.Find(&entries).Error
+	return
+}
+
+//	'Replay' reset entry to pending, it will be delivered again by the relay
+func (outbox *Outbox) Replay(id uint) error {
+	var entry Entry
+	if err := outbox.Config.DB.First(&entry, id).Error; err != nil {
+		return err
+	}
+
+	return outbox.Config.DB.Model(&entry).Updates(map[string]interface{}{
+		"status":          StatusPending,
+		"attempts":        0,
+		"next_attempt_at": time.Now(),
+		"delivered_at":    nil,
+	}).Error
+}
+
+//	'Handler' admin API to inspect and replay entries, mount it with a prefix, e.g.
+//		mux.Handle("/admin/outbox/", http.StripPrefix("/admin/outbox", outbox.Handler()))
+//	Routes:
+//		GET  /entries?status=failed&limit=20&offset=0
+//		GET  /entries/:id
+//		POST /entries/:id/replay
+//	Requests are forbidden unless 'Config.Authorize' returns true for them, e.g.
+//		outbox.Config.Authorize = func(request *http.Request) bool { return isAdmin(request) }
+func (outbox *Outbox) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		if outbox.Config.Authorize == nil || !outbox.Config.Authorize(request) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		paths := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+		if len(paths) == 0 || paths[0] != "entries" {
+			http.NotFound(w, request)
+			return
+		}
+
+		switch {
+		case len(paths) == 1 && request.Method == "GET":
+			query := request.URL.Query()
+			limit, _ := strconv.Atoi(query.Get("limit"))
+			offset, _ := strconv.Atoi(query.Get("offset"))
+			if limit <= 0 {
+				limit = 20
+			}
+
+			entries, err := outbox.Entries(query.Get("status"), limit, offset)
+			writeJSON(w, entries, err)
+		case len(paths) == 2 && request.Method == "GET":
+			var entry Entry
+			id, _ := strconv.ParseUint(paths[1], 10, 64)
+			if outbox.Config.DB.First(&entry, uint(id)).RecordNotFound() {
+				http.NotFound(w, request)
+				return
+			}
+			writeJSON(w, entry, nil)
+		case len(paths) == 3 && paths[2] == "replay" && request.Method == "POST":
+			id, _ := strconv.ParseUint(paths[1], 10, 64)
+			writeJSON(w, map[string]bool{"replayed": true}, outbox.Replay(uint(id)))
+		default:
+			http.NotFound(w, request)
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(value)
+}
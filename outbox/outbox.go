@@ -0,0 +1,306 @@
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+)
+
+const (
+	//	'StatusPending' entry is waiting to be delivered
+	StatusPending = "pending"
+	//	'StatusDelivered' entry is delivered to all sinks
+	StatusDelivered = "delivered"
+	//	'StatusFailed' entry is failed after max attempts, it could be replayed
+	StatusFailed = "failed"
+)
+
+//	'Entry' an outbox entry, it is written in the same transaction with the resource change
+//	'Key' is an unique de-duplication key, sinks should pass it to consumers, so they could ignore entries delivered more than once
+//	'NextAttemptAt' is when the entry will be delivered or retried, it is moved forward while the entry is delivering, so it won't be delivered by other relays
+type Entry struct {
+	gorm.Model
+	Key           string `sql:"size:191" gorm:"unique_index"`
+	Resource      string
+	Event         string
+	PrimaryKey    string
+	Changes       string
+	Payload       string `sql:"type:text"`
+	UserName      string
+	Status        string `gorm:"index"`
+	Attempts      int
+	LastError     string     `sql:"type:text"`
+	NextAttemptAt *time.Time `gorm:"index"`
+	DeliveredAt   *time.Time
+}
+
+//	'GetChanges' get names of changed metas
+func (entry Entry) GetChanges() []string {
+	if entry.Changes == "" {
+		return nil
+	}
+	return strings.Split(entry.Changes, ",")
+}
+
+//	'GetPayload' decode payload of the entry
+func (entry Entry) GetPayload() (payload map[string]interface{}) {
+	json.Unmarshal([]byte(entry.Payload), &payload)
+	return
+}
+
+//	'Config' outbox config
+//	Failed entries are retried after 'Backoff', it doubles after every attempt up to 'MaxBackoff'
+//	'LeaseTimeout' is the max duration of delivering an entry, if the relay crashed when delivering, the entry will be delivered again after it
+//	'Authorize' decides whether a request could access the admin API of 'Handler', all requests are forbidden if it is nil
+type Config struct {
+	DB           *gorm.DB
+	Sinks        []Sink
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	LeaseTimeout time.Duration
+	Authorize    func(*http.Request) bool
+}
+
+//	'Outbox' transactional outbox, it writes entries when registered resources changed, and relay them to sinks
+type Outbox struct {
+	Config *Config
+	stop   chan struct{}
+	done   chan struct{}
+	mutex  sync.Mutex
+}
+
+//	'New' initialize outbox with config, and migrate its table
+func New(config *Config) *Outbox {
+	if config.PollInterval == 0 {
+		config.PollInterval = time.Second
+	}
+
+	if config.BatchSize == 0 {
+		config.BatchSize = 100
+	}
+
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 10
+	}
+
+	if config.Backoff == 0 {
+		config.Backoff = 5 * time.Second
+	}
+
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = time.Hour
+	}
+
+	if config.LeaseTimeout == 0 {
+		config.LeaseTimeout = time.Minute
+	}
+
+	config.DB.AutoMigrate(&Entry{})
+	return &Outbox{Config: config}
+}
+
+//	'AddSink' add a sink that entries will be delivered to
+func (outbox *Outbox) AddSink(sink Sink) {
+	outbox.Config.Sinks = append(outbox.Config.Sinks, sink)
+}
+
+//	'Register' write outbox entries in the save, delete transaction of the resource, payload is built from metas' formatted valuer
+//	if no metas passed, will use metas returned by 'resource.DefaultMetas'
+func (outbox *Outbox) Register(res resource.Resourcer, metas ...resource.Metaor) {
+	if len(metas) == 0 {
+		metas = resource.DefaultMetas(res)
+	}
+
+	name := res.GetResource().Name
+	res.GetResource().AddCallback(resource.CallbackAfterSave, "outbox:write", func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		if resource.IsNewRecord(context) {
+			return outbox.Write(name, string(resource.EventCreated), record, metas, metaValues, context)
+		}
+		return outbox.Write(name, string(resource.EventUpdated), record, metas, metaValues, context)
+	})
+	res.GetResource().AddCallback(resource.CallbackAfterDelete, "outbox:write", func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		return outbox.Write(name, string(resource.EventDeleted), record, metas, metaValues, context)
+	})
+}
+
+//	'Write' write an outbox entry with context's db, so it will be committed or rollbacked with the transaction of context
+func (outbox *Outbox) Write(resourceName, event string, record interface{}, metas []resource.Metaor, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+	var (
+		db      = context.GetDB()
+		now     = time.Now()
+		changes []string
+		data    = map[string]interface{}{}
+	)
+
+	for _, meta := range metas {
+		if valuer := meta.GetFormattedValuer(); valuer != nil {
+			data[meta.GetName()] = valuer(record, context)
+		}
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if metaValues != nil {
+		changes = metaValues.Changes()
+	}
+
+	entry := Entry{
+		Resource:      resourceName,
+		Event:         event,
+		PrimaryKey:    resource.PrimaryValueOf(record),
+		Changes:       strings.Join(changes, ","),
+		Payload:       string(payload),
+		Status:        StatusPending,
+		NextAttemptAt: &now,
+	}
+
+	//	random token keeps keys of events happened at the same time unique
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+	entry.Key = fmt.Sprintf("%v:%v:%v:%v", resourceName, entry.PrimaryKey, event, hex.EncodeToString(token))
+
+	if context.CurrentUser != nil {
+		entry.UserName = context.CurrentUser.DisplayName()
+	}
+
+	return db.Create(&entry).Error
+}
+
+//	'Start' start the relay goroutine, it polls pending entries every 'PollInterval' and deliver them to sinks
+func (outbox *Outbox) Start() {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	if outbox.stop != nil {
+		return
+	}
+
+	outbox.stop = make(chan struct{})
+	outbox.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(outbox.Config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			outbox.Relay()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(outbox.stop, outbox.done)
+}
+
+//	'Stop' stop the relay goroutine, it will wait the delivering batch finished
+func (outbox *Outbox) Stop() {
+	outbox.mutex.Lock()
+	defer outbox.mutex.Unlock()
+
+	if outbox.stop == nil {
+		return
+	}
+
+	close(outbox.stop)
+	<-outbox.done
+	outbox.stop, outbox.done = nil, nil
+}
+
+//	'Relay' deliver a batch of pending entries that reached their next attempt time to all sinks, and return count of delivered entries
+//	An entry is marked as delivered only after all sinks accepted it, so sinks could receive an entry more than once
+//	Every entry is claimed before delivered, so several relays could run at the same time
+func (outbox *Outbox) Relay() (int, error) {
+	var (
+		entries   []Entry
+		delivered int
+	)
+
+	if err := outbox.Config.DB.Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", StatusPending, time.Now()).Order("id").Limit(outbox.Config.BatchSize).Find(&entries).Error; err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if outbox.claim(&entry) && outbox.deliver(&entry) == nil {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+//	'claim' lease the entry by moving its next attempt time after 'LeaseTimeout', it may be claimed by other relays
+func (outbox *Outbox) claim(entry *Entry) bool {
+	var (
+		now   = time.Now()
+		lease = now.Add(outbox.Config.LeaseTimeout)
+	)
+
+	if outbox.Config.DB.Model(&Entry{}).Where("id = ? AND status = ? AND attempts = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", entry.ID, StatusPending, entry.Attempts, now).UpdateColumn("next_attempt_at", lease).RowsAffected == 0 {
+		return false
+	}
+
+	entry.NextAttemptAt = &lease
+	return true
+}
+
+func (outbox *Outbox) backoff(attempts int) time.Duration {
+	duration := outbox.Config.Backoff
+	for i := 1; i < attempts; i++ {
+		duration *= 2
+		if duration >= outbox.Config.MaxBackoff {
+			return outbox.Config.MaxBackoff
+		}
+	}
+	return duration
+}
+
+func (outbox *Outbox) deliver(entry *Entry) error {
+	var errs TM_EC.Errors
+	for _, sink := range outbox.Config.Sinks {
+		if err := sink.Deliver(entry); err != nil {
+			errs.AddError(fmt.Errorf("%v: %v", sink.Name(), err))
+		}
+	}
+
+	updates := map[string]interface{}{"attempts": entry.Attempts + 1}
+	if errs.HasError() {
+		updates["last_error"] = errs.Error()
+		if entry.Attempts+1 >= outbox.Config.MaxAttempts {
+			updates["status"] = StatusFailed
+		} else {
+			updates["next_attempt_at"] = time.Now().Add(outbox.backoff(entry.Attempts + 1))
+		}
+	} else {
+		updates["status"] = StatusDelivered
+		updates["delivered_at"] = time.Now()
+		updates["last_error"] = ""
+	}
+
+	if err := outbox.Config.DB.Model(entry).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	if errs.HasError() {
+		return errs
+	}
+	return nil
+}
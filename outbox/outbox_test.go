@@ -0,0 +1,254 @@
+package outbox_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/outbox"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type Order struct {
+	gorm.Model
+	Code string
+}
+
+type sink struct {
+	err  error
+	keys []string
+}
+
+func (sink *sink) Name() string { return "test" }
+
+func (sink *sink) Deliver(entry *outbox.Entry) error {
+	sink.keys = append(sink.keys, entry.Key)
+	return sink.err
+}
+
+func newOutbox(t *testing.T) (*outbox.Outbox, *resource.Resource, *TM_EC.Context) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Order{}, &outbox.Entry{})
+	db.AutoMigrate(&Order{})
+
+	box := outbox.New(&outbox.Config{DB: db, MaxAttempts: 2, Backoff: time.Millisecond})
+	res := resource.New(&Order{})
+	box.Register(res)
+	return box, res, &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+}
+
+func entryEvents(box *outbox.Outbox) (events []string) {
+	entries, _ := box.Entries("", 0, 0)
+	for i := len(entries) - 1; i >= 0; i-- {
+		events = append(events, fmt.Sprintf("%v %v", entries[i].Event, entries[i].GetPayload()["Code"]))
+	}
+	return
+}
+
+func TestWriteInTransaction(t *testing.T) {
+	box, res, context := newOutbox(t)
+
+	order := Order{Code: "O001"}
+	if err := res.CallSave(&order, context); err != nil {
+		t.Fatal(err)
+	}
+
+	err := resource.Transaction(context, func(context *TM_EC.Context) error {
+		if err := res.CallSave(&Order{Code: "O002"}, context); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Errorf("transaction should be rollbacked")
+	}
+
+	order.Code = "O003"
+	if err := res.CallSave(&order, context); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.CallDelete(&order, context); err != nil {
+		t.Fatal(err)
+	}
+
+	if events := entryEvents(box); !reflect.DeepEqual(events, []string{"created O001", "updated O003", "deleted O003"}) {
+		t.Errorf("entries should be written with committed changes only, but got %v", events)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := box.Write(res.Name, string(resource.EventUpdated), &order, nil, nil, context); err != nil {
+			t.Errorf("entries of same event written at the same time should have unique keys, but got %v", err)
+		}
+	}
+
+	entries, _ := box.Entries("", 0, 0)
+	duplicated := outbox.Entry{Key: entries[0].Key, Status: outbox.StatusPending}
+	if err := box.Config.DB.Create(&duplicated).Error; err == nil {
+		t.Errorf("entry with duplicated key %v should not be created", duplicated.Key)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	box, res, context := newOutbox(t)
+
+	var (
+		good = &sink{}
+		bad  = &sink{err: errors.New("unavailable")}
+	)
+	box.AddSink(good)
+	box.AddSink(bad)
+
+	order := Order{Code: "O001"}
+	res.CallSave(&order, context)
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if delivered, err := box.Relay(); delivered != 0 || err != nil {
+			t.Errorf("entry shouldn't be delivered if any sink failed, but got %v, %v", delivered, err)
+		}
+	}
+
+	entries, _ := box.Entries(outbox.StatusFailed, 0, 0)
+	if len(entries) != 1 || entries[0].Attempts != 2 || entries[0].LastError == "" {
+		t.Fatalf("entry should be failed after max attempts, but got %+v", entries)
+	}
+
+	if delivered, _ := box.Relay(); delivered != 0 || len(bad.keys) != 2 {
+		t.Errorf("failed entry shouldn't be delivered again")
+	}
+
+	bad.err = nil
+	if err := box.Replay(entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if delivered, err := box.Relay(); delivered != 1 || err != nil {
+		t.Errorf("replayed entry should be delivered, but got %v, %v", delivered, err)
+	}
+
+	if len(good.keys) != 3 || good.keys[2] != entries[0].Key {
+		t.Errorf("entry should be delivered with its key %v, but got %v", entries[0].Key, good.keys)
+	}
+
+	entries, _ = box.Entries(outbox.StatusDelivered, 0, 0)
+	if len(entries) != 1 || entries[0].DeliveredAt == nil || entries[0].Attempts != 1 {
+		t.Errorf("entry should be delivered, but got %+v", entries)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	box, res, context := newOutbox(t)
+	box.AddSink(&sink{err: errors.New("unavailable")})
+	res.CallSave(&Order{Code: "O001"}, context)
+	box.Relay()
+	time.Sleep(10 * time.Millisecond)
+	box.Relay()
+
+	server := httptest.NewServer(box.Handler())
+	defer server.Close()
+
+	entries, _ := box.Entries("", 0, 0)
+	entryURL := fmt.Sprintf("%v/entries/%v", server.URL, entries[0].ID)
+
+	for _, url := range []string{server.URL + "/entries", entryURL} {
+		if response, err := http.Get(url); err != nil || response.StatusCode != http.StatusForbidden {
+			t.Errorf("%v should be forbidden without authorization", url)
+		}
+	}
+
+	box.Config.Authorize = func(request *http.Request) bool {
+		return request.Header.Get("Authorization") == "admin"
+	}
+
+	request := func(method, url string, value interface{}) int {
+		req, _ := http.NewRequest(method, url, nil)
+		req.Header.Set("Authorization", "admin")
+		response, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		if value != nil {
+			json.NewDecoder(response.Body).Decode(value)
+		}
+		return response.StatusCode
+	}
+
+	if response, _ := http.Post(entryURL+"/replay", "application/json", nil); response.StatusCode != http.StatusForbidden {
+		t.Errorf("replay should be forbidden without authorization")
+	}
+
+	var list []outbox.Entry
+	if status := request("GET", server.URL+"/entries?status=failed", &list); status != http.StatusOK || len(list) != 1 {
+		t.Errorf("should list failed entries, but got %v, %+v", status, list)
+	}
+
+	if status := request("POST", entryURL+"/replay", nil); status != http.StatusOK {
+		t.Errorf("should replay entry, but got %v", status)
+	}
+
+	var entry outbox.Entry
+	if status := request("GET", entryURL, &entry); status != http.StatusOK || entry.Status != outbox.StatusPending || entry.Attempts != 0 {
+		t.Errorf("replayed entry should be pending, but got %v, %+v", status, entry)
+	}
+
+	if status := request("GET", server.URL+"/entries/0", nil); status != http.StatusNotFound {
+		t.Errorf("unknown entry should be not found, but got %v", status)
+	}
+}
+
+func TestRelayBackoff(t *testing.T) {
+	box, res, context := newOutbox(t)
+	box.Config.Backoff = time.Hour
+
+	bad := &sink{err: errors.New("unavailable")}
+	box.AddSink(bad)
+
+	res.CallSave(&Order{Code: "O001"}, context)
+	box.Relay()
+	if delivered, _ := box.Relay(); delivered != 0 || len(bad.keys) != 1 {
+		t.Errorf("failed entry shouldn't be retried before backoff, but got %v", bad.keys)
+	}
+
+	bad.err = nil
+	res.CallSave(&Order{Code: "O002"}, context)
+	if delivered, _ := box.Relay(); delivered != 1 || len(bad.keys) != 2 {
+		t.Errorf("entry shouldn't be blocked by failed entries, but got %v, %v", delivered, bad.keys)
+	}
+}
+
+type relaySink struct {
+	box  *outbox.Outbox
+	keys []string
+}
+
+func (sink *relaySink) Name() string { return "relay" }
+
+func (sink *relaySink) Deliver(entry *outbox.Entry) error {
+	sink.keys = append(sink.keys, entry.Key)
+	//	relays running at the same time should skip the claimed entry
+	sink.box.Relay()
+	return nil
+}
+
+func TestRelayClaimed(t *testing.T) {
+	box, res, context := newOutbox(t)
+	sink := &relaySink{box: box}
+	box.AddSink(sink)
+
+	res.CallSave(&Order{Code: "O001"}, context)
+	if delivered, _ := box.Relay(); delivered != 1 || len(sink.keys) != 1 {
+		t.Errorf("claimed entry should be delivered once, but got %v", sink.keys)
+	}
+}
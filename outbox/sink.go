@@ -0,0 +1,149 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+)
+
+//	'Sink' destination of outbox entries, 'Deliver' should be idempotent with entry's 'Key', as entries are delivered at least once
+type Sink interface {
+	Name() string
+	Deliver(*Entry) error
+}
+
+//	'DefaultHTTPClient' client of 'HTTPSink' if it is not set, requests are timed out, so a stuck endpoint won't block the relay
+var DefaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+//	'HTTPSink' post entries as JSON to an URL, entry's key is sent in "Idempotency-Key" header
+//	'Client' should have a timeout, 'DefaultHTTPClient' is used if it is nil
+type HTTPSink struct {
+	URL    string
+	Header http.Header
+	Client *http.Client
+}
+
+//	'Name' name of the sink
+func (sink *HTTPSink) Name() string {
+	return "http " + sink.URL
+}
+
+//	'Deliver' deliver an entry, response status other than 2xx is treated as failure
+func (sink *HTTPSink) Deliver(entry *Entry) error {
+	content, err := json.Marshal(newEntryMessage(entry))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", sink.URL, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	for key, values := range sink.Header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Idempotency-Key", entry.Key)
+
+	client := sink.Client
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response status %v", response.StatusCode)
+	}
+	return nil
+}
+
+//	'FileSink' append entries to a file as JSON lines
+type FileSink struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+//	'Name' name of the sink
+func (sink *FileSink) Name() string {
+	return "file " + sink.Path
+}
+
+//	'Deliver' append the entry to the file
+func (sink *FileSink) Deliver(entry *Entry) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	file, err := os.OpenFile(sink.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err = json.NewEncoder(file).Encode(newEntryMessage(entry)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+//	'BusSink' publish entries to an in-process event bus, event's 'Record' is the decoded payload
+type BusSink struct {
+	Bus *resource.EventBus
+}
+
+//	'Name' name of the sink
+func (sink *BusSink) Name() string {
+	return "bus"
+}
+
+//	'Deliver' publish the entry as a resource event
+func (sink *BusSink) Deliver(entry *Entry) error {
+	bus := sink.Bus
+	if bus == nil {
+		bus = resource.DefaultEventBus
+	}
+
+	return bus.Publish(resource.Event{
+		Type:       resource.EventType(entry.Event),
+		Resource:   entry.Resource,
+		PrimaryKey: entry.PrimaryKey,
+		Changes:    entry.GetChanges(),
+		Record:     entry.GetPayload(),
+		CreatedAt:  entry.CreatedAt,
+	})
+}
+
+type entryMessage struct {
+	Key        string                 `json:"key"`
+	Resource   string                 `json:"resource"`
+	Event      string                 `json:"event"`
+	PrimaryKey string                 `json:"primary_key"`
+	Changes    []string               `json:"changes"`
+	Payload    map[string]interface{} `json:"payload"`
+	User       string                 `json:"user,omitempty"`
+	CreatedAt  string                 `json:"created_at"`
+}
+
+func newEntryMessage(entry *Entry) entryMessage {
+	return entryMessage{
+		Key:        entry.Key,
+		Resource:   entry.Resource,
+		Event:      entry.Event,
+		PrimaryKey: entry.PrimaryKey,
+		Changes:    entry.GetChanges(),
+		Payload:    entry.GetPayload(),
+		User:       entry.UserName,
+		CreatedAt:  entry.CreatedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+	}
+}
@@ -0,0 +1,463 @@
+package resource
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+//	'OrphanPolicy' decides what to do with has one, has many children that are not submitted anymore
+type OrphanPolicy int
+
+const (
+	//	'KeepOrphans' keep orphans as it is
+	KeepOrphans OrphanPolicy = iota
+	//	'DeleteOrphans' delete orphans
+	DeleteOrphans
+	//	'NullifyOrphans' set orphans' foreign keys to null
+	NullifyOrphans
+)
+
+//	'AssociationConfig' meta config for has one, has many relationships
+//	'SortField' is the field of children that will be set to their submitted order, it is "Position" by default if children have it
+type AssociationConfig struct {
+	MetaConfig
+	Orphans   OrphanPolicy
+	SortField string
+}
+
+func (meta *Meta) getAssociationConfig() *AssociationConfig {
+	if config, ok := meta.Config.(*AssociationConfig); ok {
+		return config
+	}
+	return &AssociationConfig{}
+}
+
+//	'associationSetter' setter for has one, has many relationships, it decodes children from nested meta values
+//	existing children are found by their primary keys and updated, children with "_destory" are deleted, and new children are created
+func (meta *Meta) associationSetter(relationship *gorm.Relationship) func(resource interface{}, metaValue *MetaValue, context *TM_EC.Context) {
+	return func(resource interface{}, metaValue *MetaValue, context *TM_EC.Context) {
+		var (
+			reflectValue = reflect.Indirect(reflect.ValueOf(resource))
+			field        = reflectValue.FieldByName(meta.structFieldName())
+			fieldType    = field.Type()
+			isSlice      = fieldType.Kind() == reflect.Slice
+			isPtr        bool
+		)
+
+		if isSlice {
+			if metaValue.Operation != "" {
+				meta.patchChildren(resource, field, metaValue, relationship, context)
+				return
+			}
+
+			fieldType = fieldType.Elem()
+			if metaValue.Index == 0 {
+				field.Set(reflect.Zero(field.Type()))
+			}
+		}
+
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+			isPtr = true
+		}
+
+		if metaValue.MetaValues == nil {
+			if !isSlice {
+				field.Set(reflect.Zero(field.Type()))
+			}
+			return
+		}
+
+		child := reflect.New(fieldType)
+		if !isSlice {
+			if existing := reflect.Indirect(field); existing.IsValid() {
+				child.Elem().Set(existing)
+			}
+		}
+
+		if !decodeChild(resource, child, metaValue, relationship, context) {
+			if !isSlice {
+				field.Set(reflect.Zero(field.Type()))
+			}
+			return
+		}
+
+		stampAssociation(reflectValue, child.Elem(), relationship)
+
+		if isSlice {
+			meta.setPosition(child.Elem(), metaValue.Index)
+			if isPtr {
+				field.Set(reflect.Append(field, child))
+			} else {
+				field.Set(reflect.Append(field, child.Elem()))
+			}
+		} else if isPtr {
+			field.Set(child)
+		} else {
+			field.Set(child.Elem())
+		}
+	}
+}
+
+//	'decodeChild' decode nested meta values to the child, returns false if it is skipped
+//	Existing children are found in children of the parent, children submitted with primary keys of other parents' children are rejected
+func decodeChild(resource interface{}, child reflect.Value, metaValue *MetaValue, relationship *gorm.Relationship, context *TM_EC.Context) bool {
+	var res Resourcer
+	if metaValue.Meta != nil {
+		res = metaValue.Meta.GetResource()
+	}
+
+	if res == nil {
+		res = New(reflect.New(child.Type().Elem()).Interface())
+	}
+
+	associationProcessor := DecodeToResource(res, child.Interface(), metaValue.MetaValues, context)
+	associationProcessor.nested = true
+	associationProcessor.parentScope = parentScope(reflect.ValueOf(resource), relationship)
+	err := associationProcessor.Start()
+	if err == ErrForeignRecord {
+		context.AddError(NewFieldError(resource, metaValue.Name, i18n.NewError("ec.errors.not_found")))
+		return false
+	}
+
+	context.AddError(err)
+	return !associationProcessor.SkipLeft
+}
+
+//	'parentScope' scope children of has one, has many relationship to the parent by foreign keys and polymorphic type
+func parentScope(parent reflect.Value, relationship *gorm.Relationship) func(*gorm.DB) *gorm.DB {
+	if relationship == nil || (relationship.Kind != "has_one" && relationship.Kind != "has_many") {
+		return nil
+	}
+
+	parent = reflect.Indirect(parent)
+	return func(db *gorm.DB) *gorm.DB {
+		for idx, foreignDBName := range relationship.ForeignDBNames {
+			if idx < len(relationship.AssociationForeignFieldNames) {
+				associationField := parent.FieldByName(relationship.AssociationForeignFieldNames[idx])
+				db = db.Where(fmt.Sprintf("%v = ?", db.Dialect().Quote(foreignDBName)), associationField.Interface())
+			}
+		}
+
+		if relationship.PolymorphicType != "" {
+			db = db.Where(fmt.Sprintf("%v = ?", db.Dialect().Quote(relationship.PolymorphicDBName)), relationship.PolymorphicValue)
+		}
+		return db
+	}
+}
+
+//	'setPosition' set sort field of has many child to its index
+func (meta *Meta) setPosition(child reflect.Value, index int) {
+	sortField := meta.getAssociationConfig().SortField
+	if sortField == "" {
+		sortField = "Position"
+	}
+
+	if position := reflect.Indirect(child).FieldByName(sortField); position.IsValid() && position.CanSet() {
+		switch position.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			position.SetInt(int64(index + 1))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			position.SetUint(uint64(index + 1))
+		}
+	}
+}
+
+//	'patchChildren' apply JSON Patch operation to has many children that are loaded by the processor, children of "replace" operations are decoded from existing ones
+//	Removed children are handled by orphan policy of the meta
+func (meta *Meta) patchChildren(resource interface{}, field reflect.Value, metaValue *MetaValue, relationship *gorm.Relationship, context *TM_EC.Context) {
+	var (
+		reflectValue = reflect.Indirect(reflect.ValueOf(resource))
+		elemType     = field.Type().Elem()
+		isPtr        = elemType.Kind() == reflect.Ptr
+	)
+
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+
+	err := patchSlice(field, metaValue, func(existing reflect.Value) (reflect.Value, bool) {
+		child := reflect.New(elemType)
+		if existing = reflect.Indirect(existing); existing.IsValid() {
+			child.Elem().Set(existing)
+		}
+
+		if metaValue.MetaValues == nil {
+			context.AddError(NewFieldError(resource, meta.Name, i18n.NewError("ec.errors.invalid_value", metaValue.Value)))
+			return child, false
+		}
+
+		if !decodeChild(resource, child, metaValue, relationship, context) {
+			return child, false
+		}
+
+		stampAssociation(reflectValue, child.Elem(), relationship)
+		if isPtr {
+			return child, true
+		}
+		return child.Elem(), true
+	})
+
+	if err != nil {
+		context.AddError(NewFieldError(resource, meta.Name, err))
+		return
+	}
+
+	for i := 0; i < field.Len(); i++ {
+		meta.setPosition(field.Index(i), i)
+	}
+}
+
+//	'registerOrphansCallback' register an after save callback to delete or nullify children that are not submitted anymore
+func (meta *Meta) registerOrphansCallback(relationship *gorm.Relationship) {
+	var (
+		config = meta.getAssociationConfig()
+		res    = meta.Resource.GetResource()
+		name   = "ec:orphans:" + meta.Name
+	)
+
+	if config.Orphans == KeepOrphans {
+		res.RemoveCallback(CallbackAfterSave, name)
+		return
+	}
+
+	res.AddCallback(CallbackAfterSave, name, func(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		if metaValues == nil || metaValues.Get(meta.Name) == nil {
+			return nil
+		}
+
+		if strings.Contains(meta.FieldName, ".") {
+			if record = getNestedModel(record, meta.FieldName, false, context); record == nil {
+				return nil
+			}
+		}
+
+		var (
+			reflectValue = reflect.Indirect(reflect.ValueOf(record))
+			field        = reflect.Indirect(reflectValue.FieldByName(meta.structFieldName()))
+			fieldType    = field.Type()
+			keep         [][]interface{}
+		)
+
+		if fieldType.Kind() == reflect.Slice {
+			fieldType = fieldType.Elem()
+		}
+
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+
+		var (
+			db            = context.GetDB()
+			childScope    = db.NewScope(reflect.New(fieldType).Interface())
+			primaryFields = childScope.PrimaryFields()
+		)
+
+		if len(primaryFields) == 0 {
+			return nil
+		}
+
+		collect := func(child reflect.Value) {
+			if child = reflect.Indirect(child); child.IsValid() {
+				var values []interface{}
+				for _, primaryField := range primaryFields {
+					key := child.FieldByName(primaryField.Name)
+					if !key.IsValid() || isBlank(key) {
+						return
+					}
+					values = append(values, key.Interface())
+				}
+				keep = append(keep, values)
+			}
+		}
+
+		if field.Kind() == reflect.Slice {
+			for i := 0; i < field.Len(); i++ {
+				collect(field.Index(i))
+			}
+		} else {
+			collect(field)
+		}
+
+		query := db.Model(reflect.New(fieldType).Interface())
+		for idx, foreignDBName := range relationship.ForeignDBNames {
+			associationField := reflectValue.FieldByName(relationship.AssociationForeignFieldNames[idx])
+			query = query.Where(fmt.Sprintf("%v = ?", childScope.Quote(foreignDBName)), associationField.Interface())
+		}
+
+		if relationship.PolymorphicType != "" {
+			query = query.Where(fmt.Sprintf("%v = ?", childScope.Quote(relationship.PolymorphicDBName)), relationship.PolymorphicValue)
+		}
+
+		if len(primaryFields) == 1 && len(keep) > 0 {
+			var keys []interface{}
+			for _, values := range keep {
+				keys = append(keys, values...)
+			}
+			query = query.Where(fmt.Sprintf("%v NOT IN (?)", childScope.Quote(primaryFields[0].DBName)), keys)
+		} else {
+			//	composite primary keys
+			var sqls []string
+			for _, primaryField := range primaryFields {
+				sqls = append(sqls, fmt.Sprintf("%v = ?", childScope.Quote(primaryField.DBName)))
+			}
+
+			for _, values := range keep {
+				query = query.Where(fmt.Sprintf("NOT (%v)", strings.Join(sqls, " AND ")), values...)
+			}
+		}
+
+		if config.Orphans == DeleteOrphans {
+			return query.Delete(reflect.New(fieldType).Interface()).Error
+		}
+
+		updates := map[string]interface{}{}
+		for _, foreignDBName := range relationship.ForeignDBNames {
+			updates[foreignDBName] = nil
+		}
+		return query.UpdateColumns(updates).Error
+	})
+}
+
+//	'stampAssociation' set has one, has many child's foreign keys to parent's values, and set polymorphic type for polymorphic relationships
+func stampAssociation(parent reflect.Value, child reflect.Value, relationship *gorm.Relationship) {
+	if relationship == nil || (relationship.Kind != "has_one" && relationship.Kind != "has_many") {
+		return
+	}
+
+	parent, child = reflect.Indirect(parent), reflect.Indirect(child)
+	for idx, foreignFieldName := range relationship.ForeignFieldNames {
+		if idx < len(relationship.AssociationForeignFieldNames) {
+			foreignField := child.FieldByName(foreignFieldName)
+			associationField := parent.FieldByName(relationship.AssociationForeignFieldNames[idx])
+			if foreignField.CanSet() && associationField.IsValid() && !isBlank(associationField) {
+				setFieldValue(foreignField, associationField)
+			}
+		}
+	}
+
+	if relationship.PolymorphicType != "" {
+		if typeField := child.FieldByName(relationship.PolymorphicType); typeField.CanSet() {
+			setFieldValue(typeField, reflect.ValueOf(relationship.PolymorphicValue))
+		}
+	}
+}
+
+//	'loadChildren' load children of the meta if they are blank, has many children are ordered by the sort field, so patches could refer them by index
+func loadChildren(record interface{}, meta Metaor, context *TM_EC.Context) {
+	scope := context.GetDB().NewScope(record)
+	field, ok := scope.FieldByName(meta.GetFieldName())
+	if !ok || field.Relationship == nil || field.Relationship.Kind == "belongs_to" || !isBlank(field.Field) || scope.PrimaryKeyZero() {
+		return
+	}
+
+	if field.Relationship.Kind == "has_many" {
+		sortField := "Position"
+		if configor, ok := meta.(interface {
+			getAssociationConfig() *AssociationConfig
+		}); ok && configor.getAssociationConfig().SortField != "" {
+			sortField = configor.getAssociationConfig().SortField
+		}
+
+		elemType := field.Field.Type().Elem()
+		for elemType.Kind() == reflect.Ptr {
+			elemType = elemType.Elem()
+		}
+
+		if sort, ok := context.GetDB().NewScope(reflect.New(elemType).Interface()).FieldByName(sortField); ok {
+			context = context.Clone()
+			context.SetDB(context.GetDB().Order(scope.Quote(sort.DBName)))
+		}
+	}
+	loadRelated(record, field, context)
+}
+
+//	'loadRelated' load related records of the field, children of polymorphic relationships are scoped by polymorphic type
+func loadRelated(record interface{}, field *gorm.Field, context *TM_EC.Context) {
+	relationship := field.Relationship
+	if relationship == nil || !field.Field.CanAddr() {
+		return
+	}
+
+	db := context.GetDB()
+	if relationship.PolymorphicType != "" && (relationship.Kind == "has_one" || relationship.Kind == "has_many") {
+		var (
+			reflectValue = reflect.Indirect(reflect.ValueOf(record))
+			toScope      = db.NewScope(field.Field.Addr().Interface())
+			query        = db.Where(fmt.Sprintf("%v = ?", toScope.Quote(relationship.PolymorphicDBName)), relationship.PolymorphicValue)
+		)
+
+		for idx, foreignDBName := range relationship.ForeignDBNames {
+			associationField := reflectValue.FieldByName(relationship.AssociationForeignFieldNames[idx])
+			query = query.Where(fmt.Sprintf("%v = ?", toScope.Quote(foreignDBName)), associationField.Interface())
+		}
+		query.Find(field.Field.Addr().Interface())
+		return
+	}
+
+	db.Model(record).Related(field.Field.Addr().Interface(), field.Name)
+}
+
+func setFieldValue(field reflect.Value, value reflect.Value) {
+	value = reflect.Indirect(value)
+	if !value.IsValid() {
+		return
+	}
+
+	if field.Kind() == reflect.Ptr {
+		if value.Type().ConvertibleTo(field.Type().Elem()) {
+			ptr := reflect.New(field.Type().Elem())
+			ptr.Elem().Set(value.Convert(field.Type().Elem()))
+			field.Set(ptr)
+		}
+	} else if value.Type().ConvertibleTo(field.Type()) {
+		field.Set(value.Convert(field.Type()))
+	}
+}
+
+func isBlank(value reflect.Value) bool {
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
+
+//	'patchAssociatedRecords' apply JSON Patch operation to many to many associations, elements of "add", "replace" operations are found by submitted primary keys
+func (meta *Meta) patchAssociatedRecords(resource interface{}, field reflect.Value, metaValue *MetaValue, context *TM_EC.Context) {
+	var (
+		elemType = field.Type().Elem()
+		isPtr    = elemType.Kind() == reflect.Ptr
+	)
+
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+
+	err := patchSlice(field, metaValue, func(reflect.Value) (reflect.Value, bool) {
+		element := reflect.New(elemType)
+
+		if err := context.GetDB().Where([]string{utils.ToString(metaValue.Value)}).First(element.Interface()).Error; err != nil {
+			context.AddError(NewFieldError(resource, meta.Name, i18n.NewError("ec.errors.not_found")))
+			return element, false
+		}
+
+		if isPtr {
+			return element, true
+		}
+		return element.Elem(), true
+	})
+
+	if err != nil {
+		context.AddError(NewFieldError(resource, meta.Name, err))
+		return
+	}
+
+	if !context.GetDB().NewScope(resource).PrimaryKeyZero() {
+		context.AddError(context.GetDB().Model(resource).Association(meta.structFieldName()).Replace(field.Interface()).Error)
+	}
+}
@@ -0,0 +1,456 @@
+package resource
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+//	'SelectOneConfig' meta config for select_one metas
+//	'Collection' could be []string, [][]string{{value, label}}, []MetaOption, func(record interface{}, context *TM_EC.Context) []MetaOption or a 'Resourcer'
+//	If 'Collection' is blank for belongs to relationships, the related model's resource will be used
+type SelectOneConfig struct {
+	MetaConfig
+	Collection interface{}
+}
+
+//	'SelectManyConfig' meta config for select_many metas, refer 'SelectOneConfig' for 'Collection'
+type SelectManyConfig struct {
+	MetaConfig
+	Collection interface{}
+}
+
+//	'RichTextConfig' meta config for rich_text metas, submitted HTML will be sanitized with 'Sanitizer', it is 'utils.HTMLSanitizer' by default
+type RichTextConfig struct {
+	MetaConfig
+	Sanitizer interface {
+		Sanitize(string) string
+	}
+}
+
+//	'MoneyConfig' meta config for money metas
+//	'Currency' is the default currency, submitted value could has currency code, e.g. "USD 12.50", it will be saved to 'CurrencyField' if configured
+//	'Currencies' is allowed currency codes, blank means any currency
+//	'Precision' is the max number of decimal places, it is 2 by default
+type MoneyConfig struct {
+	MetaConfig
+	Currency      string
+	CurrencyField string
+	Currencies    []string
+	Precision     int
+}
+
+//	'EnumConfig' meta config for enum metas, both value and label of options could be submitted
+type EnumConfig struct {
+	MetaConfig
+	Options []MetaOption
+}
+
+var (
+	//	'DateLayout' layout of formatted date value
+	DateLayout = "2006-01-02"
+	//	'DateTimeLayout' layout of formatted datetime value
+	DateTimeLayout = "2006-01-02 15:04"
+)
+
+func init() {
+	RegisterMetaType(&MetaType{
+		Name: "select_one",
+		Configure: func(meta *Meta) {
+			config, ok := meta.Config.(*SelectOneConfig)
+			if !ok {
+				config = &SelectOneConfig{}
+				meta.Config = config
+			}
+
+			if config.Collection == nil {
+				config.Collection = meta.relatedResource()
+			}
+		},
+		Decode: func(meta *Meta, record interface{}, metaValue *MetaValue, context *TM_EC.Context) (interface{}, error) {
+			config := meta.Config.(*SelectOneConfig)
+			if value := metaValueString(metaValue); value != "" && !hasOption(config.Collection, value, record, context) {
+				return nil, i18n.NewError("ec.errors.invalid_option", value)
+			}
+			return metaValue.Value, nil
+		},
+		Format: func(meta *Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{} {
+			return formatOption(meta.Config.(*SelectOneConfig).Collection, value, record, context)
+		},
+		Schema: func(meta *Meta, schema *MetaSchema, context *TM_EC.Context) {
+			collectionSchema(meta.Config.(*SelectOneConfig).Collection, schema, context)
+		},
+	})
+
+	RegisterMetaType(&MetaType{
+		Name: "select_many",
+		Configure: func(meta *Meta) {
+			config, ok := meta.Config.(*SelectManyConfig)
+			if !ok {
+				config = &SelectManyConfig{}
+				meta.Config = config
+			}
+
+			if config.Collection == nil {
+				config.Collection = meta.relatedResource()
+			}
+		},
+		Decode: func(meta *Meta, record interface{}, metaValue *MetaValue, context *TM_EC.Context) (interface{}, error) {
+			config := meta.Config.(*SelectManyConfig)
+			values := utils.ToArray(metaValue.Value)
+			for _, value := range values {
+				if !hasOption(config.Collection, value, record, context) {
+					return nil, i18n.NewError("ec.errors.invalid_option", value)
+				}
+			}
+
+			if meta.fieldKind() == reflect.String {
+				return strings.Join(values, ","), nil
+			}
+			return values, nil
+		},
+		Format: func(meta *Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{} {
+			var (
+				collection = meta.Config.(*SelectManyConfig).Collection
+				results    []interface{}
+			)
+
+			reflectValue := reflect.Indirect(reflect.ValueOf(value))
+			switch {
+			case reflectValue.Kind() == reflect.String:
+				for _, v := range strings.Split(reflectValue.String(), ",") {
+					if v != "" {
+						results = append(results, formatOption(collection, v, record, context))
+					}
+				}
+			case reflectValue.Kind() == reflect.Slice:
+				for i := 0; i < reflectValue.Len(); i++ {
+					results = append(results, formatOption(collection, reflectValue.Index(i).Interface(), record, context))
+				}
+			}
+			return results
+		},
+		Schema: func(meta *Meta, schema *MetaSchema, context *TM_EC.Context) {
+			collectionSchema(meta.Config.(*SelectManyConfig).Collection, schema, context)
+			schema.Attributes["multiple"] = true
+		},
+	})
+
+	RegisterMetaType(&MetaType{
+		Name: "rich_text",
+		Configure: func(meta *Meta) {
+			config, ok := meta.Config.(*RichTextConfig)
+			if !ok {
+				config = &RichTextConfig{}
+				meta.Config = config
+			}
+
+			if config.Sanitizer == nil {
+				config.Sanitizer = utils.HTMLSanitizer
+			}
+		},
+		Decode: func(meta *Meta, record interface{}, metaValue *MetaValue, context *TM_EC.Context) (interface{}, error) {
+			return meta.Config.(*RichTextConfig).Sanitizer.Sanitize(metaValueString(metaValue)), nil
+		},
+	})
+
+	RegisterMetaType(&MetaType{
+		Name: "money",
+		Configure: func(meta *Meta) {
+			config, ok := meta.Config.(*MoneyConfig)
+			if !ok {
+				config = &MoneyConfig{}
+				meta.Config = config
+			}
+
+			if config.Precision == 0 {
+				config.Precision = 2
+			}
+		},
+		Decode: func(meta *Meta, record interface{}, metaValue *MetaValue, context *TM_EC.Context) (interface{}, error) {
+			var (
+				config = meta.Config.(*MoneyConfig)
+				money  decimal.Money
+				err    error
+			)
+
+			switch value := metaValue.Value.(type) {
+			case decimal.Money:
+				money = value
+			case string, []string, []interface{}:
+				str := strings.TrimSpace(metaValueString(metaValue))
+				if str == "" {
+					return "", nil
+				}
+
+				if money, err = decimal.ParseMoney(str, utils.GetLocale(context)); err != nil {
+					return nil, i18n.NewError("ec.errors.invalid_amount", str)
+				}
+			default:
+				if value == nil {
+					return "", nil
+				}
+
+				if money.Amount, err = utils.ToDecimal(value, context); err != nil {
+					return nil, i18n.NewError("ec.errors.invalid_amount", metaValueString(metaValue))
+				}
+			}
+
+			if money.Amount.Scale() > config.Precision {
+				if rounded := money.Amount.Rescale(config.Precision); rounded.Equal(money.Amount) {
+					money.Amount = rounded
+				} else {
+					return nil, i18n.NewError("ec.errors.too_many_decimal_places", money.Amount, config.Precision)
+				}
+			}
+
+			if money.Currency != "" && len(config.Currencies) > 0 && !stringInSlice(money.Currency, config.Currencies) {
+				return nil, i18n.NewError("ec.errors.unsupported_currency", money.Currency)
+			}
+
+			if meta.indirectFieldType() == reflect.TypeOf(decimal.Money{}) {
+				if money.Currency == "" {
+					money.Currency = config.Currency
+				}
+				return money, nil
+			}
+
+			if money.Currency != "" {
+				if config.CurrencyField != "" {
+					if field := reflect.Indirect(reflect.ValueOf(record)).FieldByName(config.CurrencyField); field.IsValid() && field.Kind() == reflect.String {
+						field.SetString(money.Currency)
+					}
+				} else if config.Currency != "" && money.Currency != config.Currency {
+					return nil, i18n.NewError("ec.errors.unsupported_currency", money.Currency)
+				}
+			}
+			return money.Amount, nil
+		},
+		Format: func(meta *Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{} {
+			var (
+				config       = meta.Config.(*MoneyConfig)
+				money        decimal.Money
+				reflectValue = reflect.Indirect(reflect.ValueOf(value))
+			)
+
+			if !reflectValue.IsValid() {
+				return ""
+			}
+
+			if v, ok := reflectValue.Interface().(decimal.Money); ok {
+				money = v
+			} else if amount, err := utils.ToDecimal(reflectValue.Interface(), context); err == nil {
+				money.Amount = amount
+			} else {
+				return value
+			}
+
+			if money.Currency == "" {
+				money.Currency = config.Currency
+				if config.CurrencyField != "" {
+					if field := reflect.Indirect(reflect.ValueOf(record)).FieldByName(config.CurrencyField); field.IsValid() && field.Kind() == reflect.String && field.String() != "" {
+						money.Currency = field.String()
+					}
+				}
+			}
+
+			money.Amount = money.Amount.Rescale(config.Precision)
+			return money.Format(utils.GetLocale(context))
+		},
+		Schema: func(meta *Meta, schema *MetaSchema, context *TM_EC.Context) {
+			config := meta.Config.(*MoneyConfig)
+			schema.Attributes["currency"] = config.Currency
+			schema.Attributes["precision"] = config.Precision
+			if len(config.Currencies) > 0 {
+				schema.Attributes["currencies"] = config.Currencies
+			}
+		},
+	})
+
+	for name, layout := range map[string]*string{"date": &DateLayout, "datetime": &DateTimeLayout} {
+		name, layout := name, layout
+		RegisterMetaType(&MetaType{
+			Name: name,
+			Decode: func(meta *Meta, record interface{}, metaValue *MetaValue, context *TM_EC.Context) (interface{}, error) {
+				str := metaValueString(metaValue)
+				if str == "" {
+					return "", nil
+				}
+
+				t, err := utils.ParseTime(str, context)
+				if err != nil {
+					return nil, i18n.NewError("ec.errors.invalid_format", str, name)
+				}
+
+				if name == "date" {
+					t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+				}
+
+				if meta.fieldKind() == reflect.String {
+					return utils.FormatTime(t, *layout, context), nil
+				}
+				return t, nil
+			},
+			Format: func(meta *Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{} {
+				switch t := value.(type) {
+				case time.Time:
+					if !t.IsZero() {
+						return utils.FormatTime(t, *layout, context)
+					}
+				case *time.Time:
+					if t != nil && !t.IsZero() {
+						return utils.FormatTime(*t, *layout, context)
+					}
+				default:
+					return utils.ToString(value)
+				}
+				return ""
+			},
+			Schema: func(meta *Meta, schema *MetaSchema, context *TM_EC.Context) {
+				schema.Attributes["layout"] = *layout
+			},
+		})
+	}
+
+	RegisterMetaType(&MetaType{
+		Name: "enum",
+		Configure: func(meta *Meta) {
+			if _, ok := meta.Config.(*EnumConfig); !ok {
+				meta.Config = &EnumConfig{}
+			}
+		},
+		Decode: func(meta *Meta, record interface{}, metaValue *MetaValue, context *TM_EC.Context) (interface{}, error) {
+			value := metaValueString(metaValue)
+			if value == "" {
+				return "", nil
+			}
+
+			for _, option := range meta.Config.(*EnumConfig).Options {
+				if option.Value == value || strings.EqualFold(option.Label, value) {
+					return option.Value, nil
+				}
+			}
+			return nil, i18n.NewError("ec.errors.invalid_option", value)
+		},
+		Format: func(meta *Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{} {
+			return formatOption(meta.Config.(*EnumConfig).Options, value, record, context)
+		},
+		Schema: func(meta *Meta, schema *MetaSchema, context *TM_EC.Context) {
+			schema.Options = meta.Config.(*EnumConfig).Options
+		},
+	})
+}
+
+func metaValueString(metaValue *MetaValue) string {
+	if metaValue == nil || metaValue.Value == nil {
+		return ""
+	}
+	return utils.ToString(metaValue.Value)
+}
+
+//	'indirectFieldType' type of meta's field, pointers are dereferenced, returns nil if the meta has no field
+func (meta *Meta) indirectFieldType() reflect.Type {
+	if meta.FieldStruct == nil {
+		return nil
+	}
+
+	fieldType := meta.FieldStruct.Struct.Type
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+	return fieldType
+}
+
+//	'fieldKind' kind of meta's field, pointers are dereferenced
+func (meta *Meta) fieldKind() reflect.Kind {
+	if fieldType := meta.indirectFieldType(); fieldType != nil {
+		return fieldType.Kind()
+	}
+	return reflect.Invalid
+}
+
+//	'relatedResource' resource of belongs to, many to many relationship's model, returns nil for other fields
+func (meta *Meta) relatedResource() Resourcer {
+	if meta.FieldStruct == nil || meta.FieldStruct.Relationship == nil {
+		return nil
+	}
+
+	fieldType := meta.FieldStruct.Struct.Type
+	for fieldType.Kind() == reflect.Ptr || fieldType.Kind() == reflect.Slice {
+		fieldType = fieldType.Elem()
+	}
+	return New(reflect.New(fieldType).Interface())
+}
+
+//	'getOptions' get options of static or function collections, returns nil for resource collections
+func getOptions(collection interface{}, record interface{}, context *TM_EC.Context) (options []MetaOption) {
+	switch collection := collection.(type) {
+	case []string:
+		for _, value := range collection {
+			options = append(options, MetaOption{Value: value, Label: value})
+		}
+	case [][]string:
+		for _, pair := range collection {
+			if len(pair) > 1 {
+				options = append(options, MetaOption{Value: pair[0], Label: pair[1]})
+			} else if len(pair) == 1 {
+				options = append(options, MetaOption{Value: pair[0], Label: pair[0]})
+			}
+		}
+	case []MetaOption:
+		options = collection
+	case func(interface{}, *TM_EC.Context) []MetaOption:
+		options = collection(record, context)
+	}
+	return
+}
+
+func hasOption(collection interface{}, value string, record interface{}, context *TM_EC.Context) bool {
+	if res, ok := collection.(Resourcer); ok {
+		primaryQuerySQL, primaryParams, err := res.GetResource().ToPrimaryQueryParams(value, context)
+		if err != nil {
+			return false
+		}
+
+		var count int
+		context.GetDB().Model(res.NewStruct()).Where(primaryQuerySQL, primaryParams...).Count(&count)
+		return count > 0
+	}
+
+	for _, option := range getOptions(collection, record, context) {
+		if option.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func formatOption(collection interface{}, value interface{}, record interface{}, context *TM_EC.Context) interface{} {
+	if _, ok := collection.(Resourcer); ok {
+		if reflect.Indirect(reflect.ValueOf(value)).Kind() == reflect.Struct {
+			return utils.Stringify(value)
+		}
+		return value
+	}
+
+	str := utils.ToString(value)
+	for _, option := range getOptions(collection, record, context) {
+		if option.Value == str {
+			return option.Label
+		}
+	}
+	return value
+}
+
+func collectionSchema(collection interface{}, schema *MetaSchema, context *TM_EC.Context) {
+	if res, ok := collection.(Resourcer); ok {
+		schema.Attributes["resource"] = res.GetResource().Name
+		return
+	}
+	schema.Options = getOptions(collection, nil, context)
+}
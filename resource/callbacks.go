@@ -0,0 +1,307 @@
+package resource
+
+import (
+	"database/sql"
+	"log"
+	"reflect"
+	"sort"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+//	'CallbackKind' kind of resource lifecycle callbacks
+type CallbackKind string
+
+const (
+	//	'CallbackBeforeSave' run in the save transaction before 'SaveHandler', returning an error will abort the save
+	CallbackBeforeSave CallbackKind = "before_save"
+	//	'CallbackAfterSave' run in the save transaction after 'SaveHandler', returning an error will rollback the save
+	CallbackAfterSave CallbackKind = "after_save"
+	//	'CallbackAfterSaveCommit' run after the transaction that saved the record committed
+	CallbackAfterSaveCommit CallbackKind = "after_save_commit"
+	//	'CallbackBeforeDelete' run in the delete transaction before 'DeleteHandler', returning an error will abort the delete
+	CallbackBeforeDelete CallbackKind = "before_delete"
+	//	'CallbackAfterDelete' run in the delete transaction after 'DeleteHandler', returning an error will rollback the delete
+	CallbackAfterDelete CallbackKind = "after_delete"
+	//	'CallbackAfterDeleteCommit' run after the transaction that deleted the record committed
+	CallbackAfterDeleteCommit CallbackKind = "after_delete_commit"
+	//	'CallbackAfterFind' run after 'FindOneHandler' or 'FindManyHandler', returning an error will be returned as the find error
+	CallbackAfterFind CallbackKind = "after_find"
+)
+
+const (
+	metaValuesSettingKey   = "ec:meta_values"
+	newRecordSettingKey    = "ec:new_record"
+	afterCommitsSettingKey = "ec:after_commit_callbacks"
+	parentScopeSettingKey  = "ec:parent_scope"
+)
+
+//	'Callback' a resource lifecycle callback, callbacks with smaller priority will be run first, callbacks with same priority will be run in registered order
+type Callback struct {
+	Name     string
+	Kind     CallbackKind
+	Priority int
+	Handler  func(interface{}, *MetaValues, *TM_EC.Context) error
+}
+
+//	'AddCallback' add lifecycle callback to resource, the callback with same kind and name will be replaced
+//		res.AddCallback(resource.CallbackAfterSaveCommit, "send_confirm_email", func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+//			return mailer.Send(record.(*Order).Email)
+//		})
+func (res *Resource) AddCallback(kind CallbackKind, name string, fc func(interface{}, *MetaValues, *TM_EC.Context) error, priority ...int) *Callback {
+	callback := &Callback{Name: name, Kind: kind, Handler: fc}
+	if len(priority) > 0 {
+		callback.Priority = priority[0]
+	}
+
+	for idx, c := range res.Callbacks {
+		if c.Kind == kind && c.Name == name {
+			res.Callbacks[idx] = callback
+			return callback
+		}
+	}
+
+	res.Callbacks = append(res.Callbacks, callback)
+	return callback
+}
+
+//	'RemoveCallback' remove lifecycle callback from resource by kind and name
+func (res *Resource) RemoveCallback(kind CallbackKind, name string) {
+	for idx, c := range res.Callbacks {
+		if c.Kind == kind && c.Name == name {
+			res.Callbacks = append(res.Callbacks[:idx], res.Callbacks[idx+1:]...)
+			return
+		}
+	}
+}
+
+//	'GetCallbacks' get sorted lifecycle callbacks of the kind
+func (res *Resource) GetCallbacks(kind CallbackKind) (callbacks []*Callback) {
+	for _, c := range res.Callbacks {
+		if c.Kind == kind {
+			callbacks = append(callbacks, c)
+		}
+	}
+
+	sort.SliceStable(callbacks, func(i, j int) bool {
+		return callbacks[i].Priority < callbacks[j].Priority
+	})
+	return
+}
+
+func (res *Resource) runCallbacks(kind CallbackKind, result interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+	for _, callback := range res.GetCallbacks(kind) {
+		if err := callback.Handler(result, metaValues, context); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (res *Resource) runAfterCommitCallbacks(kind CallbackKind, result interface{}, metaValues *MetaValues, context *TM_EC.Context) {
+	callbacks := res.GetCallbacks(kind)
+	if len(callbacks) == 0 {
+		return
+	}
+
+	newRecord := IsNewRecord(context)
+	AfterCommit(context, func(context *TM_EC.Context) {
+		callbackContext := context.Clone()
+		callbackContext.SetDB(context.GetDB().Set(newRecordSettingKey, newRecord).Set(metaValuesSettingKey, &recordMetaValues{record: result, metaValues: metaValues}))
+		for _, callback := range callbacks {
+			//	the transaction is committed, so errors couldn't be returned, log them in case the response is already built
+			if err := callback.Handler(result, metaValues, callbackContext); err != nil {
+				log.Printf("resource: %v callback %v of %v failed: %v", kind, callback.Name, res.Name, err)
+				context.AddError(err)
+			}
+		}
+	})
+}
+
+type afterCommitCallbacks struct {
+	callbacks []func(*TM_EC.Context)
+	rollbacks []func(*TM_EC.Context)
+	context   *TM_EC.Context
+}
+
+func (afterCommits *afterCommitCallbacks) rollbacked(context *TM_EC.Context) {
+	for _, callback := range afterCommits.rollbacks {
+		callback(context)
+	}
+}
+
+func getAfterCommitCallbacks(db *gorm.DB) *afterCommitCallbacks {
+	if value, ok := db.Get(afterCommitsSettingKey); ok {
+		if afterCommits, ok := value.(*afterCommitCallbacks); ok {
+			return afterCommits
+		}
+	}
+	return nil
+}
+
+//	'Transaction' run fc in a database transaction, the transaction will be rollbacked if fc return any error
+//	Functions registered with 'AfterCommit' in the transaction will be run after it committed, and functions registered with 'AfterRollback' after it rollbacked, if current context is already in a transaction, fc will be run in it
+//	If the transaction is begun by caller with 'db.Begin()', there is no way to know when it will be committed, so functions registered with 'AfterCommit' and 'AfterRollback' are dropped and logged, begin it with 'BeginTransaction' to run them
+func Transaction(context *TM_EC.Context, fc func(*TM_EC.Context) error) error {
+	db := context.GetDB()
+	if getAfterCommitCallbacks(db) != nil {
+		return fc(context)
+	}
+
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		var (
+			afterCommits = &afterCommitCallbacks{}
+			txContext    = context.Clone()
+		)
+
+		txContext.SetDB(db.Set(afterCommitsSettingKey, afterCommits))
+		err := fc(txContext)
+		if count := len(afterCommits.callbacks) + len(afterCommits.rollbacks); count > 0 {
+			log.Printf("resource: %v after commit, rollback callbacks are dropped, as the transaction is not begun with 'BeginTransaction'", count)
+		}
+		return err
+	}
+
+	var (
+		afterCommits = &afterCommitCallbacks{}
+		tx           = db.Set(afterCommitsSettingKey, afterCommits).Begin()
+		txContext    = context.Clone()
+	)
+
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	txContext.SetDB(tx)
+	if err := fc(txContext); err != nil {
+		tx.Rollback()
+		afterCommits.rollbacked(context)
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		afterCommits.rollbacked(context)
+		return err
+	}
+
+	for _, callback := range afterCommits.callbacks {
+		callback(context)
+	}
+	return nil
+}
+
+//	'BeginTransaction' begin a transaction, returns a cloned context that uses it, the context passed in is not changed
+//	Functions registered with 'AfterCommit' in the transaction are kept, and run with the context passed in when it is committed with 'CommitTransaction'
+//		txContext, err := resource.BeginTransaction(context)
+//		resource.ImportJSONStream(txContext, reader, productRes, nil)
+//		err = resource.CommitTransaction(txContext)
+func BeginTransaction(context *TM_EC.Context) (*TM_EC.Context, error) {
+	var (
+		tx        = context.GetDB().Set(afterCommitsSettingKey, &afterCommitCallbacks{context: context}).Begin()
+		txContext = context.Clone()
+	)
+
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	txContext.SetDB(tx)
+	return txContext, nil
+}
+
+//	'CommitTransaction' commit the transaction begun with 'BeginTransaction', and run functions registered with 'AfterCommit' in it, or 'AfterRollback' if failed to commit
+func CommitTransaction(txContext *TM_EC.Context) error {
+	db := txContext.GetDB()
+	if err := db.Commit().Error; err != nil {
+		if afterCommits := getAfterCommitCallbacks(db); afterCommits != nil {
+			afterCommits.rollbacked(afterCommits.context)
+		}
+		return err
+	}
+
+	if afterCommits := getAfterCommitCallbacks(db); afterCommits != nil {
+		for _, callback := range afterCommits.callbacks {
+			callback(afterCommits.context)
+		}
+	}
+	return nil
+}
+
+//	'RollbackTransaction' rollback the transaction begun with 'BeginTransaction', and run functions registered with 'AfterRollback' in it, functions registered with 'AfterCommit' are dropped
+func RollbackTransaction(txContext *TM_EC.Context) error {
+	db := txContext.GetDB()
+	err := db.Rollback().Error
+	if afterCommits := getAfterCommitCallbacks(db); afterCommits != nil {
+		afterCommits.rollbacked(afterCommits.context)
+	}
+	return err
+}
+
+//	'WithMetaValues' clone the context with meta values decoded to the record, save and delete callbacks of the record get them when it is saved or deleted with the returned context
+//		resource.DecodeToResource(productRes, &product, metaValues, context).Start()
+//		productRes.CallSave(&product, resource.WithMetaValues(context, &product, metaValues))
+func WithMetaValues(context *TM_EC.Context, record interface{}, metaValues *MetaValues) *TM_EC.Context {
+	clone := context.Clone()
+	clone.SetDB(context.GetDB().Set(metaValuesSettingKey, &recordMetaValues{record: record, metaValues: metaValues}))
+	return clone
+}
+
+//	'AfterCommit' register fc to be run after the transaction of current context committed, it will be run immediately if the context is not in a transaction started by 'Transaction'
+//	fc will be called with the context that started the transaction, so it won't use the committed transaction
+func AfterCommit(context *TM_EC.Context, fc func(*TM_EC.Context)) {
+	if afterCommits := getAfterCommitCallbacks(context.GetDB()); afterCommits != nil {
+		afterCommits.callbacks = append(afterCommits.callbacks, fc)
+		return
+	}
+
+	fc(context)
+}
+
+//	'AfterRollback' register fc to be run after the transaction of current context rollbacked, e.g. to clean up files stored in it, it is dropped if the context is not in a transaction started by 'Transaction'
+//	fc will be called with the context that started the transaction
+func AfterRollback(context *TM_EC.Context, fc func(*TM_EC.Context)) {
+	if afterCommits := getAfterCommitCallbacks(context.GetDB()); afterCommits != nil {
+		afterCommits.rollbacks = append(afterCommits.rollbacks, fc)
+	}
+}
+
+//	'IsNewRecord' return true if the record saving in current context was a new record, it is used in save callbacks to tell creating from updating
+func IsNewRecord(context *TM_EC.Context) bool {
+	if value, ok := context.GetDB().Get(newRecordSettingKey); ok {
+		if newRecord, ok := value.(bool); ok {
+			return newRecord
+		}
+	}
+	return false
+}
+
+type recordMetaValues struct {
+	record     interface{}
+	metaValues *MetaValues
+}
+
+//	'GetMetaValues' get meta values that decoded to the record processing in current context
+func GetMetaValues(context *TM_EC.Context) *MetaValues {
+	if value, ok := context.GetDB().Get(metaValuesSettingKey); ok {
+		if values, ok := value.(*recordMetaValues); ok {
+			return values.metaValues
+		}
+	}
+	return nil
+}
+
+//	'getRecordMetaValues' get meta values that decoded to the record, it is nil if the context's meta values are decoded to other records
+func getRecordMetaValues(context *TM_EC.Context, record interface{}) *MetaValues {
+	if value, ok := context.GetDB().Get(metaValuesSettingKey); ok {
+		if values, ok := value.(*recordMetaValues); ok {
+			decoded, current := reflect.ValueOf(values.record), reflect.ValueOf(record)
+			if decoded.Kind() == reflect.Ptr && current.Kind() == reflect.Ptr && decoded.Type() == current.Type() && decoded.Pointer() == current.Pointer() {
+				return values.metaValues
+			}
+		}
+	}
+	return nil
+}
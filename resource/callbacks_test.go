@@ -0,0 +1,151 @@
+package resource
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+func TestCallbacks(t *testing.T) {
+	res, context := newUserResource()
+
+	var calls []string
+	record := func(name string, err error) func(interface{}, *MetaValues, *TM_EC.Context) error {
+		return func(result interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	res.AddCallback(CallbackBeforeSave, "second", record("second", nil))
+	res.AddCallback(CallbackBeforeSave, "third", record("third", nil))
+	res.AddCallback(CallbackBeforeSave, "first", record("first", nil), -1)
+	res.AddCallback(CallbackAfterSave, "after_save", record("after_save", nil))
+	res.AddCallback(CallbackAfterSaveCommit, "after_commit", func(result interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		var count int
+		context.GetDB().Model(&User{}).Where("name = ?", result.(*User).Name).Count(&count)
+		calls = append(calls, "after_commit")
+		if count != 1 {
+			t.Errorf("after commit callback should be run after committed")
+		}
+		return nil
+	})
+
+	cases := []struct {
+		name  string
+		kind  CallbackKind
+		calls []string
+		saved bool
+	}{
+		{"saved", "", []string{"first", "second", "third", "after_save", "after_commit"}, true},
+		{"aborted", CallbackBeforeSave, []string{"first", "second"}, false},
+		{"rollbacked", CallbackAfterSave, []string{"first", "second", "third", "after_save"}, false},
+	}
+
+	for _, c := range cases {
+		calls = nil
+		if c.kind != "" {
+			res.AddCallback(c.kind, c.calls[len(c.calls)-1], record(c.calls[len(c.calls)-1], errors.New("failed")))
+		}
+
+		var (
+			count int
+			err   = res.CallSave(&User{Name: c.name}, context)
+		)
+
+		context.GetDB().Model(&User{}).Where("name = ?", c.name).Count(&count)
+		if (err == nil) != c.saved || (count == 1) != c.saved || !reflect.DeepEqual(calls, c.calls) {
+			t.Errorf("%v: expect calls %v, saved %v, but got %v, %v, %v", c.name, c.calls, c.saved, calls, err, count)
+		}
+
+		if c.kind != "" {
+			res.AddCallback(c.kind, c.calls[len(c.calls)-1], record(c.calls[len(c.calls)-1], nil))
+		}
+	}
+}
+
+func TestCallerTransactionAfterCommit(t *testing.T) {
+	res, context := newUserResource()
+
+	var committed []string
+	res.AddCallback(CallbackAfterSaveCommit, "after_commit", func(result interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		committed = append(committed, result.(*User).Name)
+		return nil
+	})
+
+	for _, commit := range []bool{true, false} {
+		committed = nil
+		txContext, err := BeginTransaction(context)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := res.CallSave(&User{Name: "a"}, txContext); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := Transaction(txContext, func(context *TM_EC.Context) error {
+			return res.CallSave(&User{Name: "b"}, context)
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(committed) != 0 {
+			t.Errorf("after commit callbacks should not be run before caller committed, but got %v", committed)
+		}
+
+		expect := []string{"a", "b"}
+		if commit {
+			CommitTransaction(txContext)
+		} else {
+			RollbackTransaction(txContext)
+			expect = nil
+		}
+
+		if !reflect.DeepEqual(committed, expect) {
+			t.Errorf("commit %v: expect after commit callbacks run for %v, but got %v", commit, expect, committed)
+		}
+
+		if _, ok := context.GetDB().CommonDB().(*sql.Tx); ok {
+			t.Errorf("commit %v: db of the context passed to 'BeginTransaction' should not be changed", commit)
+		}
+		context.GetDB().Delete(&User{})
+	}
+}
+
+func TestProcessorMetaValues(t *testing.T) {
+	res, context := newUserResource()
+
+	var got []*MetaValues
+	res.AddValidator(func(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		got = append(got, GetMetaValues(context))
+		return nil
+	})
+	res.AddCallback(CallbackBeforeSave, "meta_values", func(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		got = append(got, metaValues)
+		return nil
+	})
+
+	var (
+		user       User
+		metaValues = &MetaValues{Values: []*MetaValue{{Name: "Name", Value: "a"}}}
+	)
+
+	if err := DecodeToResource(res, &user, metaValues, context).Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if GetMetaValues(context) != nil {
+		t.Errorf("meta values should not be left in the context after decoded")
+	}
+
+	res.CallSave(&user, WithMetaValues(context, &user, metaValues))
+	res.CallSave(&User{Name: "b"}, WithMetaValues(context, &user, metaValues))
+
+	if !reflect.DeepEqual(got, []*MetaValues{metaValues, metaValues, nil}) {
+		t.Errorf("meta values should only be accessible for the decoded record, but got %v", got)
+	}
+}
@@ -8,38 +8,58 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"github.com/Sky-And-Hammer/TM_EC"
-	"github.com/Sky-And-Hammer/TM_EC/utils"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
 	"github.com/Sky-And-Hammer/roles"
 )
 
+//	'ErrForeignRecord' returned when a nested child is submitted with the primary key of a record that doesn't belong to its parent
+var ErrForeignRecord = errors.New("resource: record belongs to other parent")
+
 func (res *Resource) findOneHandler(result interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
 	if res.HasPermission(roles.Read, context) {
 		var (
-			scope        = context.GetDB().NewScope(res.Value)
-			primaryField = res.PrimaryField()
-			primaryKey   string
+			primaryQuerySQL string
+			primaryParams   []interface{}
 		)
 
 		if metaValues == nil {
-			primaryKey = context.ResourceID
-		} else if primaryField == nil {
+			var err error
+			if context.ResourceID != "" {
+				if primaryQuerySQL, primaryParams, err = res.ToPrimaryQueryParams(context.ResourceID, context); err != nil {
+					return err
+				}
+			}
+		} else if len(res.PrimaryFields) == 0 {
 			return nil
-		} else if id := metaValues.Get(primaryField.Name); id != nil {
-			primaryKey = utils.ToString(id.Value)
+		} else {
+			primaryQuerySQL, primaryParams = res.ToPrimaryQueryParamsFromMetaValue(metaValues, context)
 		}
 
-		if primaryKey != "" {
+		if primaryQuerySQL != "" {
+			var (
+				db         = context.GetDB()
+				conditions = append([]interface{}{primaryQuerySQL}, primaryParams...)
+			)
+
+			if scope, ok := db.Get(parentScopeSettingKey); ok {
+				//	nested children could only be found in, deleted from children of their parent
+				if db = scope.(func(*gorm.DB) *gorm.DB)(db); db.First(res.NewStruct(), conditions...).RecordNotFound() &&
+					!context.GetDB().First(res.NewStruct(), conditions...).RecordNotFound() {
+					return ErrForeignRecord
+				}
+			}
+
 			if metaValues != nil {
 				if destory := metaValues.Get("_destory"); destory != nil {
 					if fmt.Sprint(destory.Value) != "0" && res.HasPermission(roles.Delete, context) {
-						context.GetDB().Delete(result, fmt.Sprintf("%v = ?", scope.Quote(primaryField.DBName)), primaryKey)
+						db.Delete(result, conditions...)
 						return ErrProcessorSkipLeft
 					}
 				}
 			}
-			return context.GetDB().First(result, fmt.Sprintf("%v.%v = ?", scope.QuotedTableName(), scope.Quote(primaryField.DBName)), primaryKey).Error
+			return db.First(result, conditions...).Error
 		}
-		return errors.New("failed to find")
+		return i18n.NewError("ec.errors.not_found")
 	}
 	return roles.ErrPermissionDenied
 }
@@ -68,8 +88,17 @@ func (res *Resource) saveHandler(result interface{}, context *TM_EC.Context) err
 
 func (res *Resource) deleteHandler(result interface{}, context *TM_EC.Context) error {
 	if res.HasPermission(roles.Delete, context) {
-		scope := context.GetDB().NewScope(res.Value)
-		if !context.GetDB().First(result, fmt.Sprintf("%v = ?", scope.Quote(res.PrimaryDBName())), context.ResourceID).RecordNotFound() {
+		primaryValue := context.ResourceID
+		if primaryValue == "" {
+			primaryValue = res.GetPrimaryValue(result)
+		}
+
+		primaryQuerySQL, primaryParams, err := res.ToPrimaryQueryParams(primaryValue, context)
+		if err != nil {
+			return err
+		}
+
+		if !context.GetDB().First(result, append([]interface{}{primaryQuerySQL}, primaryParams...)...).RecordNotFound() {
 			return context.GetDB().Delete(result).Error
 		}
 		return gorm.ErrRecordNotFound
@@ -77,18 +106,112 @@ func (res *Resource) deleteHandler(result interface{}, context *TM_EC.Context) e
 	return roles.ErrPermissionDenied
 }
 
+//	'CallFindOne' call 'FindOneHandler' and run after find callbacks
 func (res *Resource) CallFindOne(result interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
-	return res.FindOneHandler(result, metaValues, context)
+	if err := res.FindOneHandler(result, metaValues, context); err != nil {
+		return err
+	}
+	return res.runCallbacks(CallbackAfterFind, result, metaValues, context)
 }
 
+//	'CallFindMany' call 'FindManyHandler' and run after find callbacks
 func (res *Resource) CallFindMany(result interface{}, context *TM_EC.Context) error {
-	return res.FindManyHandler(result, context)
+	if err := res.FindManyHandler(result, context); err != nil {
+		return err
+	}
+
+	if _, ok := context.GetDB().Get("ec:getting_total_count"); ok {
+		return nil
+	}
+	return res.runCallbacks(CallbackAfterFind, result, nil, context)
 }
 
+//	'CallSave' call 'SaveHandler' in a transaction, and run save callbacks around it, created or updated event will be published after committed, and published event too if a 'Publishable' record is published
+//	Callbacks get meta values that decoded to the record with the context, e.g. by 'DecodeToResource', meta values decoded to other records are ignored
 func (res *Resource) CallSave(result interface{}, context *TM_EC.Context) error {
-	return res.SaveHandler(result, context)
+	var (
+		metaValues = getRecordMetaValues(context, result)
+		newRecord  = context.GetDB().NewScope(result).PrimaryKeyZero()
+	)
+
+	return Transaction(context, func(context *TM_EC.Context) error {
+		context.SetDB(context.GetDB().Set(newRecordSettingKey, newRecord).Set(metaValuesSettingKey, &recordMetaValues{record: result, metaValues: metaValues}))
+		if err := res.runCallbacks(CallbackBeforeSave, result, metaValues, context); err != nil {
+			return err
+		}
+
+		wasPublished := !newRecord && res.isStoredPublished(result, context)
+
+		if err := res.SaveHandler(result, context); err != nil {
+			return err
+		}
+
+		if err := res.runCallbacks(CallbackAfterSave, result, metaValues, context); err != nil {
+			return err
+		}
+
+		res.runAfterCommitCallbacks(CallbackAfterSaveCommit, result, metaValues, context)
+		if newRecord {
+			res.publishEventAfterCommit(EventCreated, result, metaValues, context)
+		} else {
+			res.publishEventAfterCommit(EventUpdated, result, metaValues, context)
+		}
+
+		if publishable, ok := result.(Publishable); ok && publishable.IsPublished() && !wasPublished {
+			res.publishEventAfterCommit(EventPublished, result, metaValues, context)
+		}
+		return nil
+	})
 }
 
+//	'isStoredPublished' check the stored record is published or not, it is false if the record is not 'Publishable'
+func (res *Resource) isStoredPublished(record interface{}, context *TM_EC.Context) bool {
+	if _, ok := record.(Publishable); !ok {
+		return false
+	}
+
+	primaryQuerySQL, primaryParams, err := res.ToPrimaryQueryParams(res.GetPrimaryValue(record), context)
+	if err != nil {
+		return false
+	}
+
+	stored := res.NewStruct()
+	if context.GetDB().First(stored, append([]interface{}{primaryQuerySQL}, primaryParams...)...).Error != nil {
+		return false
+	}
+
+	publishable, ok := stored.(Publishable)
+	return ok && publishable.IsPublished()
+}
+
+//	'CallDelete' call 'DeleteHandler' in a transaction, and run delete callbacks around it, deleted event will be published after committed
+//	Like 'CallSave', callbacks only get meta values that decoded to the record
 func (res *Resource) CallDelete(result interface{}, context *TM_EC.Context) error {
-	return res.DeleteHandler(result, context)
+	var metaValues = getRecordMetaValues(context, result)
+
+	return Transaction(context, func(context *TM_EC.Context) error {
+		context.SetDB(context.GetDB().Set(metaValuesSettingKey, &recordMetaValues{record: result, metaValues: metaValues}))
+		if res.GetPrimaryValue(result) == "" && context.ResourceID != "" {
+			//	load the record, so before delete callbacks could check it
+			if primaryQuerySQL, primaryParams, err := res.ToPrimaryQueryParams(context.ResourceID, context); err == nil {
+				context.GetDB().First(result, append([]interface{}{primaryQuerySQL}, primaryParams...)...)
+			}
+		}
+
+		if err := res.runCallbacks(CallbackBeforeDelete, result, metaValues, context); err != nil {
+			return err
+		}
+
+		if err := res.DeleteHandler(result, context); err != nil {
+			return err
+		}
+
+		if err := res.runCallbacks(CallbackAfterDelete, result, metaValues, context); err != nil {
+			return err
+		}
+
+		res.runAfterCommitCallbacks(CallbackAfterDeleteCommit, result, metaValues, context)
+		res.publishEventAfterCommit(EventDeleted, result, metaValues, context)
+		return nil
+	})
 }
@@ -0,0 +1,89 @@
+package resource
+
+import (
+	"testing"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type OrderLine struct {
+	OrderID  uint   `gorm:"primary_key;auto_increment:false"`
+	LineNo   int    `gorm:"primary_key;auto_increment:false"`
+	Sku      string `gorm:"primary_key"`
+	Quantity int
+}
+
+type Voucher struct {
+	UUID string `gorm:"primary_key"`
+	Code string
+}
+
+func TestPrimaryValueEncoding(t *testing.T) {
+	res := New(&OrderLine{})
+	if len(res.PrimaryFields) != 3 {
+		t.Fatalf("should have 3 primary fields, but got %v", len(res.PrimaryFields))
+	}
+
+	line := OrderLine{OrderID: 1001, LineNo: 3, Sku: "a,b c"}
+	primaryValue := res.GetPrimaryValue(&line)
+	if primaryValue != "1001,3,a%2Cb+c" {
+		t.Errorf("composite primary value is not encoded correctly, got %v", primaryValue)
+	}
+
+	if values, err := DecodePrimaryValue(primaryValue, 3); err != nil || values[2] != "a,b c" {
+		t.Errorf("failed to decode primary value, got %v, %v", values, err)
+	}
+
+	if _, err := DecodePrimaryValue("1001,3", 3); err == nil {
+		t.Errorf("should return error if primary value doesn't have enough parts")
+	}
+
+	if primaryValue := res.GetPrimaryValue(&OrderLine{OrderID: 1001, Sku: "A"}); primaryValue != "1001,0,A" {
+		t.Errorf("zero primary field should be encoded, got %v", primaryValue)
+	}
+
+	if primaryValue := res.GetPrimaryValue(&OrderLine{}); primaryValue != "" {
+		t.Errorf("primary value should be blank if all primary fields are blank, got %v", primaryValue)
+	}
+}
+
+func TestCompositePrimaryKeysCRUD(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&OrderLine{}, &Voucher{})
+	db.AutoMigrate(&OrderLine{}, &Voucher{})
+
+	db.Create(&OrderLine{OrderID: 1, LineNo: 1, Sku: "A", Quantity: 1})
+	db.Create(&OrderLine{OrderID: 1, LineNo: 2, Sku: "A", Quantity: 2})
+	db.Create(&OrderLine{OrderID: 2, LineNo: 1, Sku: "A", Quantity: 3})
+	db.Create(&Voucher{UUID: "8b4ba5b3-8fa0-4d4e-9bb8-3f1b7e2c0a11", Code: "HELLO"})
+
+	res := New(&OrderLine{})
+	context := &TM_EC.Context{Config: &TM_EC.Config{DB: db}, ResourceID: "1,2,A"}
+
+	var line OrderLine
+	if err := res.CallFindOne(&line, nil, context); err != nil || line.Quantity != 2 {
+		t.Errorf("should find order line by composite id, but got %+v, %v", line, err)
+	}
+
+	line = OrderLine{}
+	metaValues := &MetaValues{Values: []*MetaValue{{Name: "OrderID", Value: "2"}, {Name: "LineNo", Value: []string{"1"}}, {Name: "Sku", Value: "A"}}}
+	if err := res.CallFindOne(&line, metaValues, context); err != nil || line.Quantity != 3 {
+		t.Errorf("should find order line by meta values, but got %+v, %v", line, err)
+	}
+
+	if err := res.CallDelete(&OrderLine{}, context); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if db.Model(&OrderLine{}).Count(&count); count != 2 {
+		t.Errorf("should only delete one order line, but %v left", count)
+	}
+
+	var voucher Voucher
+	context.ResourceID = "8b4ba5b3-8fa0-4d4e-9bb8-3f1b7e2c0a11"
+	if err := New(&Voucher{}).CallFindOne(&voucher, nil, context); err != nil || voucher.Code != "HELLO" {
+		t.Errorf("should find voucher by uuid, but got %+v, %v", voucher, err)
+	}
+}
@@ -0,0 +1,117 @@
+package resource
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+var decimalColumnRegexp = regexp.MustCompile(`(?i)(?:decimal|numeric)\s*\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)`)
+
+//	'isDecimalType' check the type is a string backed decimal type, like 'decimal.Decimal'
+//	It should implement sql.Scanner and driver.Valuer, and its zero value should be a numeric string
+func isDecimalType(fieldType reflect.Type) (result bool) {
+	value := reflect.New(fieldType)
+	if _, ok := value.Interface().(sql.Scanner); !ok {
+		return false
+	}
+
+	valuer, ok := value.Elem().Interface().(driver.Valuer)
+	if !ok {
+		return false
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = false
+		}
+	}()
+
+	if v, err := valuer.Value(); err == nil {
+		if str, ok := v.(string); ok {
+			_, err = decimal.NewFromString(str)
+			return err == nil
+		}
+	}
+	return false
+}
+
+//	'columnPrecision' get precision and scale from field's sql type, e.g. `sql:"type:decimal(10,2)"`
+func columnPrecision(field *gorm.StructField) (precision int, scale int, ok bool) {
+	if field == nil {
+		return 0, 0, false
+	}
+
+	if matches := decimalColumnRegexp.FindStringSubmatch(field.TagSettings["TYPE"]); len(matches) > 0 {
+		precision, _ = strconv.Atoi(matches[1])
+		scale, _ = strconv.Atoi(matches[2])
+		return precision, scale, true
+	}
+	return 0, 0, false
+}
+
+//	'parseDecimal' parse decimal from submitted value, and validate it with precision and scale of its column
+func (meta *Meta) parseDecimal(value interface{}, context *TM_EC.Context) (decimal.Decimal, error) {
+	d, err := utils.ToDecimal(value, context)
+	if err != nil {
+		return d, i18n.NewError("ec.errors.invalid_number", utils.ToString(value))
+	}
+
+	if precision, scale, ok := columnPrecision(meta.FieldStruct); ok {
+		if d.Scale() > scale {
+			if rounded := d.Rescale(scale); rounded.Equal(d) {
+				d = rounded
+			} else {
+				return d, i18n.NewError("ec.errors.too_many_decimal_places", d, scale)
+			}
+		}
+
+		if d.Precision()-d.Scale() > precision-scale {
+			return d, i18n.NewError("ec.errors.out_of_range", d, precision-scale)
+		}
+	}
+	return d, nil
+}
+
+//	'decimalFormattedValuer' formatted valuer for decimal, money and float fields with decimal column, values are formatted with context's locale and column's scale
+//	valuer is got when formatting, as it is wrapped for nested fields after initialized
+func (meta *Meta) decimalFormattedValuer() func(interface{}, *TM_EC.Context) interface{} {
+	_, scale, hasScale := columnPrecision(meta.FieldStruct)
+
+	return func(record interface{}, context *TM_EC.Context) interface{} {
+		value := meta.Valuer(record, context)
+		if reflect.ValueOf(value).Kind() == reflect.Ptr {
+			if reflect.ValueOf(value).IsNil() {
+				return ""
+			}
+			value = reflect.ValueOf(value).Elem().Interface()
+		}
+
+		if money, ok := value.(decimal.Money); ok {
+			if hasScale {
+				money.Amount = money.Amount.Rescale(scale)
+			}
+			return money.Format(utils.GetLocale(context))
+		}
+
+		d, err := utils.ToDecimal(value, context)
+		if err != nil {
+			return value
+		}
+
+		if hasScale {
+			d = d.Rescale(scale)
+		}
+		return d.Format(utils.GetLocale(context))
+	}
+}
@@ -0,0 +1,97 @@
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type Invoice struct {
+	gorm.Model
+	Total    decimal.Decimal `sql:"type:decimal(8,2)"`
+	Rate     float64         `sql:"type:decimal(5,3)"`
+	Deposit  *decimal.Decimal
+	Balance  decimal.Money
+	Shipping InvoiceShipping
+}
+
+type InvoiceShipping struct {
+	Total decimal.Decimal `sql:"type:decimal(8,2)"`
+}
+
+func TestDecimalSetter(t *testing.T) {
+	invoiceRes := New(&Invoice{})
+	metas := map[string]Metaor{}
+	for _, name := range []string{"Total", "Rate", "Deposit", "Balance", "Shipping.Total"} {
+		metas[name] = newTestMeta(&Meta{Name: name, Resource: invoiceRes}, nil)
+	}
+
+	request, _ := http.NewRequest("POST", "/invoices", nil)
+	request.Header.Set("Locale", "de")
+
+	cases := []struct {
+		meta      string
+		value     interface{}
+		hasError  bool
+		formatted string
+	}{
+		{"Total", "1.234,5", false, "1.234,50"},
+		{"Total", "0,125", true, ""},
+		{"Total", "1.000.000", true, ""},
+		{"Total", "12,500", false, "12,50"},
+		{"Rate", "0,1", false, "0,100"},
+		{"Deposit", "", false, ""},
+		{"Deposit", "99,99", false, "99,99"},
+		{"Balance", "EUR 1.234,56", false, "EUR 1.234,56"},
+		{"Balance", "EUR abc", true, ""},
+		{"Shipping.Total", "3,5", false, "3,50"},
+	}
+
+	for _, c := range cases {
+		var (
+			invoice Invoice
+			meta    = metas[c.meta]
+			context = &TM_EC.Context{Request: request, Config: &TM_EC.Config{DB: utils.TestDB()}}
+		)
+
+		meta.GetSetter()(&invoice, &MetaValue{Name: c.meta, Value: c.value, Meta: meta}, context)
+		if context.HasError() != c.hasError {
+			t.Errorf("meta %v with value %v: expect has error %v, but got %v", c.meta, c.value, c.hasError, context.GetErrors())
+			continue
+		}
+
+		if !c.hasError {
+			if formatted := meta.GetFormattedValuer()(&invoice, context); formatted != c.formatted {
+				t.Errorf("meta %v with value %v: expect formatted value %#v, but got %#v", c.meta, c.value, c.formatted, formatted)
+			}
+		}
+	}
+}
+
+func TestJSONNumbers(t *testing.T) {
+	invoiceRes := New(&Invoice{})
+	metas := []Metaor{
+		newTestMeta(&Meta{Name: "Total", Resource: invoiceRes}, nil),
+		newTestMeta(&Meta{Name: "Rate", Resource: invoiceRes}, nil),
+		newTestMeta(&Meta{Name: "ID", Resource: invoiceRes}, nil),
+	}
+	metaValues, err := ConvertJSONToMetaValues(strings.NewReader(`{"Total": 0.1, "Rate": 0.125, "ID": 1}`), metas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expects := map[string]interface{}{"Total": json.Number("0.1"), "Rate": json.Number("0.125"), "ID": float64(1)}
+	for name, expect := range expects {
+		if value := metaValues.Get(name).Value; value != expect {
+			t.Errorf("meta %v: only numbers of decimal metas should be kept as json.Number, expect %#v, but got %#v", name, expect, value)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package resource
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	//  YAML support for the Go language.
+	"gopkg.in/yaml.v2"
+)
+
+//	'Decoder' convert request to meta values
+type Decoder func(request *http.Request, metaors []Metaor) (*MetaValues, error)
+
+var (
+	decoders      = map[string]Decoder{}
+	decodersMutex sync.RWMutex
+)
+
+func init() {
+	jsonDecoder := func(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+		return ConvertJSONToMetaValues(request.Body, metaors)
+	}
+
+	xmlDecoder := func(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+		return ConvertXMLToMetaValues(request.Body, metaors)
+	}
+
+	yamlDecoder := func(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+		return ConvertYAMLToMetaValues(request.Body, metaors)
+	}
+
+	RegisterDecoder("application/json", jsonDecoder)
+	RegisterDecoder("application/merge-patch+json", func(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+		return ConvertMergePatchToMetaValues(request.Body, metaors)
+	})
+	RegisterDecoder("application/json-patch+json", func(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+		return ConvertJSONPatchToMetaValues(request.Body, metaors)
+	})
+	RegisterDecoder("application/xml", xmlDecoder)
+	RegisterDecoder("text/xml", xmlDecoder)
+	RegisterDecoder("application/yaml", yamlDecoder)
+	RegisterDecoder("application/x-yaml", yamlDecoder)
+	RegisterDecoder("text/yaml", yamlDecoder)
+	RegisterDecoder("text/csv", func(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+		results, err := ConvertCSVToMetaValues(request.Body, metaors)
+		if err == nil && len(results) == 0 {
+			err = io.ErrUnexpectedEOF
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		return results[0], nil
+	})
+	RegisterDecoder("application/x-www-form-urlencoded", formDecoder)
+	RegisterDecoder("multipart/form-data", formDecoder)
+}
+
+func formDecoder(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+	return ConvertFormToMetaValues(request, metaors, "ECResource.")
+}
+
+//	'RegisterDecoder' register decoder for a media type, e.g. "application/xml", registered decoder of same media type will be replaced
+func RegisterDecoder(mediaType string, decoder Decoder) {
+	decodersMutex.Lock()
+	decoders[strings.ToLower(mediaType)] = decoder
+	decodersMutex.Unlock()
+}
+
+//	'GetDecoder' get decoder of the request by its Content-Type header, structured syntax suffixes are supported, e.g. "application/vnd.erp+xml" uses decoder of "application/xml"
+//	Requests with unknown content type are decoded as form, requests without content type and parsed form are decoded from URL query
+func GetDecoder(request *http.Request) Decoder {
+	contentType := request.Header.Get("Content-Type")
+	if contentType == "" && request.Form == nil {
+		return func(request *http.Request, metaors []Metaor) (*MetaValues, error) {
+			return ConvertQueryToMetaValues(request.URL.Query(), metaors, "")
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return formDecoder
+	}
+
+	decodersMutex.RLock()
+	defer decodersMutex.RUnlock()
+	if decoder, ok := decoders[mediaType]; ok {
+		return decoder
+	}
+
+	if idx := strings.LastIndex(mediaType, "+"); idx >= 0 {
+		if decoder, ok := decoders["application/"+mediaType[idx+1:]]; ok {
+			return decoder
+		}
+	}
+	return formDecoder
+}
+
+//	'ConvertQueryToMetaValues' convert URL query to meta values, keys are same as form, e.g. "Items[0].Quantity"
+func ConvertQueryToMetaValues(query url.Values, metaors []Metaor, prefix string) (*MetaValues, error) {
+	return convertValuesToMetaValues(query, nil, nil, metaors, prefix)
+}
+
+//	'CSVNullValue' cells of CSV with this value are converted to null values, empty cells are empty strings
+var CSVNullValue = `\N`
+
+//	'ConvertCSVToMetaValues' convert rows of CSV to meta values, the first row is header, its columns are same as form keys, e.g. "Items[0].Quantity"
+//	Missing cells of rows shorter than header are absent, so the fields will be kept untouched
+func ConvertCSVToMetaValues(reader io.Reader, metaors []Metaor) (results []*MetaValues, err error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return results, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		metaValues, err := ConvertCSVRecordToMetaValues(header, record, metaors)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, metaValues)
+	}
+}
+
+//	'ConvertCSVRecordToMetaValues' convert a row of CSV to meta values with header
+func ConvertCSVRecordToMetaValues(header []string, record []string, metaors []Metaor) (*MetaValues, error) {
+	var (
+		values = url.Values{}
+		nulls  = map[string]bool{}
+	)
+
+	for idx, name := range header {
+		name = strings.TrimSpace(name)
+		if idx >= len(record) {
+			values[name] = []string{}
+			continue
+		}
+
+		values[name] = []string{record[idx]}
+		nulls[name] = record[idx] == CSVNullValue
+	}
+	return convertValuesToMetaValues(values, nil, nulls, metaors, "")
+}
+
+//	'ConvertXMLToMetaValues' convert xml to meta values, child elements of the root element are converted to meta values by their names
+//	Repeated elements are converted to meta values with 'Index', elements with child elements or only attributes are converted to nested meta values, attributes are treated as child elements
+//		<Order><Code>A1</Code><Items><Quantity>1</Quantity></Items><Items><Quantity>2</Quantity></Items></Order>
+func ConvertXMLToMetaValues(reader io.Reader, metaors []Metaor) (*MetaValues, error) {
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+
+			values, ok := value.(map[string]interface{})
+			if !ok {
+				values = map[string]interface{}{}
+			}
+			return converMapToMetaValues(values, metaors)
+		}
+	}
+}
+
+//	'decodeXMLElement' decode element to map if it has child elements or only attributes, otherwise to its text
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	var (
+		values      = map[string]interface{}{}
+		text        strings.Builder
+		hasChildren bool
+	)
+
+	add := func(name string, value interface{}) {
+		switch existing := values[name].(type) {
+		case nil:
+			values[name] = value
+		case []interface{}:
+			values[name] = append(existing, value)
+		default:
+			values[name] = []interface{}{existing, value}
+		}
+	}
+
+	for _, attr := range start.Attr {
+		add(attr.Name.Local, attr.Value)
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			hasChildren = true
+			add(t.Name.Local, value)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			str := strings.TrimSpace(text.String())
+			if hasChildren || len(start.Attr) > 0 && str == "" {
+				return values, nil
+			}
+			return str, nil
+		}
+	}
+}
+
+//	'ConvertYAMLToMetaValues' convert yaml to meta values, it is converted same as json
+func ConvertYAMLToMetaValues(reader io.Reader, metaors []Metaor) (*MetaValues, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var values interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	switch result := normalizeYAMLValue(values).(type) {
+	case map[string]interface{}:
+		return converMapToMetaValues(result, metaors)
+	case nil:
+		return &MetaValues{}, nil
+	default:
+		return nil, fmt.Errorf("resource: yaml should be a mapping, but got %T", result)
+	}
+}
+
+//	'normalizeYAMLValue' convert yaml mappings to map[string]interface{}, numbers to json.Number, so they are same as decoded json
+func normalizeYAMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for key, value := range v {
+			result[fmt.Sprint(key)] = normalizeYAMLValue(value)
+		}
+		return result
+	case []interface{}:
+		for idx, value := range v {
+			v[idx] = normalizeYAMLValue(value)
+		}
+		return v
+	case int:
+		return json.Number(strconv.Itoa(v))
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	return value
+}
@@ -0,0 +1,49 @@
+package resource
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecoders(t *testing.T) {
+	cases := []struct {
+		contentType string
+		target      string
+		body        string
+	}{
+		{"application/json", "/users", `{"Name": "jinzhu", "Profile": {"Nickname": "jz"}, "Addresses": [{"Address1": "Shanghai"}, {"Address1": "Beijing"}]}`},
+		{"application/xml; charset=utf-8", "/users", `<?xml version="1.0"?>
+<User Name="jinzhu">
+	<Profile><Nickname>jz</Nickname></Profile>
+	<Addresses><Address1>Shanghai</Address1></Addresses>
+	<Addresses Address1="Beijing"/>
+</User>`},
+		{"application/vnd.erp+xml", "/users", `<User><Name>jinzhu</Name><Profile Nickname="jz"/><Addresses><Address1>Shanghai</Address1></Addresses><Addresses><Address1>Beijing</Address1></Addresses></User>`},
+		{"application/x-yaml", "/users", "Name: jinzhu\nProfile:\n  Nickname: jz\nAddresses:\n  - Address1: Shanghai\n  - Address1: Beijing\n"},
+		{"", "/users?Name=jinzhu&Profile.Nickname=jz&Addresses[0].Address1=Shanghai&Addresses[1].Address1=Beijing", ""},
+	}
+
+	for _, c := range cases {
+		res, context := newUserResource()
+		context.Request = httptest.NewRequest("POST", c.target, strings.NewReader(c.body))
+		if c.contentType != "" {
+			context.Request.Header.Set("Content-Type", c.contentType)
+		}
+
+		var user User
+		if err := Decode(context, &user, res); err != nil {
+			t.Errorf("failed to decode %v: %v", c.contentType, err)
+			continue
+		}
+
+		if user.Name != "jinzhu" || user.Profile.Nickname != "jz" || len(user.Addresses) != 2 ||
+			user.Addresses[0].Address1 != "Shanghai" || user.Addresses[1].Address1 != "Beijing" {
+			t.Errorf("decoded user of %q is not correct, got %+v", c.contentType, user)
+		}
+	}
+
+	if _, err := ConvertYAMLToMetaValues(strings.NewReader("- a\n- b\n"), nil); err == nil {
+		t.Errorf("should return error for yaml that is not a mapping")
+	}
+}
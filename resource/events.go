@@ -0,0 +1,339 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+//	'EventType' type of resource events
+type EventType string
+
+const (
+	//	'EventCreated' published after a new record saved
+	EventCreated EventType = "created"
+	//	'EventUpdated' published after an existing record saved
+	EventUpdated EventType = "updated"
+	//	'EventDeleted' published after a record deleted
+	EventDeleted EventType = "deleted"
+	//	'EventPublished' published after a 'Publishable' record saved as published, which was not published before
+	EventPublished EventType = "published"
+)
+
+//	'Publishable' records implement it will publish 'EventPublished' when they are published, e.g.
+//		func (article Article) IsPublished() bool { return article.PublishedAt != nil }
+type Publishable interface {
+	IsPublished() bool
+}
+
+var (
+	//	'ErrEventBusClosed' returned when publish events to a closed event bus
+	ErrEventBusClosed = errors.New("resource: event bus closed")
+	//	'ErrEventQueueFull' returned when an async subscriber's queue is still full after its timeout
+	ErrEventQueueFull = errors.New("resource: event queue full")
+)
+
+//	'DefaultEventBus' event bus used by resources that haven't configured 'EventBus'
+var DefaultEventBus = NewEventBus()
+
+//	'Event' a resource change event
+type Event struct {
+	Type       EventType
+	Resource   string
+	PrimaryKey string
+	Changes    []string
+	Record     interface{}
+	User       TM_EC.CurrentUser
+	CreatedAt  time.Time
+}
+
+//	'EventFilter' used to filter events when subscribe, blank field means match all
+type EventFilter struct {
+	Resources []string
+	Types     []EventType
+}
+
+//	'Match' check the event match the filter or not
+func (filter EventFilter) Match(event Event) bool {
+	if len(filter.Resources) > 0 {
+		var matched bool
+		for _, name := range filter.Resources {
+			if name == event.Resource {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	if len(filter.Types) > 0 {
+		for _, typ := range filter.Types {
+			if typ == event.Type {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+//	'AsyncConfig' config of an async subscriber
+//	'QueueSize' is the size of its bounded queue, when it is full, 'Publish' will block up to 'Timeout' until there is room, zero 'Timeout' means block until there is room
+type AsyncConfig struct {
+	QueueSize int
+	Workers   int
+	Timeout   time.Duration
+}
+
+type eventSubscriber struct {
+	id      int
+	filter  EventFilter
+	handler func(Event) error
+	async   *AsyncConfig
+	queue   chan Event
+	done    chan struct{}
+	once    sync.Once
+}
+
+//	'stop' stop accepting events, queued events will still be handled by workers
+func (subscriber *eventSubscriber) stop() {
+	if subscriber.done != nil {
+		subscriber.once.Do(func() { close(subscriber.done) })
+	}
+}
+
+func (bus *EventBus) work(subscriber *eventSubscriber) {
+	defer bus.workers.Done()
+
+	handle := func(event Event) {
+		if err := subscriber.handler(event); err != nil && bus.ErrorHandler != nil {
+			bus.ErrorHandler(event, err)
+		}
+	}
+
+	for {
+		select {
+		case event := <-subscriber.queue:
+			handle(event)
+		case <-subscriber.done:
+			for {
+				select {
+				case event := <-subscriber.queue:
+					handle(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+//	'EventBus' in-process event bus, subscribers could handle events synchronously in publisher's goroutine, or asynchronously with bounded queues
+type EventBus struct {
+	// 'ErrorHandler' will be called with errors returned by async handlers
+	ErrorHandler func(Event, error)
+	mutex        sync.RWMutex
+	subscribers  []*eventSubscriber
+	lastID       int
+	closed       bool
+	workers      sync.WaitGroup
+}
+
+//	'NewEventBus' initialize an event bus
+func NewEventBus() *EventBus {
+	return &EventBus{}
+}
+
+//	'Subscribe' register a handler that will be called synchronously when publishing matched events, errors returned by it will be returned by 'Publish'
+//	it returns an id could be used to unsubscribe it
+func (bus *EventBus) Subscribe(filter EventFilter, handler func(Event) error) int {
+	return bus.subscribe(&eventSubscriber{filter: filter, handler: handler})
+}
+
+//	'SubscribeAsync' register a handler that will be called in background workers, events are buffered in a bounded queue
+func (bus *EventBus) SubscribeAsync(filter EventFilter, handler func(Event) error, config AsyncConfig) int {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 100
+	}
+
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+
+	subscriber := &eventSubscriber{filter: filter, handler: handler, async: &config, queue: make(chan Event, config.QueueSize), done: make(chan struct{})}
+	id := bus.subscribe(subscriber)
+
+	for i := 0; i < config.Workers; i++ {
+		bus.workers.Add(1)
+		go bus.work(subscriber)
+	}
+	return id
+}
+
+func (bus *EventBus) subscribe(subscriber *eventSubscriber) int {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.lastID++
+	subscriber.id = bus.lastID
+	bus.subscribers = append(bus.subscribers, subscriber)
+	return subscriber.id
+}
+
+//	'Unsubscribe' remove the subscriber, events already in its queue will still be handled
+func (bus *EventBus) Unsubscribe(id int) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	for idx, subscriber := range bus.subscribers {
+		if subscriber.id == id {
+			subscriber.stop()
+			bus.subscribers = append(bus.subscribers[:idx], bus.subscribers[idx+1:]...)
+			return
+		}
+	}
+}
+
+//	'Publish' publish event to matched subscribers
+//	Subscribers are called without holding the bus's lock, so handlers could subscribe or publish, and blocked publishers won't block 'Close'
+func (bus *EventBus) Publish(event Event) error {
+	bus.mutex.RLock()
+	if bus.closed {
+		bus.mutex.RUnlock()
+		return ErrEventBusClosed
+	}
+
+	var subscribers []*eventSubscriber
+	for _, subscriber := range bus.subscribers {
+		if subscriber.filter.Match(event) {
+			subscribers = append(subscribers, subscriber)
+		}
+	}
+	bus.mutex.RUnlock()
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	var errs TM_EC.Errors
+	for _, subscriber := range subscribers {
+		if subscriber.async == nil {
+			errs.AddError(subscriber.handler(event))
+			continue
+		}
+
+		select {
+		case subscriber.queue <- event:
+			continue
+		case <-subscriber.done:
+			errs.AddError(ErrEventBusClosed)
+			continue
+		default:
+		}
+
+		//	block until there is room, zero timeout means no timeout
+		var (
+			timer   *time.Timer
+			timeout <-chan time.Time
+		)
+		if subscriber.async.Timeout > 0 {
+			timer = time.NewTimer(subscriber.async.Timeout)
+			timeout = timer.C
+		}
+
+		select {
+		case subscriber.queue <- event:
+		case <-subscriber.done:
+			errs.AddError(ErrEventBusClosed)
+		case <-timeout:
+			errs.AddError(ErrEventQueueFull)
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+
+	if errs.HasError() {
+		return errs
+	}
+	return nil
+}
+
+//	'Close' stop accepting new events, and wait queued events handled by async subscribers, publishers blocked by full queues will get 'ErrEventBusClosed'
+//	it will return an error if queues are not drained in the timeout, zero timeout means wait until drained
+func (bus *EventBus) Close(timeout time.Duration) error {
+	bus.mutex.Lock()
+	if !bus.closed {
+		bus.closed = true
+		for _, subscriber := range bus.subscribers {
+			subscriber.stop()
+		}
+		bus.subscribers = nil
+	}
+	bus.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		bus.workers.Wait()
+		close(done)
+	}()
+
+	if timeout <= 0 {
+		<-done
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("resource: event bus not drained in %v", timeout)
+	}
+}
+
+//	'GetEventBus' get event bus of the resource
+func (res *Resource) GetEventBus() *EventBus {
+	if res.EventBus != nil {
+		return res.EventBus
+	}
+	return DefaultEventBus
+}
+
+//	'PublishEvent' publish event of the record to resource's event bus, metaValues is used to get changed metas, it could be nil
+func (res *Resource) PublishEvent(eventType EventType, record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+	event := Event{
+		Type:       eventType,
+		Resource:   res.Name,
+		PrimaryKey: res.GetPrimaryValue(record),
+		Record:     record,
+		User:       context.CurrentUser,
+	}
+
+	if metaValues != nil {
+		event.Changes = metaValues.Changes()
+	}
+
+	return res.GetEventBus().Publish(event)
+}
+
+func (res *Resource) publishEventAfterCommit(eventType EventType, record interface{}, metaValues *MetaValues, context *TM_EC.Context) {
+	AfterCommit(context, func(context *TM_EC.Context) {
+		context.AddError(res.PublishEvent(eventType, record, metaValues, context))
+	})
+}
+
+func stringInSlice(str string, strs []string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
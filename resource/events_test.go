@@ -0,0 +1,219 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+func TestEventBusFilter(t *testing.T) {
+	var (
+		bus     = NewEventBus()
+		handled []Event
+	)
+
+	bus.Subscribe(EventFilter{Resources: []string{"Order"}, Types: []EventType{EventCreated, EventDeleted}}, func(event Event) error {
+		handled = append(handled, event)
+		return nil
+	})
+
+	cases := []struct {
+		event Event
+		want  bool
+	}{
+		{Event{Resource: "Order", Type: EventCreated}, true},
+		{Event{Resource: "Order", Type: EventUpdated}, false},
+		{Event{Resource: "Product", Type: EventCreated}, false},
+		{Event{Resource: "Order", Type: EventDeleted}, true},
+	}
+
+	for _, c := range cases {
+		count := len(handled)
+		bus.Publish(c.event)
+		if got := len(handled) > count; got != c.want {
+			t.Errorf("Publish(%v %v) handled = %v, want %v", c.event.Resource, c.event.Type, got, c.want)
+		}
+	}
+}
+
+func TestEventBusAsyncClose(t *testing.T) {
+	var (
+		bus     = NewEventBus()
+		handled int32
+		release = make(chan struct{})
+	)
+
+	bus.SubscribeAsync(EventFilter{}, func(event Event) error {
+		<-release
+		atomic.AddInt32(&handled, 1)
+		return nil
+	}, AsyncConfig{QueueSize: 2, Timeout: 10 * time.Millisecond})
+
+	// one event is taken by the worker, two are queued, the last one should hit backpressure timeout
+	var errs int
+	for i := 0; i < 4; i++ {
+		if err := bus.Publish(Event{Type: EventCreated}); err != nil {
+			errs++
+		}
+	}
+
+	if errs != 1 {
+		t.Errorf("should get one queue full error, but got %v", errs)
+	}
+
+	close(release)
+	if err := bus.Close(time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 3 {
+		t.Errorf("queued events should be handled before closed, but handled %v", handled)
+	}
+
+	if err := bus.Publish(Event{}); err != ErrEventBusClosed {
+		t.Errorf("publish to closed bus should return ErrEventBusClosed, but got %v", err)
+	}
+}
+
+func TestEventBusCloseBlockedPublisher(t *testing.T) {
+	var (
+		bus       = NewEventBus()
+		release   = make(chan struct{})
+		published = make(chan error)
+	)
+
+	bus.SubscribeAsync(EventFilter{}, func(event Event) error {
+		<-release
+		return nil
+	}, AsyncConfig{QueueSize: 1})
+
+	bus.Publish(Event{Type: EventCreated})
+	bus.Publish(Event{Type: EventCreated})
+	go func() {
+		published <- bus.Publish(Event{Type: EventCreated})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan error)
+	go func() {
+		closed <- bus.Close(50 * time.Millisecond)
+	}()
+
+	select {
+	case err := <-closed:
+		if err == nil {
+			t.Errorf("close should be timed out as queue is not drained")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("close should not be blocked by blocked publishers")
+	}
+
+	if err := <-published; err == nil || err.Error() != ErrEventBusClosed.Error() {
+		t.Errorf("blocked publisher should get ErrEventBusClosed, but got %v", err)
+	}
+	close(release)
+}
+
+type Article struct {
+	gorm.Model
+	Title     string
+	Published bool
+}
+
+func (article Article) IsPublished() bool {
+	return article.Published
+}
+
+type currentUser string
+
+func (user currentUser) DisplayName() string {
+	return string(user)
+}
+
+func TestResourceEvents(t *testing.T) {
+	_, context := newUserResource()
+	context.CurrentUser = currentUser("jinzhu")
+	context.GetDB().DropTableIfExists(&Article{})
+	context.GetDB().AutoMigrate(&Article{})
+
+	var (
+		events []string
+		res    = New(&Article{})
+	)
+
+	res.EventBus = NewEventBus()
+	res.EventBus.Subscribe(EventFilter{}, func(event Event) error {
+		if event.Resource != "Article" || event.PrimaryKey == "" || event.User != context.CurrentUser {
+			t.Errorf("event should have resource, primary key and user, but got %+v", event)
+		}
+		events = append(events, string(event.Type)+" "+event.Record.(*Article).Title)
+		return nil
+	})
+
+	article := Article{Title: "draft"}
+	metaValues := &MetaValues{Values: []*MetaValue{{Name: "Title", Value: "draft"}}}
+	DecodeToResource(res, &article, metaValues, context).Start()
+	res.CallSave(&article, context)
+
+	article.Title, article.Published = "published", true
+	res.CallSave(&article, context)
+
+	article.Title = "updated"
+	res.CallSave(&article, context)
+
+	Transaction(context, func(context *TM_EC.Context) error {
+		res.CallSave(&Article{Title: "rollbacked"}, context)
+		return errors.New("rollback")
+	})
+
+	res.AddCallback(CallbackAfterSave, "fail", func(interface{}, *MetaValues, *TM_EC.Context) error {
+		return errors.New("failed")
+	})
+	res.CallSave(&Article{Title: "failed"}, context)
+	res.RemoveCallback(CallbackAfterSave, "fail")
+
+	res.CallDelete(&article, context)
+
+	expects := []string{"created draft", "updated published", "published published", "updated updated", "deleted updated"}
+	if !reflect.DeepEqual(events, expects) {
+		t.Errorf("expect events %v, but got %v", expects, events)
+	}
+}
+
+func TestEventChanges(t *testing.T) {
+	_, context := newUserResource()
+	context.GetDB().DropTableIfExists(&Article{})
+	context.GetDB().AutoMigrate(&Article{})
+
+	var (
+		changes []string
+		res     = New(&Article{})
+		article = Article{Title: "draft"}
+	)
+
+	res.EventBus = NewEventBus()
+	res.EventBus.Subscribe(EventFilter{}, func(event Event) error {
+		changes = event.Changes
+		return nil
+	})
+	context.GetDB().Create(&article)
+
+	metaValues, _ := ConvertJSONToMetaValues(strings.NewReader(fmt.Sprintf(`{"ID": %v, "Title": "draft", "Published": true}`, article.ID)), DefaultMetas(res))
+	if err := DecodeToResource(res, &article, metaValues, context).Start(); err != nil {
+		t.Fatal(err)
+	}
+	res.CallSave(&article, WithMetaValues(context, &article, metaValues))
+
+	if !reflect.DeepEqual(changes, []string{"Published"}) {
+		t.Errorf("only changed metas should be in changes, but got %v", changes)
+	}
+}
@@ -0,0 +1,345 @@
+package resource
+
+import (
+	"fmt"
+	"reflect"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+const translationsSettingKey = "ec:translations"
+
+//	'Translation' translated value of a record's field, values of the default locale are saved in the record's table, translations of other locales are saved in this side table
+//	Migrate it with db.AutoMigrate(&resource.Translation{}) to use translatable fields
+type Translation struct {
+	ID           uint   `gorm:"primary_key"`
+	Scope        string `gorm:"size:128;index:idx_ec_translations_record"`
+	PrimaryValue string `gorm:"size:255;index:idx_ec_translations_record"`
+	Locale       string `gorm:"size:32"`
+	Field        string `gorm:"size:128"`
+	Value        string `gorm:"type:text"`
+}
+
+//	'TableName' table name of translations
+func (Translation) TableName() string {
+	return "ec_translations"
+}
+
+//	'MissingTranslation' translatable fields of a record that are not translated to a locale
+type MissingTranslation struct {
+	PrimaryValue string
+	Fields       []string
+}
+
+//	'SetTranslatableFields' set string fields that have per-locale values
+//	Records found with 'CallFindOne', 'CallFindMany' will have values of the context's locale, fallback to values of the default locale 'i18n.DefaultLocale'
+//	Records saved with 'CallSave' will save submitted values of translatable fields to the context's locale
+func (res *Resource) SetTranslatableFields(fields ...string) error {
+	var (
+		scope              = &gorm.Scope{Value: res.Value}
+		translatableFields []*gorm.StructField
+	)
+
+	for _, fieldName := range fields {
+		if field, ok := scope.FieldByName(fieldName); ok && field.Field.Kind() == reflect.String {
+			translatableFields = append(translatableFields, field.StructField)
+		} else {
+			return fmt.Errorf("resource: %v is not a translatable field of %v", fieldName, res.Name)
+		}
+	}
+
+	res.TranslatableFields = translatableFields
+	res.AddCallback(CallbackAfterFind, "ec:load_translations", res.loadTranslations, -1000)
+	res.AddCallback(CallbackBeforeSave, "ec:restore_default_locale_values", res.restoreDefaultLocaleValues, -1000)
+	res.AddCallback(CallbackAfterSave, "ec:save_translations", res.saveTranslations, -1000)
+	res.AddCallback(CallbackAfterDelete, "ec:delete_translations", func(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		if primaryValue := res.GetPrimaryValue(record); primaryValue != "" {
+			return context.GetDB().Where("scope = ? AND primary_value = ?", res.translationScope(context), primaryValue).Delete(&Translation{}).Error
+		}
+		return nil
+	})
+	return nil
+}
+
+func (res *Resource) translationScope(context *TM_EC.Context) string {
+	return context.GetDB().NewScope(res.Value).TableName()
+}
+
+//	'translationLocale' locale of the context, returns blank if it is the default locale
+func translationLocale(context *TM_EC.Context) string {
+	locale := i18n.Normalize(utils.GetLocale(context))
+	if locale == i18n.Normalize(i18n.DefaultLocale) {
+		return ""
+	}
+	return locale
+}
+
+func (res *Resource) loadTranslations(result interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+	locale := translationLocale(context)
+	if locale == "" || len(res.TranslatableFields) == 0 {
+		return nil
+	}
+
+	var (
+		records       []reflect.Value
+		primaryValues []string
+		reflectValue  = reflect.Indirect(reflect.ValueOf(result))
+		chain         = i18n.FallbackChain(locale)
+		translations  []Translation
+	)
+
+	if reflectValue.Kind() == reflect.Slice {
+		for i := 0; i < reflectValue.Len(); i++ {
+			records = append(records, reflect.Indirect(reflectValue.Index(i)))
+		}
+	} else {
+		records = append(records, reflectValue)
+	}
+
+	for _, record := range records {
+		if primaryValue := res.GetPrimaryValue(record.Addr().Interface()); primaryValue != "" {
+			primaryValues = append(primaryValues, primaryValue)
+		}
+	}
+
+	if len(primaryValues) == 0 {
+		return nil
+	}
+
+	if err := context.GetDB().New().Where("scope = ? AND primary_value IN (?) AND locale IN (?)", res.translationScope(context), primaryValues, chain).Find(&translations).Error; err != nil {
+		return err
+	}
+
+	//	values of the locale that is first in the fallback chain are used
+	var (
+		priorities = map[string]int{}
+		translated = map[string]*Translation{}
+	)
+
+	for idx, l := range chain {
+		priorities[l] = idx + 1
+	}
+
+	for idx, translation := range translations {
+		key := translation.PrimaryValue + "\x00" + translation.Field
+		if value, ok := translated[key]; !ok || priorities[translation.Locale] < priorities[value.Locale] {
+			translated[key] = &translations[idx]
+		}
+	}
+
+	for _, record := range records {
+		primaryValue := res.GetPrimaryValue(record.Addr().Interface())
+		for _, field := range res.TranslatableFields {
+			if value, ok := translated[primaryValue+"\x00"+field.Name]; ok {
+				record.FieldByName(field.Name).SetString(value.Value)
+			}
+		}
+	}
+	return nil
+}
+
+//	'restoreDefaultLocaleValues' set values of the default locale back to translatable fields before saving, so they won't be overwritten by values of current locale
+//	values of current locale are kept in the context, and saved as translations after the record saved
+//	Records created in other locales have no values of the default locale yet, so their translatable fields are saved blank in the record's table, and are found blank in the default locale until it is saved in the default locale
+func (res *Resource) restoreDefaultLocaleValues(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+	locale := translationLocale(context)
+	if locale == "" || len(res.TranslatableFields) == 0 {
+		return nil
+	}
+
+	var (
+		reflectValue = reflect.Indirect(reflect.ValueOf(record))
+		values       = map[string]string{}
+		original     = reflect.Value{}
+	)
+
+	if primaryValue := res.GetPrimaryValue(record); primaryValue != "" && !IsNewRecord(context) {
+		primaryQuerySQL, primaryParams, err := res.ToPrimaryQueryParams(primaryValue, context)
+		if err != nil {
+			return err
+		}
+
+		value := res.NewStruct()
+		if err := context.GetDB().New().Where(primaryQuerySQL, primaryParams...).First(value).Error; err != nil {
+			return err
+		}
+		original = reflect.Indirect(reflect.ValueOf(value))
+	}
+
+	for _, field := range res.TranslatableFields {
+		if metaValues != nil && !hasSubmittedField(metaValues, field.Name) {
+			//	keep values of the default locale for fields not submitted
+			if original.IsValid() {
+				reflectValue.FieldByName(field.Name).Set(original.FieldByName(field.Name))
+			}
+			continue
+		}
+
+		value := reflectValue.FieldByName(field.Name).String()
+		if metaValues == nil && value == "" {
+			continue
+		}
+
+		values[field.Name] = value
+		if original.IsValid() {
+			reflectValue.FieldByName(field.Name).Set(original.FieldByName(field.Name))
+		} else {
+			reflectValue.FieldByName(field.Name).SetString("")
+		}
+	}
+
+	context.SetDB(context.GetDB().Set(translationsSettingKey, values))
+	return nil
+}
+
+func hasSubmittedField(metaValues *MetaValues, fieldName string) bool {
+	for _, metaValue := range metaValues.Values {
+		if metaValue.Meta != nil && metaValue.Meta.GetFieldName() == fieldName || metaValue.Meta == nil && metaValue.Name == fieldName {
+			return true
+		}
+	}
+	return false
+}
+
+//	'saveTranslations' save values of current locale kept by 'restoreDefaultLocaleValues', and set them back to the record
+func (res *Resource) saveTranslations(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+	locale := translationLocale(context)
+	value, ok := context.GetDB().Get(translationsSettingKey)
+	if locale == "" || !ok {
+		return nil
+	}
+
+	var (
+		values       = value.(map[string]string)
+		reflectValue = reflect.Indirect(reflect.ValueOf(record))
+		primaryValue = res.GetPrimaryValue(record)
+		scope        = res.translationScope(context)
+	)
+
+	for field, value := range values {
+		translation := Translation{Scope: scope, PrimaryValue: primaryValue, Locale: locale, Field: field}
+		if err := context.GetDB().New().Where(translation).Assign(Translation{Value: value}).FirstOrCreate(&translation).Error; err != nil {
+			return err
+		}
+		reflectValue.FieldByName(field).SetString(value)
+	}
+
+	context.SetDB(context.GetDB().Set(translationsSettingKey, nil))
+	return nil
+}
+
+//	'MissingTranslations' records that have translatable fields not translated to the locale, blank values of the default locale are not reported
+//	Records are found with the context's DB, so it could be scoped with conditions
+func (res *Resource) MissingTranslations(locale string, context *TM_EC.Context) (missing []MissingTranslation, err error) {
+	locale = i18n.Normalize(locale)
+	records := res.NewSlice()
+	if err = context.GetDB().Find(records).Error; err != nil {
+		return
+	}
+
+	translated, err := res.translatedFields(locale, context)
+	if err != nil {
+		return
+	}
+
+	reflectValue := reflect.Indirect(reflect.ValueOf(records))
+	for i := 0; i < reflectValue.Len(); i++ {
+		var (
+			record       = reflect.Indirect(reflectValue.Index(i))
+			primaryValue = res.GetPrimaryValue(record.Addr().Interface())
+			fields       []string
+		)
+
+		for _, field := range res.TranslatableFields {
+			if record.FieldByName(field.Name).String() != "" && !translated[primaryValue+"\x00"+field.Name] {
+				fields = append(fields, field.Name)
+			}
+		}
+
+		if len(fields) > 0 {
+			missing = append(missing, MissingTranslation{PrimaryValue: primaryValue, Fields: fields})
+		}
+	}
+	return
+}
+
+func (res *Resource) translatedFields(locale string, context *TM_EC.Context) (map[string]bool, error) {
+	var (
+		translations []Translation
+		translated   = map[string]bool{}
+	)
+
+	if err := context.GetDB().New().Where("scope = ? AND locale = ?", res.translationScope(context), locale).Find(&translations).Error; err != nil {
+		return nil, err
+	}
+
+	for _, translation := range translations {
+		translated[translation.PrimaryValue+"\x00"+translation.Field] = true
+	}
+	return translated, nil
+}
+
+//	'CopyDefaultTranslations' copy values of the default locale to the locale for fields that are not translated yet, it returns count of created translations
+//	All records found with the context's DB will be copied if no record is given
+func (res *Resource) CopyDefaultTranslations(locale string, context *TM_EC.Context, records ...interface{}) (count int, err error) {
+	if locale = i18n.Normalize(locale); locale == i18n.Normalize(i18n.DefaultLocale) {
+		return 0, nil
+	}
+
+	if len(records) == 0 {
+		all := res.NewSlice()
+		if err = context.GetDB().Find(all).Error; err != nil {
+			return
+		}
+
+		reflectValue := reflect.Indirect(reflect.ValueOf(all))
+		for i := 0; i < reflectValue.Len(); i++ {
+			records = append(records, reflect.Indirect(reflectValue.Index(i)).Addr().Interface())
+		}
+	}
+
+	translated, err := res.translatedFields(locale, context)
+	if err != nil {
+		return
+	}
+
+	err = Transaction(context, func(context *TM_EC.Context) error {
+		scope := res.translationScope(context)
+		for _, record := range records {
+			primaryValue := res.GetPrimaryValue(record)
+			if primaryValue == "" {
+				continue
+			}
+
+			//	values of the default locale are saved in the record's table
+			original := res.NewStruct()
+			primaryQuerySQL, primaryParams, err := res.ToPrimaryQueryParams(primaryValue, context)
+			if err != nil {
+				return err
+			}
+
+			if err := context.GetDB().New().Where(primaryQuerySQL, primaryParams...).First(original).Error; err != nil {
+				return err
+			}
+
+			for _, field := range res.TranslatableFields {
+				value := reflect.Indirect(reflect.ValueOf(original)).FieldByName(field.Name).String()
+				if value == "" || translated[primaryValue+"\x00"+field.Name] {
+					continue
+				}
+
+				if err := context.GetDB().New().Create(&Translation{Scope: scope, PrimaryValue: primaryValue, Locale: locale, Field: field.Name, Value: value}).Error; err != nil {
+					return err
+				}
+				count++
+			}
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,159 @@
+package resource
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type Book struct {
+	gorm.Model
+	Title       string
+	Description string
+	ISBN        string
+}
+
+func TestTranslatableFields(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Book{}, &Translation{})
+	db.AutoMigrate(&Book{}, &Translation{})
+
+	res := New(&Book{})
+	if err := res.SetTranslatableFields("Title", "ISBN", "Unknown"); err == nil {
+		t.Errorf("should return error for unknown fields")
+	}
+
+	if err := res.SetTranslatableFields("Title", "Description"); err != nil {
+		t.Fatal(err)
+	}
+
+	newContext := func(locale string, form url.Values) *TM_EC.Context {
+		request, _ := http.NewRequest("POST", "/books", nil)
+		request.Header.Set("Locale", locale)
+		request.Form = form
+		return &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}
+	}
+
+	book := Book{Title: "The Go Programming Language", Description: "Go book", ISBN: "978-0134190440"}
+	if err := res.CallSave(&book, newContext("en", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	//	save title of zh-CN
+	var (
+		metas   = []Metaor{&Meta{Name: "Title", Resource: res}, &Meta{Name: "ISBN", Resource: res}}
+		context = newContext("zh-CN", url.Values{"ID": {res.GetPrimaryValue(&book)}, "Title": {"Go 程序设计语言"}, "ISBN": {"978-7111558422"}})
+		record  Book
+	)
+
+	for _, meta := range metas {
+		meta.(*Meta).PerInitialize()
+		meta.(*Meta).Initialize()
+	}
+
+	metaValues, _ := ConvertFormToMetaValues(context.Request, metas, "")
+	if err := DecodeToResource(res, &record, metaValues, context).Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.CallSave(&record, WithMetaValues(context, &record, metaValues)); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Title != "Go 程序设计语言" {
+		t.Errorf("saved record should have value of current locale, but got %v", record.Title)
+	}
+
+	var saved Book
+	db.First(&saved, book.ID)
+	if saved.Title != "The Go Programming Language" || saved.Description != "Go book" || saved.ISBN != "978-7111558422" {
+		t.Errorf("values of the default locale should not be changed, but got %+v", saved)
+	}
+
+	cases := []struct {
+		locale      string
+		title       string
+		description string
+	}{
+		{"en", "The Go Programming Language", "Go book"},
+		{"zh-CN", "Go 程序设计语言", "Go book"},
+		{"zh", "Go 程序设计语言", "Go book"},
+		{"ja", "The Go Programming Language", "Go book"},
+	}
+
+	for _, c := range cases {
+		var (
+			found   Book
+			books   []Book
+			context = newContext(c.locale, nil)
+		)
+
+		context.ResourceID = res.GetPrimaryValue(&book)
+		if err := res.CallFindOne(&found, nil, context); err != nil || found.Title != c.title || found.Description != c.description {
+			t.Errorf("find book in %v: expect %v, %v, but got %+v, %v", c.locale, c.title, c.description, found, err)
+		}
+
+		if err := res.CallFindMany(&books, context); err != nil || len(books) != 1 || books[0].Title != c.title {
+			t.Errorf("find books in %v: expect %v, but got %+v, %v", c.locale, c.title, books, err)
+		}
+	}
+
+	context = newContext("", nil)
+	if missing, err := res.MissingTranslations("zh-CN", context); err != nil || !reflect.DeepEqual(missing, []MissingTranslation{{PrimaryValue: res.GetPrimaryValue(&book), Fields: []string{"Description"}}}) {
+		t.Errorf("description should be missing for zh-CN, but got %+v, %v", missing, err)
+	}
+
+	if count, err := res.CopyDefaultTranslations("zh_cn", context); err != nil || count != 1 {
+		t.Errorf("should copy 1 translation, but got %v, %v", count, err)
+	}
+
+	if missing, _ := res.MissingTranslations("zh-CN", context); len(missing) != 0 {
+		t.Errorf("no translation should be missing after copied, but got %+v", missing)
+	}
+
+	//	values of the default locale are blank for records created in other locales
+	created := Book{Title: "Go 语言实战", ISBN: "978-7115421081"}
+	if err := res.CallSave(&created, newContext("zh-CN", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if created.Title != "Go 语言实战" {
+		t.Errorf("created record should have value of current locale, but got %v", created.Title)
+	}
+
+	var createdInDB Book
+	db.First(&createdInDB, created.ID)
+	if createdInDB.Title != "" || createdInDB.ISBN != "978-7115421081" {
+		t.Errorf("translatable fields of the default locale should be blank for records created in other locales, but got %+v", createdInDB)
+	}
+
+	for locale, title := range map[string]string{"en": "", "zh-CN": "Go 语言实战"} {
+		var found Book
+		context := newContext(locale, nil)
+		context.ResourceID = res.GetPrimaryValue(&created)
+		if err := res.CallFindOne(&found, nil, context); err != nil || found.Title != title {
+			t.Errorf("find created book in %v: expect %q, but got %+v, %v", locale, title, found, err)
+		}
+	}
+
+	if err := res.CallDelete(&created, context); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.CallDelete(&book, context); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	db.Model(&Translation{}).Count(&count)
+	if count != 0 {
+		t.Errorf("translations should be deleted with the record, but got %v", count)
+	}
+}
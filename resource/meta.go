@@ -13,9 +13,10 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
 	"github.com/Sky-And-Hammer/TM_EC/utils"
 	"github.com/Sky-And-Hammer/roles"
-	"github.com/Sky-And-Hammer/validations"
 )
 
 //	'Metaor' interface
@@ -52,16 +53,28 @@ type MetaConfig struct{}
 func (MetaConfig) ConfigureECMeta(Metaor) {}
 
 //	'Meta' meta struct definition
+//	'Required', 'Min', 'Max', 'Format', 'Pattern', 'OneOf', 'Unique' are validation rules, they could also be declared with field's tag like `ec:"required;max:255;format:email"`
+//	'Min', 'Max' limit value of number fields, and length of other fields
 type Meta struct {
-	Name            string
-	FieldName       string
-	FieldStruct     *gorm.StructField
-	Setter          func(resource interface{}, metaValue *MetaValue, context *TM_EC.Context)
-	Valuer          func(interface{}, *TM_EC.Context) interface{}
-	FormattedValuer func(interface{}, *TM_EC.Context) interface{}
-	Config          MetaConfigInterface
-	Resource        Resourcer
-	Permission      *roles.Permission
+	Name                string
+	FieldName           string
+	Type                string
+	FieldStruct         *gorm.StructField
+	Setter              func(resource interface{}, metaValue *MetaValue, context *TM_EC.Context)
+	Valuer              func(interface{}, *TM_EC.Context) interface{}
+	FormattedValuer     func(interface{}, *TM_EC.Context) interface{}
+	Config              MetaConfigInterface
+	Resource            Resourcer
+	Permission          *roles.Permission
+	Required            bool
+	Min                 *float64
+	Max                 *float64
+	Format              string
+	Pattern             string
+	OneOf               []string
+	Unique              bool
+	validatorRegistered bool
+	decimalField        bool
 }
 
 //	'GetBaseResource' get base resource from meta
@@ -69,6 +82,21 @@ func (meta Meta) GetBaseResource() Resourcer {
 	return meta.Resource
 }
 
+//	'GetResource' get nested resource of meta, base meta doesn't have nested resource, it is used to match interface 'Metaor'
+func (meta Meta) GetResource() Resourcer {
+	return nil
+}
+
+//	'GetMetas' get nested metas of meta, base meta doesn't have nested metas, it is used to match interface 'Metaor'
+func (meta Meta) GetMetas() []Metaor {
+	return nil
+}
+
+//	'isDecimalField' check the meta's field is decimal, money or float with decimal column
+func (meta Meta) isDecimalField() bool {
+	return meta.decimalField
+}
+
 //	'GetName' get meta's name
 func (meta Meta) GetName() string {
 	return meta.Name
@@ -138,15 +166,6 @@ func (meta *Meta) PerInitialize() error {
 		meta.FieldName = meta.Name
 	}
 
-	var parseNestedField = func(value reflect.Value, name string) (reflect.Value, string) {
-		fields := strings.Split(name, ".")
-		value = reflect.Indirect(value)
-		for _, field := range fields[:len(fields)-1] {
-			value = value.FieldByName(field)
-		}
-		return value, fields[len(fields)-1]
-	}
-
 	var getField = func(fields []*gorm.StructField, name string) *gorm.StructField {
 		for _, field := range fields {
 			if field.Name == name || field.DBName == name {
@@ -156,48 +175,78 @@ func (meta *Meta) PerInitialize() error {
 		return nil
 	}
 
-	var nestedField = strings.Contains(meta.FieldName, ".")
-	var scope = &gorm.Scope{
-		Value: meta.Resource.GetResource().Value,
-	}
-	if nestedField {
-		subModel, name := parseNestedField(reflect.ValueOf(meta.Resource.GetResource().Validatiors), meta.FieldName)
-		meta.FieldStruct = getField(scope.New(subModel.Interface()).GetStructFields(), name)
-	} else {
-		meta.FieldStruct = getField(scope.GetStructFields(), meta.FieldName)
+	var (
+		fields    = strings.Split(meta.FieldName, ".")
+		modelType = utils.ModelType(meta.Resource.GetResource().Value)
+		scope     = &gorm.Scope{
+			Value: meta.Resource.GetResource().Value,
+		}
+	)
+
+	//	walk through nested structs, pointers and relations to find the struct of the last field
+	for _, name := range fields[:len(fields)-1] {
+		field := getField(scope.New(reflect.New(modelType).Interface()).GetStructFields(), name)
+		if field == nil {
+			return fmt.Errorf("resource: nested field %v not found for meta %v", name, meta.Name)
+		}
+
+		modelType = field.Struct.Type
+		for modelType.Kind() == reflect.Ptr {
+			modelType = modelType.Elem()
+		}
+
+		if modelType.Kind() != reflect.Struct {
+			return fmt.Errorf("resource: nested field %v of meta %v is not a struct", name, meta.Name)
+		}
 	}
+
+	meta.FieldStruct = getField(scope.New(reflect.New(modelType).Interface()).GetStructFields(), fields[len(fields)-1])
 	return nil
 }
 
+//	'structFieldName' name of the field in its struct, it is the last part of nested field name "Address.Country.Name"
+func (meta *Meta) structFieldName() string {
+	fields := strings.Split(meta.FieldName, ".")
+	return fields[len(fields)-1]
+}
+
 //	'Initialize' initialize meta, will set valuer, setter if haven't configure it
 func (meta *Meta) Initialize() error {
+	if meta.FieldStruct != nil {
+		if configor, ok := reflect.New(meta.indirectFieldType()).Interface().(ConfigureMetaBeforeInitializeInterface); ok {
+			configor.ConfigureECMetaBeforeInitialize(meta)
+		}
+	}
+
+	meta.configureMetaType()
+
 	var (
 		nestedField = strings.Contains(meta.FieldName, ".")
 		field       = meta.FieldStruct
 		hasColumn   = meta.FieldStruct != nil
+		fieldType   reflect.Type
+		isDecimal   bool
 	)
 
-	var fieldType reflect.Type
 	if hasColumn {
 		fieldType = field.Struct.Type
 		for fieldType.Kind() == reflect.Ptr {
 			fieldType = fieldType.Elem()
 		}
+		isDecimal = isDecimalType(fieldType)
 	}
 
 	if meta.Valuer == nil {
 		if hasColumn {
 			meta.Valuer = func(value interface{}, context *TM_EC.Context) interface{} {
-				scope := context.GetDB().NewScope(value)
-				fieldName := meta.FieldName
-				if nestedField {
-					fileds := strings.Split(fieldName, ".")
-					fieldName = fileds[len(fileds)-1]
+				if value == nil {
+					return nil
 				}
 
-				if f, ok := scope.FieldByName(fieldName); ok {
+				scope := context.GetDB().NewScope(value)
+				if f, ok := scope.FieldByName(meta.structFieldName()); ok {
 					if f.Relationship != nil && f.Field.CanAddr() && !scope.PrimaryKeyZero() {
-						context.GetDB().Model(value).Related(f.Field.Addr().Interface(), meta.FieldName)
+						loadRelated(value, f, context)
 					}
 
 					return f.Field.Interface()
@@ -218,7 +267,7 @@ func (meta *Meta) Initialize() error {
 						Value: resource,
 					}
 					reflectValue := reflect.Indirect(reflect.ValueOf(resource))
-					field := reflectValue.FieldByName(meta.FieldName)
+					field := reflectValue.FieldByName(meta.structFieldName())
 					if field.Kind() == reflect.Ptr {
 						if field.IsNil() {
 							field.Set(utils.NewValue(field.Type()).Elem())
@@ -229,8 +278,13 @@ func (meta *Meta) Initialize() error {
 						}
 					}
 
+					if metaValue.Operation != "" && field.Kind() == reflect.Slice {
+						meta.patchAssociatedRecords(resource, field, metaValue, context)
+						return
+					}
+
 					primaryKeys := utils.ToArray(metaValue.Value)
-					if relationship.Kind == "belong_to" && len(relationship.ForeignFieldNames) == 1 {
+					if relationship.Kind == "belongs_to" && len(relationship.ForeignFieldNames) == 1 {
 						oldPrimaryKeys := utils.ToArray(reflectValue.FieldByName(relationship.ForeignFieldNames[0]).Interface())
 						if fmt.Sprint(primaryKeys) == fmt.Sprint(oldPrimaryKeys) {
 							return
@@ -248,45 +302,55 @@ func (meta *Meta) Initialize() error {
 
 					if relationship.Kind == "many_to_many" {
 						if !scope.PrimaryKeyZero() {
-							context.GetDB().Model(resource).Association(meta.FieldName).Replace(field.Interface())
+							context.GetDB().Model(resource).Association(meta.structFieldName()).Replace(field.Interface())
 							field.Send(reflect.Zero(field.Type()))
 						}
 					}
 				}
+			} else if relationship.Kind == "has_one" || relationship.Kind == "has_many" {
+				meta.Setter = meta.associationSetter(relationship)
+				meta.registerOrphansCallback(relationship)
 			}
 		} else {
 			meta.Setter = func(resource interface{}, metaValue *MetaValue, context *TM_EC.Context) {
-				if metaValue == nil {
+				if metaValue == nil || metaValue.Absent {
 					return
 				}
 
-				var (
-					value     = metaValue.Value
-					fieldName = meta.FieldName
-				)
+				var value = metaValue.Value
 
 				defer func() {
 					if r := recover(); r != nil {
-						context.AddError(validations.NewError(resource, meta.Name, fmt.Sprintf("Can't set value %v", value)))
+						context.AddError(NewFieldError(resource, meta.Name, i18n.NewError("ec.errors.invalid_value", value)))
 					}
 				}()
 
-				if nestedField {
-					fields := strings.Split(fieldName, ".")
-					fieldName = fields[len(fields)-1]
+				field := reflect.Indirect(reflect.ValueOf(resource)).FieldByName(meta.structFieldName())
+				if metaValue.Null {
+					setNull(field)
+					return
 				}
 
-				field := reflect.Indirect(reflect.ValueOf(resource)).FieldByName(fieldName)
-				if field.Kind() == reflect.Ptr {
-					if field.IsNil() && utils.ToString(value) != "" {
-						field.Set(utils.NewValue(field.Type()).Elem())
+				if metaValue.Operation != "" && field.Kind() == reflect.Slice {
+					if err := patchSlice(field, metaValue, func(reflect.Value) (reflect.Value, bool) {
+						return convertElement(value, field.Type().Elem()), true
+					}); err != nil {
+						context.AddError(NewFieldError(resource, meta.Name, err))
 					}
+					return
+				}
 
-					if utils.ToString(value) == "" {
+				if field.Kind() == reflect.Ptr {
+					//	empty string is kept for pointers of string, it is null for other pointers
+					if utils.ToString(value) == "" && indirectType(field.Type()).Kind() != reflect.String {
 						field.Set(reflect.Zero(field.Type()))
 						return
 					}
 
+					if field.IsNil() {
+						field.Set(utils.NewValue(field.Type()).Elem())
+					}
+
 					for field.Kind() == reflect.Ptr {
 						field = field.Elem()
 					}
@@ -299,7 +363,12 @@ func (meta *Meta) Initialize() error {
 					case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 						field.SetUint(utils.ToUInt(value))
 					case reflect.Float32, reflect.Float64:
-						field.SetFloat(utils.ToFloat(value))
+						d, err := meta.parseDecimal(value, context)
+						if err != nil {
+							context.AddError(NewFieldError(resource, meta.Name, err))
+							return
+						}
+						field.SetFloat(d.Float64())
 					case reflect.Bool:
 						if utils.ToString(value) == "true" {
 							field.SetBool(true)
@@ -307,20 +376,45 @@ func (meta *Meta) Initialize() error {
 							field.SetBool(false)
 						}
 					default:
-						if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
+						if money, ok := field.Addr().Interface().(*decimal.Money); ok {
+							switch v := value.(type) {
+							case decimal.Money:
+								*money = v
+							case *decimal.Money:
+								*money = *v
+							default:
+								if str := utils.ToString(value); value == nil || str == "" {
+									*money = decimal.Money{}
+								} else if parsed, err := decimal.ParseMoney(str, utils.GetLocale(context)); err == nil {
+									*money = parsed
+								} else {
+									context.AddError(NewFieldError(resource, meta.Name, i18n.NewError("ec.errors.invalid_amount", str)))
+								}
+							}
+						} else if isDecimal {
+							d, err := meta.parseDecimal(value, context)
+							if err != nil {
+								context.AddError(NewFieldError(resource, meta.Name, err))
+								return
+							}
+							field.Addr().Interface().(sql.Scanner).Scan(d.String())
+						} else if scanner, ok := field.Addr().Interface().(sql.Scanner); ok {
 							if value == nil && len(metaValue.MetaValues.Values) > 0 {
-								decodeMetaValuesToField(meta.Resource, field, metaValue, context)
+								decodeMetaValuesToField(meta.Resource, resource, field, metaValue, context)
 								return
 							}
 
 							if scanner.Scan(value) != nil {
-								scanner.Scan(utils.ToString(value))
+								//	empty string is null for scanners that couldn't hold it, e.g. sql.NullInt64
+								if str := utils.ToString(value); scanner.Scan(str) != nil && str == "" {
+									scanner.Scan(nil)
+								}
 							}
 						} else if reflect.TypeOf("").ConvertibleTo(field.Type()) {
 							field.Set(reflect.ValueOf(utils.ToString(value)).Convert(field.Type()))
 						} else if reflect.TypeOf([]string{}).ConvertibleTo(field.Type()) {
 							field.Set(reflect.ValueOf(utils.ToArray(value)).Convert(field.Type()))
-						} else if rValue := reflect.ValueOf(value); reflect.TypeOf(rValue.Type()).ConvertibleTo(field.Type()) {
+						} else if rValue := reflect.ValueOf(value); rValue.IsValid() && rValue.Type().ConvertibleTo(field.Type()) {
 							field.Set(rValue.Convert(field.Type()))
 						} else if _, ok := field.Addr().Interface().(*time.Time); ok {
 							if str := utils.ToString(value); str != "" {
@@ -343,36 +437,87 @@ func (meta *Meta) Initialize() error {
 		}
 	}
 
+	if hasColumn {
+		_, _, hasPrecision := columnPrecision(field)
+		meta.decimalField = isDecimal || fieldType == reflect.TypeOf(decimal.Money{}) ||
+			(hasPrecision && (fieldType.Kind() == reflect.Float32 || fieldType.Kind() == reflect.Float64))
+	}
+
+	if hasColumn && meta.Type == "" && meta.FormattedValuer == nil {
+		if meta.decimalField {
+			meta.FormattedValuer = meta.decimalFormattedValuer()
+		} else if fieldType == reflect.TypeOf(time.Time{}) {
+			meta.FormattedValuer = meta.timeFormattedValuer()
+		}
+	}
+
+	meta.initializeMetaType()
+	meta.registerValidator()
+
+	if hasColumn {
+		if configor, ok := reflect.New(fieldType).Interface().(ConfigureMetaInterface); ok {
+			configor.ConfigureECMeta(meta)
+		}
+	}
+
 	if nestedField {
 		oldValue := meta.Valuer
 		meta.Valuer = func(value interface{}, context *TM_EC.Context) interface{} {
-			return oldValue(getNestedModel(value, meta.FieldName, context), context)
+			if nestedModel := getNestedModel(value, meta.FieldName, false, context); nestedModel != nil {
+				return oldValue(nestedModel, context)
+			}
+			return nil
 		}
 
-		oldSetter := meta.Setter
-		meta.Setter = func(resource interface{}, metaValu *MetaValue, context *TM_EC.Context) {
-			oldSetter(getNestedModel(resource, meta.FieldName, context), metaValu, context)
+		if oldSetter := meta.Setter; oldSetter != nil {
+			meta.Setter = func(resource interface{}, metaValue *MetaValue, context *TM_EC.Context) {
+				if nestedModel := getNestedModel(resource, meta.FieldName, true, context); nestedModel != nil {
+					oldSetter(nestedModel, metaValue, context)
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
-func getNestedModel(value interface{}, fieldName string, context *TM_EC.Context) interface{} {
+//	'getNestedModel' get the struct that holds the last field of nested field name, e.g. "Address.Country.Name" returns the pointer of Address.Country
+//	Intermediate relations with blank primary key are loaded lazily with 'Related', if allocate is true, missing intermediate records will be created in memory, and saved with the record
+func getNestedModel(value interface{}, fieldName string, allocate bool, context *TM_EC.Context) interface{} {
 	model := reflect.Indirect(reflect.ValueOf(value))
 	fields := strings.Split(fieldName, ".")
-	for _, field := range fields[:len(fields)-1] {
-		subModel := model.FieldByName(field)
-		if key := subModel.FieldByName("Id"); !key.IsValid() || key.Uint() == 0 {
-			if subModel.CanAddr() {
-				context.GetDB().Model(model.Addr().Interface()).Related(subModel.Addr().Interface())
-				model = subModel
-			} else {
-				break
+	for _, name := range fields[:len(fields)-1] {
+		if model.Kind() != reflect.Struct || !model.CanAddr() {
+			return nil
+		}
+
+		subModel := model.FieldByName(name)
+		if !subModel.IsValid() {
+			return nil
+		}
+
+		var target = subModel
+		if subModel.Kind() == reflect.Ptr {
+			if subModel.IsNil() {
+				target = reflect.New(subModel.Type().Elem())
+			}
+			target = target.Elem()
+		}
+
+		scope := context.GetDB().NewScope(model.Addr().Interface())
+		if field, ok := scope.FieldByName(name); ok && field.Relationship != nil && !scope.PrimaryKeyZero() {
+			if context.GetDB().NewScope(target.Addr().Interface()).PrimaryKeyZero() {
+				loadRelated(model.Addr().Interface(), &gorm.Field{StructField: field.StructField, Field: target}, context)
 			}
-		} else {
-			model = subModel
 		}
+
+		if subModel.Kind() == reflect.Ptr && subModel.IsNil() {
+			if !allocate && context.GetDB().NewScope(target.Addr().Interface()).PrimaryKeyZero() {
+				return nil
+			}
+			subModel.Set(target.Addr())
+		}
+		model = target
 	}
 
 	if model.CanAddr() {
@@ -381,3 +526,42 @@ func getNestedModel(value interface{}, fieldName string, context *TM_EC.Context)
 
 	return nil
 }
+
+//	'timeFormattedValuer' formatted valuer for time fields, values are converted to time zone of the context
+func (meta *Meta) timeFormattedValuer() func(interface{}, *TM_EC.Context) interface{} {
+	return func(record interface{}, context *TM_EC.Context) interface{} {
+		//	valuer is got when formatting, as it is wrapped for nested fields after initialized
+		switch t := meta.Valuer(record, context).(type) {
+		case time.Time:
+			if !t.IsZero() {
+				return t.In(utils.GetTimeLocation(context))
+			}
+			return t
+		case *time.Time:
+			if t != nil && !t.IsZero() {
+				local := t.In(utils.GetTimeLocation(context))
+				return &local
+			}
+			return t
+		default:
+			return t
+		}
+	}
+}
+
+//	'setNull' set field to null, pointers are set to nil, sql.Scanner like sql.NullString are scanned with nil, other fields are set to zero value
+func setNull(field reflect.Value) {
+	if field.Kind() != reflect.Ptr && field.CanAddr() {
+		if scanner, ok := field.Addr().Interface().(sql.Scanner); ok && scanner.Scan(nil) == nil {
+			return
+		}
+	}
+	field.Set(reflect.Zero(field.Type()))
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
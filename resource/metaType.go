@@ -0,0 +1,163 @@
+package resource
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+//	'MetaType' handler of a meta type, it is used by metas with the same 'Type'
+//	'Configure' is called when initializing the meta, it could be used to set default config
+//	'Decode' convert submitted value to the value that will be set to the field, returns error if the value is invalid, e.g. not in options
+//	'Format' convert field's value to formatted value
+//	'Schema' fill type specific information to the meta's schema
+type MetaType struct {
+	Name      string
+	Configure func(meta *Meta)
+	Decode    func(meta *Meta, record interface{}, metaValue *MetaValue, context *TM_EC.Context) (interface{}, error)
+	Format    func(meta *Meta, record interface{}, value interface{}, context *TM_EC.Context) interface{}
+	Schema    func(meta *Meta, schema *MetaSchema, context *TM_EC.Context)
+}
+
+//	'MetaOption' an option of select, enum metas
+type MetaOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+//	'MetaSchema' description of a meta, it could be used to render forms or generate API documents
+type MetaSchema struct {
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	Options    []MetaOption           `json:"options,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Metas      []*MetaSchema          `json:"metas,omitempty"`
+}
+
+var (
+	metaTypes      = map[string]*MetaType{}
+	metaTypesMutex sync.RWMutex
+)
+
+//	'RegisterMetaType' register a meta type, registered type with same name will be replaced
+func RegisterMetaType(metaType *MetaType) {
+	metaTypesMutex.Lock()
+	metaTypes[metaType.Name] = metaType
+	metaTypesMutex.Unlock()
+}
+
+//	'GetMetaType' get registered meta type by name, returns nil if not found
+func GetMetaType(name string) *MetaType {
+	metaTypesMutex.RLock()
+	defer metaTypesMutex.RUnlock()
+	return metaTypes[name]
+}
+
+//	'configureMetaType' configure the meta with its meta type, it is called before setting default setter, valuer
+func (meta *Meta) configureMetaType() {
+	if metaType := GetMetaType(meta.Type); metaType != nil && metaType.Configure != nil {
+		metaType.Configure(meta)
+	}
+}
+
+//	'initializeMetaType' wrap setter to decode submitted value with meta type, and set formatted valuer
+func (meta *Meta) initializeMetaType() {
+	metaType := GetMetaType(meta.Type)
+	if metaType == nil {
+		return
+	}
+
+	if metaType.Decode != nil {
+		if setter := meta.Setter; setter != nil {
+			meta.Setter = func(resource interface{}, metaValue *MetaValue, context *TM_EC.Context) {
+				if metaValue == nil || metaValue.Absent || metaValue.Null {
+					setter(resource, metaValue, context)
+					return
+				}
+
+				value, err := metaType.Decode(meta, resource, metaValue, context)
+				if err != nil {
+					context.AddError(NewFieldError(resource, meta.Name, err))
+					return
+				}
+
+				decodedValue := *metaValue
+				decodedValue.Value = value
+				setter(resource, &decodedValue, context)
+			}
+		}
+	}
+
+	if metaType.Format != nil && meta.FormattedValuer == nil && meta.Valuer != nil {
+		//	valuer is got when formatting, as it is wrapped for nested fields after initialized
+		meta.FormattedValuer = func(record interface{}, context *TM_EC.Context) interface{} {
+			return metaType.Format(meta, record, meta.Valuer(record, context), context)
+		}
+	}
+}
+
+//	'GetSchema' get schema of the meta, type of metas without 'Type' is guessed from their fields
+func (meta *Meta) GetSchema(context *TM_EC.Context) *MetaSchema {
+	schema := &MetaSchema{Name: meta.Name, Type: meta.Type, Attributes: map[string]interface{}{}}
+	if schema.Type == "" {
+		schema.Type = meta.guessType()
+	}
+
+	if metaType := GetMetaType(meta.Type); metaType != nil && metaType.Schema != nil {
+		metaType.Schema(meta, schema, context)
+	}
+	return schema
+}
+
+func (meta *Meta) guessType() string {
+	if meta.FieldStruct == nil {
+		return "string"
+	}
+
+	if relationship := meta.FieldStruct.Relationship; relationship != nil {
+		if relationship.Kind == "has_many" || relationship.Kind == "many_to_many" {
+			return "collection"
+		}
+		return "single"
+	}
+
+	fieldType := meta.FieldStruct.Struct.Type
+	for fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+
+	switch fieldType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "checkbox"
+	case reflect.Struct:
+		if fieldType == reflect.TypeOf(time.Time{}) {
+			return "datetime"
+		}
+	}
+	return "string"
+}
+
+//	'GetMetaSchemas' get schemas of metas, nested metas' schemas are included
+func GetMetaSchemas(metas []Metaor, context *TM_EC.Context) (schemas []*MetaSchema) {
+	for _, metaor := range metas {
+		var schema *MetaSchema
+		if getter, ok := metaor.(interface {
+			GetSchema(*TM_EC.Context) *MetaSchema
+		}); ok {
+			schema = getter.GetSchema(context)
+		} else {
+			schema = &MetaSchema{Name: metaor.GetName(), Type: "string"}
+		}
+
+		if children := metaor.GetMetas(); len(children) > 0 {
+			schema.Metas = GetMetaSchemas(children, context)
+		}
+		schemas = append(schemas, schema)
+	}
+	return
+}
@@ -0,0 +1,104 @@
+package resource
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type Category struct {
+	gorm.Model
+	Name string
+}
+
+type Item struct {
+	gorm.Model
+	Size        string
+	Colors      string
+	Description string
+	Price       float64
+	Currency    string
+	AvailableOn *time.Time
+	Status      int
+	CategoryID  uint
+	Category    Category
+}
+
+func TestMetaTypes(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Category{}, &Item{})
+	db.AutoMigrate(&Category{}, &Item{})
+	db.Create(&Category{Name: "Shoes"})
+
+	var (
+		context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+		itemRes = New(&Item{})
+		metas   = map[string]Metaor{}
+	)
+
+	for _, meta := range []*Meta{
+		{Name: "Size", Type: "select_one", Config: &SelectOneConfig{Collection: []string{"S", "M", "L"}}},
+		{Name: "Colors", Type: "select_many", Config: &SelectManyConfig{Collection: [][]string{{"red", "Red"}, {"blue", "Blue"}}}},
+		{Name: "Description", Type: "rich_text"},
+		{Name: "Price", Type: "money", Config: &MoneyConfig{Currency: "USD", CurrencyField: "Currency", Currencies: []string{"USD", "CNY"}}},
+		{Name: "AvailableOn", Type: "date"},
+		{Name: "Status", Type: "enum", Config: &EnumConfig{Options: []MetaOption{{Value: "1", Label: "Draft"}, {Value: "2", Label: "Published"}}}},
+		{Name: "Category", Type: "select_one"},
+	} {
+		meta.Resource = itemRes
+		metas[meta.Name] = newTestMeta(meta, nil)
+	}
+
+	cases := []struct {
+		meta      string
+		value     interface{}
+		hasError  bool
+		formatted interface{}
+	}{
+		{"Size", "M", false, "M"},
+		{"Size", "XL", true, nil},
+		{"Colors", []string{"red", "blue"}, false, []interface{}{"Red", "Blue"}},
+		{"Colors", []string{"green"}, true, nil},
+		{"Description", `<p onclick="alert(1)">hello<script>alert(1)</script></p>`, false, "<p>hello</p>"},
+		{"Price", "CNY 1,234.50", false, "CNY 1,234.50"},
+		{"Price", "12.345", true, nil},
+		{"Price", "EUR 12", true, nil},
+		{"AvailableOn", "2017-05-10 10:30", false, "2017-05-10"},
+		{"AvailableOn", "not a date", true, nil},
+		{"Status", "published", false, "Published"},
+		{"Status", "3", true, nil},
+		{"Category", "1", false, "Shoes"},
+		{"Category", "2", true, nil},
+	}
+
+	for _, c := range cases {
+		var (
+			item    Item
+			meta    = metas[c.meta]
+			context = &TM_EC.Context{Config: context.Config}
+		)
+
+		meta.GetSetter()(&item, &MetaValue{Name: c.meta, Value: c.value, Meta: meta}, context)
+		if context.HasError() != c.hasError {
+			t.Errorf("meta %v with value %v: expect has error %v, but got %v", c.meta, c.value, c.hasError, context.GetErrors())
+			continue
+		}
+
+		if !c.hasError {
+			if formatted := meta.GetFormattedValuer()(&item, context); !reflect.DeepEqual(formatted, c.formatted) {
+				t.Errorf("meta %v with value %v: expect formatted value %#v, but got %#v", c.meta, c.value, c.formatted, formatted)
+			}
+		}
+	}
+
+	schemas := GetMetaSchemas([]Metaor{metas["Size"], metas["Category"], metas["Price"]}, context)
+	if len(schemas[0].Options) != 3 || schemas[1].Attributes["resource"] != "Category" || schemas[2].Attributes["currency"] != "USD" {
+		t.Errorf("schemas are not generated correctly, got %+v, %+v, %+v", schemas[0], schemas[1], schemas[2])
+	}
+}
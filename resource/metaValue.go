@@ -3,12 +3,17 @@ package resource
 import (
 	"reflect"
 
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
 	"github.com/Sky-And-Hammer/TM_EC"
 )
 
 //	'MetaValues' a slice of MetaValue
+//	'Patch' is true if they are partial updates converted from JSON Merge Patch or JSON Patch, they could only be applied to existing records
 type MetaValues struct {
 	Values []*MetaValue
+	Patch  bool
 }
 
 func (mvs MetaValues) Get(name string) *MetaValue {
@@ -21,23 +26,51 @@ func (mvs MetaValues) Get(name string) *MetaValue {
 	return nil
 }
 
+//	'Changes' names of changed metas, metas whose values are not changed after decoded with 'DecodeToResource' are excluded
+func (mvs MetaValues) Changes() (names []string) {
+	for _, value := range mvs.Values {
+		if !value.unchanged && !value.Absent && !stringInSlice(value.Name, names) {
+			names = append(names, value.Name)
+		}
+	}
+	return
+}
+
 //	'MetaValue' a struct used to hold inforamtion when convert inputs from HTTP form, JSON, CSV fields and so on to meta values
 //	It will includes file name, field value and it's configured Meta, if it is a nested resource, will includeds nested metas in it's MetaValues
+//	'Null' is true if the value is explicitly null, e.g. null of JSON, "\N" of CSV, removed field of JSON Patch, pointers and sql.Null* fields will be set to null, other fields to zero value
+//	'Absent' is true if the field is not provided, e.g. form key without values, missing cells of short CSV rows, the field will be kept untouched
+//	Empty strings are neither null nor absent, they are kept for fields that could hold them, e.g. *string, sql.NullString
+//	'Operation' is operation of JSON Patch applied to element 'Index' of slice fields, 'Index' is -1 for the end of slice
 type MetaValue struct {
 	Name       string
 	Value      interface{}
 	Index      int
+	Null       bool
+	Absent     bool
+	Operation  PatchOperation
 	MetaValues *MetaValues
 	Meta       Metaor
 	error      error
+	patch      *pendingPatch
+	unchanged  bool
 }
 
-func decodeMetaValuesToField(res Resourcer, field reflect.Value, metaValue *MetaValue, context *TM_EC.Context) {
+func decodeMetaValuesToField(res Resourcer, record interface{}, field reflect.Value, metaValue *MetaValue, context *TM_EC.Context) {
+	var relationship *gorm.Relationship
+	if record != nil && metaValue.Meta != nil {
+		if f, ok := context.GetDB().NewScope(record).FieldByName(metaValue.Meta.GetFieldName()); ok {
+			relationship = f.Relationship
+		}
+	}
+
 	if field.Kind() == reflect.Struct {
 		value := reflect.New(field.Type())
 		associationProcessor := DecodeToResource(res, value.Interface(), metaValue.MetaValues, context)
+		associationProcessor.nested = true
 		associationProcessor.Start()
 		if !associationProcessor.SkipLeft {
+			stampAssociation(reflect.ValueOf(record), value, relationship)
 			field.Set(value.Elem())
 		}
 	} else if field.Kind() == reflect.Slice {
@@ -54,9 +87,11 @@ func decodeMetaValuesToField(res Resourcer, field reflect.Value, metaValue *Meta
 
 		value := reflect.New(fieldType)
 		associationProcessor := DecodeToResource(res, value.Interface(), metaValue.MetaValues, context)
+		associationProcessor.nested = true
 		associationProcessor.Start()
 		if !associationProcessor.SkipLeft {
 			if !reflect.DeepEqual(reflect.Zero(fieldType).Interface(), value.Elem().Interface()) {
+				stampAssociation(reflect.ValueOf(record), value, relationship)
 				if isPtr {
 					field.Set(reflect.Append(field, value))
 				} else {
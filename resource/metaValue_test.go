@@ -0,0 +1,105 @@
+package resource
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type Contact struct {
+	gorm.Model
+	Name     string
+	Nickname *string
+	Age      *int
+	Email    sql.NullString
+	Score    sql.NullInt64
+}
+
+func TestNullAndAbsentValues(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Contact{})
+	db.AutoMigrate(&Contact{})
+
+	var (
+		context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+		res     = New(&Contact{})
+		metas   []Metaor
+	)
+
+	for _, name := range []string{"Name", "Nickname", "Age", "Email", "Score"} {
+		metas = append(metas, newTestMeta(&Meta{Name: name, Resource: res}, nil))
+	}
+
+	existing := func() Contact {
+		nickname, age := "jz", 18
+		return Contact{
+			Name:     "jinzhu",
+			Nickname: &nickname,
+			Age:      &age,
+			Email:    sql.NullString{String: "jz@example.com", Valid: true},
+			Score:    sql.NullInt64{Int64: 100, Valid: true},
+		}
+	}
+
+	jsonValues := func(body string) *MetaValues {
+		metaValues, err := ConvertJSONToMetaValues(strings.NewReader(body), metas)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return metaValues
+	}
+
+	csvValues := func(body string) *MetaValues {
+		results, err := ConvertCSVToMetaValues(strings.NewReader(body), metas)
+		if err != nil || len(results) != 1 {
+			t.Fatalf("failed to convert csv, got %v, %v", results, err)
+		}
+		return results[0]
+	}
+
+	formValues := func(form url.Values) *MetaValues {
+		metaValues, _ := ConvertFormToMetaValues(&http.Request{Form: form}, metas, "")
+		return metaValues
+	}
+
+	cases := []struct {
+		name       string
+		metaValues *MetaValues
+		check      func(Contact) bool
+	}{
+		{"json null", jsonValues(`{"Nickname": null, "Age": null, "Email": null, "Score": null}`), func(c Contact) bool {
+			return c.Name == "jinzhu" && c.Nickname == nil && c.Age == nil && !c.Email.Valid && !c.Score.Valid
+		}},
+		{"json empty", jsonValues(`{"Name": "", "Nickname": "", "Age": "", "Email": "", "Score": ""}`), func(c Contact) bool {
+			return c.Name == "" && c.Nickname != nil && *c.Nickname == "" && c.Age == nil && c.Email.Valid && c.Email.String == "" && !c.Score.Valid
+		}},
+		{"json values", jsonValues(`{"Age": 20, "Score": 90}`), func(c Contact) bool {
+			return *c.Age == 20 && c.Score.Int64 == 90 && c.Score.Valid && *c.Nickname == "jz"
+		}},
+		{"form absent", formValues(url.Values{"Name": {}, "Nickname": {""}, "Email": {"new@example.com"}}), func(c Contact) bool {
+			return c.Name == "jinzhu" && *c.Nickname == "" && c.Email.String == "new@example.com" && *c.Age == 18
+		}},
+		{"csv", csvValues("\ufeffName,Nickname,Email,Score,Age\nnew,\\N,"), func(c Contact) bool {
+			return c.Name == "new" && c.Nickname == nil && c.Email.Valid && c.Email.String == "" && c.Score.Int64 == 100 && *c.Age == 18
+		}},
+	}
+
+	for _, c := range cases {
+		contact := existing()
+		if err := DecodeToResource(res, &contact, c.metaValues, context).Start(); err != nil || context.HasError() {
+			t.Errorf("%v: failed to decode, got %v, %v", c.name, err, context.GetErrors())
+		}
+
+		if !c.check(contact) {
+			t.Errorf("%v: decoded contact is not correct, got %+v", c.name, contact)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package resource
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+type User struct {
+	gorm.Model
+	Name      string
+	Profile   Profile
+	Addresses []Address
+	Phones    []*Phone
+}
+
+type Profile struct {
+	gorm.Model
+	UserID   uint
+	Nickname string
+}
+
+type Address struct {
+	gorm.Model
+	UserID   uint
+	Address1 string
+	Position int
+}
+
+type Phone struct {
+	gorm.Model
+	UserID *uint
+	Number string
+}
+
+type testMeta struct {
+	*Meta
+	resource Resourcer
+	metas    []Metaor
+}
+
+func (meta testMeta) GetResource() Resourcer {
+	return meta.resource
+}
+
+func (meta testMeta) GetMetas() []Metaor {
+	return meta.metas
+}
+
+func newTestMeta(meta *Meta, child Resourcer, metas ...Metaor) Metaor {
+	meta.PerInitialize()
+	meta.Initialize()
+	return testMeta{Meta: meta, resource: child, metas: metas}
+}
+
+type testResource struct {
+	*Resource
+	metas []Metaor
+}
+
+func (res testResource) GetMetas([]string) []Metaor {
+	return res.metas
+}
+
+func newUserResource() (testResource, *TM_EC.Context) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&User{}, &Profile{}, &Address{}, &Phone{})
+	db.AutoMigrate(&User{}, &Profile{}, &Address{}, &Phone{})
+
+	var (
+		userRes    = New(&User{})
+		profileRes = New(&Profile{})
+		addressRes = New(&Address{})
+		phoneRes   = New(&Phone{})
+	)
+
+	metas := []Metaor{
+		newTestMeta(&Meta{Name: "Name", Resource: userRes}, nil),
+		newTestMeta(&Meta{Name: "Profile", Resource: userRes}, profileRes,
+			newTestMeta(&Meta{Name: "Nickname", Resource: profileRes}, nil),
+		),
+		newTestMeta(&Meta{Name: "Addresses", Resource: userRes, Config: &AssociationConfig{Orphans: DeleteOrphans}}, addressRes,
+			newTestMeta(&Meta{Name: "Address1", Resource: addressRes}, nil),
+		),
+		newTestMeta(&Meta{Name: "Phones", Resource: userRes, Config: &AssociationConfig{Orphans: NullifyOrphans}}, phoneRes,
+			newTestMeta(&Meta{Name: "Number", Resource: phoneRes}, nil),
+		),
+	}
+
+	return testResource{Resource: userRes, metas: metas}, &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+}
+
+func saveUserFromJSON(t *testing.T, res testResource, context *TM_EC.Context, user *User, body string) {
+	metaValues, err := ConvertJSONToMetaValues(strings.NewReader(body), res.GetMetas(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DecodeToResource(res, user, metaValues, context).Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.CallSave(user, WithMetaValues(context, user, metaValues)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHasManySetter(t *testing.T) {
+	res, context := newUserResource()
+	db := context.GetDB()
+
+	var user User
+	saveUserFromJSON(t, res, context, &user, `{"Name": "jinzhu", "Addresses": [{"Address1": "A"}, {"Address1": "B"}, {"Address1": "C"}]}`)
+
+	var addresses []Address
+	db.Order("position").Find(&addresses)
+	if len(addresses) != 3 || addresses[0].Address1 != "A" || addresses[2].Position != 3 {
+		t.Fatalf("should create addresses in order, but got %+v", addresses)
+	}
+
+	// update C, move it to the first, destroy A, drop B as orphan, add D
+	user = User{}
+	db.First(&user)
+	saveUserFromJSON(t, res, context, &user, `{"ID": 1, "Addresses": [
+		{"ID": 3, "Address1": "C2"},
+		{"ID": 1, "_destory": "1"},
+		{"Address1": "D"}
+	]}`)
+
+	addresses = []Address{}
+	db.Order("position").Find(&addresses)
+	if len(addresses) != 2 {
+		t.Fatalf("should have 2 addresses left, but got %+v", addresses)
+	}
+
+	if addresses[0].ID != 3 || addresses[0].Address1 != "C2" || addresses[0].Position != 1 {
+		t.Errorf("existing address should be updated and reordered, but got %+v", addresses[0])
+	}
+
+	if addresses[1].Address1 != "D" || addresses[1].UserID != user.ID {
+		t.Errorf("new address should be created, but got %+v", addresses[1])
+	}
+
+	// clear all
+	user = User{}
+	db.First(&user)
+	saveUserFromJSON(t, res, context, &user, `{"ID": 1, "Addresses": []}`)
+	var count int
+	if db.Model(&Address{}).Count(&count); count != 0 {
+		t.Errorf("all addresses should be deleted, but got %v", count)
+	}
+}
+
+func TestHasManyNullifyOrphans(t *testing.T) {
+	res, context := newUserResource()
+	db := context.GetDB()
+
+	var user User
+	saveUserFromJSON(t, res, context, &user, `{"Name": "jinzhu", "Phones": [{"Number": "110"}, {"Number": "120"}]}`)
+
+	user = User{}
+	db.First(&user)
+	saveUserFromJSON(t, res, context, &user, `{"ID": 1, "Phones": [{"ID": 2, "Number": "120"}]}`)
+
+	var phones []Phone
+	db.Order("id").Find(&phones)
+	if len(phones) != 2 || phones[0].UserID != nil || phones[1].UserID == nil || *phones[1].UserID != user.ID {
+		t.Errorf("orphan phone's foreign key should be nullified, but got %+v", phones)
+	}
+}
+
+func TestForeignChildren(t *testing.T) {
+	res, context := newUserResource()
+	db := context.GetDB()
+
+	var user1, user2 User
+	saveUserFromJSON(t, res, context, &user1, `{"Name": "jinzhu", "Addresses": [{"Address1": "A"}]}`)
+	saveUserFromJSON(t, res, context, &user2, `{"Name": "other", "Addresses": [{"Address1": "B"}]}`)
+
+	cases := []string{
+		`{"ID": 2, "Addresses": [{"ID": 1, "Address1": "X"}, {"ID": 2}]}`,
+		`{"ID": 2, "Addresses": [{"ID": 1, "_destory": "1"}, {"ID": 2}]}`,
+		`{"Name": "new", "Addresses": [{"ID": 1, "Address1": "X"}]}`,
+	}
+
+	for _, body := range cases {
+		var (
+			user          User
+			decodeContext = context.Clone()
+		)
+
+		metaValues, _ := ConvertJSONToMetaValues(strings.NewReader(body), res.GetMetas(nil))
+		DecodeToResource(res, &user, metaValues, decodeContext).Start()
+		if !decodeContext.HasError() {
+			t.Errorf("%v: address of other user should be rejected", body)
+		}
+
+		for _, address := range user.Addresses {
+			if address.ID == 1 {
+				t.Errorf("%v: address of other user should not be decoded, but got %+v", body, address)
+			}
+		}
+
+		var address Address
+		if db.First(&address, 1).Error != nil || address.UserID != user1.ID || address.Address1 != "A" {
+			t.Errorf("%v: address of other user should not be changed, but got %+v", body, address)
+		}
+	}
+}
+
+func TestHasOneSetterWithForm(t *testing.T) {
+	res, context := newUserResource()
+	db := context.GetDB()
+
+	decode := func(user *User, form url.Values) {
+		request, _ := http.NewRequest("POST", "/users", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.ParseForm()
+
+		metaValues, _ := ConvertFormToMetaValues(request, res.GetMetas(nil), "ECResource.")
+		if err := DecodeToResource(res, user, metaValues, context).Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := res.CallSave(user, context); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var user User
+	decode(&user, url.Values{"ECResource.Name": {"jinzhu"}, "ECResource.Profile.Nickname": {"jz"}})
+
+	var profile Profile
+	if db.First(&profile); profile.Nickname != "jz" || profile.UserID != user.ID {
+		t.Fatalf("should create profile, but got %+v", profile)
+	}
+
+	user = User{}
+	db.Preload("Profile").First(&user)
+	decode(&user, url.Values{"ECResource.Profile.ID": {"1"}, "ECResource.Profile.Nickname": {"jinzhu"}})
+
+	var profiles []Profile
+	if db.Find(&profiles); len(profiles) != 1 || profiles[0].Nickname != "jinzhu" {
+		t.Errorf("should update profile, but got %+v", profiles)
+	}
+
+	user = User{}
+	db.Preload("Profile").First(&user)
+	decode(&user, url.Values{"ECResource.Profile.ID": {"1"}, "ECResource.Profile._destory": {"1"}})
+
+	profiles = []Profile{}
+	if db.Find(&profiles); len(profiles) != 0 {
+		t.Errorf("should destroy profile, but got %+v", profiles)
+	}
+}
+
+func TestHasManySetterWithForm(t *testing.T) {
+	res, context := newUserResource()
+	db := context.GetDB()
+
+	form := url.Values{"ECResource.Name": {"jinzhu"}}
+	for i := 0; i < 12; i++ {
+		form.Set(fmt.Sprintf("ECResource.Addresses[%v].Address1", i), fmt.Sprint(i))
+	}
+
+	request, _ := http.NewRequest("POST", "/users", strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.ParseForm()
+
+	var user User
+	metaValues, _ := ConvertFormToMetaValues(request, res.GetMetas(nil), "ECResource.")
+	if err := DecodeToResource(res, &user, metaValues, context).Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.CallSave(&user, context); err != nil {
+		t.Fatal(err)
+	}
+
+	var addresses []Address
+	db.Order("position").Find(&addresses)
+	if len(addresses) != 12 {
+		t.Fatalf("should create 12 addresses, but got %+v", addresses)
+	}
+
+	for i, address := range addresses {
+		if address.Address1 != fmt.Sprint(i) || address.Position != i+1 {
+			t.Errorf("address %v should be sorted by submitted index, but got %+v", i, address)
+		}
+	}
+}
+
+type Post struct {
+	gorm.Model
+	Title    string
+	Comments []Comment `gorm:"polymorphic:Owner"`
+}
+
+type Product struct {
+	gorm.Model
+	Name     string
+	Comments []Comment `gorm:"polymorphic:Owner"`
+}
+
+type Comment struct {
+	gorm.Model
+	OwnerID   uint
+	OwnerType string
+	Content   string
+}
+
+func TestPolymorphicAssociation(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Post{}, &Product{}, &Comment{})
+	db.AutoMigrate(&Post{}, &Product{}, &Comment{})
+
+	var (
+		context    = &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+		postRes    = New(&Post{})
+		commentRes = New(&Comment{})
+		comments   = newTestMeta(&Meta{Name: "Comments", Resource: postRes, Config: &AssociationConfig{Orphans: DeleteOrphans}}, commentRes,
+			newTestMeta(&Meta{Name: "Content", Resource: commentRes}, nil),
+		)
+		res = testResource{Resource: postRes, metas: []Metaor{comments}}
+	)
+
+	db.Create(&Product{Name: "product", Comments: []Comment{{Content: "product comment"}}})
+
+	var post Post
+	metaValues, _ := ConvertJSONToMetaValues(strings.NewReader(`{"Comments": [{"Content": "post comment"}]}`), res.GetMetas(nil))
+	DecodeToResource(res, &post, metaValues, context).Start()
+	if err := res.CallSave(&post, context); err != nil {
+		t.Fatal(err)
+	}
+
+	var comment Comment
+	if db.Where("content = ?", "post comment").First(&comment); comment.OwnerType != "posts" || comment.OwnerID != post.ID {
+		t.Errorf("should stamp polymorphic type and id, but got %+v", comment)
+	}
+
+	var loaded Post
+	db.First(&loaded, post.ID)
+	if values, ok := comments.GetValuer()(&loaded, context).([]Comment); !ok || len(values) != 1 || values[0].Content != "post comment" {
+		t.Errorf("valuer should only load comments of post, but got %+v", values)
+	}
+
+	// orphans of other owner types with same id should be kept
+	metaValues, _ = ConvertJSONToMetaValues(strings.NewReader(`{"Comments": []}`), res.GetMetas(nil))
+	DecodeToResource(res, &loaded, metaValues, context).Start()
+	if err := res.CallSave(&loaded, context); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if db.Model(&Comment{}).Where("owner_type = ?", "products").Count(&count); count != 1 {
+		t.Errorf("comments of products should be kept, but got %v", count)
+	}
+}
+
+type Country struct {
+	Code string `gorm:"primary_key"`
+	Name string
+}
+
+type ShippingAddress struct {
+	gorm.Model
+	Address1    string
+	CountryCode string
+	Country     *Country `gorm:"foreignkey:CountryCode"`
+}
+
+type Order struct {
+	gorm.Model
+	ShippingAddressID uint
+	ShippingAddress   *ShippingAddress
+}
+
+func TestNestedFieldMetas(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Country{}, &ShippingAddress{}, &Order{})
+	db.AutoMigrate(&Country{}, &ShippingAddress{}, &Order{})
+
+	var (
+		context     = &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+		orderRes    = New(&Order{})
+		address1    = newTestMeta(&Meta{Name: "Address1", FieldName: "ShippingAddress.Address1", Resource: orderRes}, nil)
+		countryCode = newTestMeta(&Meta{Name: "CountryCode", FieldName: "ShippingAddress.Country.Code", Resource: orderRes}, nil)
+		countryName = newTestMeta(&Meta{Name: "CountryName", FieldName: "ShippingAddress.Country.Name", Resource: orderRes}, nil)
+		res         = testResource{Resource: orderRes, metas: []Metaor{address1, countryCode, countryName}}
+	)
+
+	if field := countryName.(testMeta).FieldStruct; field == nil || field.Name != "Name" {
+		t.Fatalf("should find struct field of nested meta, but got %+v", field)
+	}
+
+	var order Order
+	metaValues, _ := ConvertJSONToMetaValues(strings.NewReader(`{"Address1": "Xuhui", "CountryCode": "CN", "CountryName": "China"}`), res.GetMetas(nil))
+	DecodeToResource(res, &order, metaValues, context).Start()
+	if err := res.CallSave(&order, context); err != nil {
+		t.Fatal(err)
+	}
+
+	var country Country
+	if db.First(&country, "code = ?", "CN"); country.Name != "China" {
+		t.Errorf("should create missing intermediate records, but got %+v", country)
+	}
+
+	var loaded Order
+	db.First(&loaded, order.ID)
+	if name := countryName.GetValuer()(&loaded, context); name != "China" {
+		t.Errorf("should load nested relations lazily, but got %v", name)
+	}
+
+	var empty Order
+	if name := countryName.GetValuer()(&empty, context); name != nil || empty.ShippingAddress != nil {
+		t.Errorf("valuer should not create missing intermediate records, but got %v", name)
+	}
+}
+
+func TestTimeZoneOfContext(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+
+	var (
+		meta    = newTestMeta(&Meta{Name: "CreatedAt", Resource: New(&User{})}, nil)
+		request = httptest.NewRequest("POST", "/users", nil)
+		user    User
+	)
+
+	request.Header.Set("Time-Zone", "Asia/Shanghai")
+	request.AddCookie(&http.Cookie{Name: "timezone", Value: "America/New_York"})
+	context := &TM_EC.Context{Request: request, Config: &TM_EC.Config{TimeLocation: time.UTC}}
+
+	meta.GetSetter()(&user, &MetaValue{Name: "CreatedAt", Value: []string{"2020-01-02 08:30"}, Meta: meta}, context)
+	if !user.CreatedAt.Equal(time.Date(2020, 1, 2, 0, 30, 0, 0, time.UTC)) {
+		t.Errorf("time should be parsed in time zone of the header, but got %v", user.CreatedAt)
+	}
+
+	context = &TM_EC.Context{Request: httptest.NewRequest("GET", "/users", nil), Config: &TM_EC.Config{DB: utils.TestDB()}, TimeLocation: newYork}
+	if value, ok := meta.GetFormattedValuer()(&user, context).(time.Time); !ok || value.Location() != newYork || value.Hour() != 19 {
+		t.Errorf("time should be formatted in time zone of the context, but got %v", value)
+	}
+
+	nestedMeta := newTestMeta(&Meta{Name: "Profile.CreatedAt", Resource: New(&User{})}, nil)
+	user.Profile.CreatedAt = time.Date(2020, 3, 4, 12, 0, 0, 0, time.UTC)
+	if value, ok := nestedMeta.GetFormattedValuer()(&user, context).(time.Time); !ok || !value.Equal(user.Profile.CreatedAt) || value.Location() != newYork {
+		t.Errorf("nested time should be formatted with value of the nested record, but got %v", value)
+	}
+}
@@ -0,0 +1,383 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+//	'PatchOperation' operation of JSON Patch
+type PatchOperation string
+
+const (
+	PatchAdd     PatchOperation = "add"
+	PatchRemove  PatchOperation = "remove"
+	PatchReplace PatchOperation = "replace"
+	PatchMove    PatchOperation = "move"
+	PatchCopy    PatchOperation = "copy"
+	PatchTest    PatchOperation = "test"
+)
+
+//	'ConvertMergePatchToMetaValues' convert JSON Merge Patch (RFC 7396) to meta values, null removes the field, nested objects are merged into existing values, arrays replace existing values
+func ConvertMergePatchToMetaValues(reader io.Reader, metaors []Metaor) (*MetaValues, error) {
+	metaValues, err := ConvertJSONToMetaValues(reader, metaors)
+	if err != nil {
+		return nil, err
+	}
+
+	metaValues.Patch = true
+	return metaValues, nil
+}
+
+type jsonPatchOperation struct {
+	Op    PatchOperation
+	Path  string
+	From  string
+	Value *json.RawMessage
+}
+
+//	'pendingPatch' "test", "move" and "copy" operations of JSON Patch, they depend on values of the record, so they are applied when decoding meta values
+type pendingPatch struct {
+	operation jsonPatchOperation
+	path      []string
+	from      []string
+	value     interface{}
+	metaors   []Metaor
+}
+
+//	'ConvertJSONPatchToMetaValues' convert JSON Patch (RFC 6902) to meta values, operations are applied in order to the existing record
+//	Paths are meta names, elements of has many metas and slice fields are referred by index, e.g. "/Addresses/0/Address1", "/Tags/-"
+//	All operations are supported, "test", "move" and "copy" operations are evaluated in order with values of the record changed by preceding operations
+//	Values of has one, has many metas are built from their metas, so moved or copied elements are new records without primary keys
+func ConvertJSONPatchToMetaValues(reader io.Reader, metaors []Metaor) (*MetaValues, error) {
+	var operations []jsonPatchOperation
+	if err := json.NewDecoder(reader).Decode(&operations); err != nil {
+		return nil, err
+	}
+
+	metaValues := &MetaValues{Patch: true}
+	for _, operation := range operations {
+		var value interface{}
+		switch operation.Op {
+		case PatchAdd, PatchReplace, PatchTest:
+			if operation.Value == nil {
+				return nil, i18n.NewError("ec.errors.invalid_patch", operation.Op, operation.Path)
+			}
+
+			decoder := json.NewDecoder(strings.NewReader(string(*operation.Value)))
+			decoder.UseNumber()
+			if err := decoder.Decode(&value); err != nil {
+				return nil, err
+			}
+		case PatchRemove:
+		case PatchMove, PatchCopy:
+			//	a value couldn't be moved into itself
+			if !strings.HasPrefix(operation.From, "/") || operation.Op == PatchMove && strings.HasPrefix(operation.Path, operation.From+"/") {
+				return nil, i18n.NewError("ec.errors.invalid_patch", operation.Op, operation.From)
+			}
+		default:
+			return nil, i18n.NewError("ec.errors.unsupported_patch", operation.Op)
+		}
+
+		segments, ok := patchPath(operation.Path)
+		if !ok {
+			return nil, i18n.NewError("ec.errors.invalid_patch", operation.Op, operation.Path)
+		}
+
+		if operation.Op == PatchTest || operation.Op == PatchMove || operation.Op == PatchCopy {
+			from, _ := patchPath(operation.From)
+			metaValue := &MetaValue{Name: segments[0], Operation: operation.Op}
+			metaValue.patch = &pendingPatch{operation: operation, path: segments, from: from, value: value, metaors: metaors}
+			for _, metaor := range metaors {
+				if metaor.GetName() == segments[0] {
+					metaValue.Meta = metaor
+				}
+			}
+			metaValues.Values = append(metaValues.Values, metaValue)
+			continue
+		}
+
+		values, err := convertPatchOperation(segments, operation, value, metaors)
+		if err != nil {
+			return nil, err
+		}
+		metaValues.Values = append(metaValues.Values, values...)
+	}
+	return metaValues, nil
+}
+
+//	'patchPath' split JSON pointer to unescaped segments
+func patchPath(path string) (segments []string, ok bool) {
+	if !strings.HasPrefix(path, "/") || path == "/" {
+		return nil, false
+	}
+
+	for _, segment := range strings.Split(path[1:], "/") {
+		segments = append(segments, strings.NewReplacer("~1", "/", "~0", "~").Replace(segment))
+	}
+	return segments, true
+}
+
+func convertPatchOperation(segments []string, operation jsonPatchOperation, value interface{}, metaors []Metaor) ([]*MetaValue, error) {
+	var (
+		name   = segments[0]
+		metaor Metaor
+		metas  []Metaor
+	)
+
+	for _, m := range metaors {
+		if m.GetName() == name {
+			metaor, metas = m, m.GetMetas()
+		}
+	}
+
+	if name == "" {
+		return nil, i18n.NewError("ec.errors.invalid_patch", operation.Op, operation.Path)
+	}
+
+	if len(segments) == 1 {
+		switch operation.Op {
+		case PatchRemove:
+			return []*MetaValue{{Name: name, Meta: metaor, Null: true}}, nil
+		default:
+			values, err := converMapToMetaValues(map[string]interface{}{name: value}, metaors)
+			if err != nil {
+				return nil, err
+			}
+			return values.Values, nil
+		}
+	}
+
+	if index, ok := patchIndex(segments[1]); ok {
+		metaValue := &MetaValue{Name: name, Meta: metaor, Index: index, Operation: operation.Op}
+		if len(segments) > 2 {
+			//	change fields of the element
+			children, err := convertPatchOperation(segments[2:], operation, value, metas)
+			if err != nil {
+				return nil, err
+			}
+			metaValue.Operation, metaValue.MetaValues = PatchReplace, &MetaValues{Values: children}
+		} else if mapValue, ok := value.(map[string]interface{}); ok {
+			children, err := converMapToMetaValues(mapValue, metas)
+			if err != nil {
+				return nil, err
+			}
+			metaValue.MetaValues = children
+		} else {
+			metaValue.Value = value
+		}
+
+		if index < 0 && metaValue.Operation != PatchAdd {
+			return nil, i18n.NewError("ec.errors.invalid_patch", operation.Op, operation.Path)
+		}
+		return []*MetaValue{metaValue}, nil
+	}
+
+	children, err := convertPatchOperation(segments[1:], operation, value, metas)
+	if err != nil {
+		return nil, err
+	}
+	return []*MetaValue{{Name: name, Meta: metaor, MetaValues: &MetaValues{Values: children}}}, nil
+}
+
+//	'patchIndex' parse array index of JSON pointer, "-" refers to the end of array
+func patchIndex(segment string) (int, bool) {
+	if segment == "-" {
+		return -1, true
+	}
+
+	if index, err := strconv.Atoi(segment); err == nil && index >= 0 && (segment == "0" || !strings.HasPrefix(segment, "0")) {
+		return index, true
+	}
+	return 0, false
+}
+
+//	'patchSlice' apply operation of meta value to slice field, elements of "add", "replace" operations are built with newElement, existing element is passed when replacing
+func patchSlice(field reflect.Value, metaValue *MetaValue, newElement func(existing reflect.Value) (reflect.Value, bool)) error {
+	var (
+		index  = metaValue.Index
+		length = field.Len()
+	)
+
+	if index < 0 && metaValue.Operation == PatchAdd {
+		index = length
+	}
+
+	if index < 0 || index > length || index == length && metaValue.Operation != PatchAdd {
+		return i18n.NewError("ec.errors.invalid_patch_index", metaValue.Index)
+	}
+
+	switch metaValue.Operation {
+	case PatchAdd:
+		if element, ok := newElement(reflect.Value{}); ok {
+			result := reflect.MakeSlice(field.Type(), 0, length+1)
+			result = reflect.AppendSlice(result, field.Slice(0, index))
+			result = reflect.Append(result, element)
+			field.Set(reflect.AppendSlice(result, field.Slice(index, length)))
+		}
+	case PatchReplace:
+		if element, ok := newElement(field.Index(index)); ok {
+			field.Index(index).Set(element)
+		}
+	case PatchRemove:
+		result := reflect.MakeSlice(field.Type(), 0, length-1)
+		result = reflect.AppendSlice(result, field.Slice(0, index))
+		field.Set(reflect.AppendSlice(result, field.Slice(index+1, length)))
+	}
+	return nil
+}
+
+//	'patchPathValue' get value of the JSON pointer from the record, values of metas that have metas are converted to maps of their metas' values
+func patchPathValue(record interface{}, segments []string, metaors []Metaor, context *TM_EC.Context) (interface{}, bool) {
+	for _, metaor := range metaors {
+		if metaor.GetName() != segments[0] {
+			continue
+		}
+
+		if len(metaor.GetMetas()) > 0 {
+			//	valuers reload associations from database, so take them from the record to get changes of preceding operations
+			if field, ok := context.GetDB().NewScope(record).FieldByName(metaor.GetFieldName()); ok && field.Field.CanAddr() {
+				loadChildren(record, metaor, context)
+				return patchMetaValue(field.Field.Addr().Interface(), segments[1:], metaor.GetMetas(), context)
+			}
+		}
+
+		if valuer := metaor.GetValuer(); valuer != nil {
+			return patchMetaValue(valuer(record, context), segments[1:], metaor.GetMetas(), context)
+		}
+	}
+	return nil, false
+}
+
+func patchMetaValue(value interface{}, segments []string, metaors []Metaor, context *TM_EC.Context) (interface{}, bool) {
+	reflectValue := reflect.ValueOf(value)
+	for reflectValue.Kind() == reflect.Ptr && !reflectValue.IsNil() {
+		reflectValue = reflectValue.Elem()
+	}
+
+	element := func(index int) interface{} {
+		if elem := reflectValue.Index(index); elem.CanAddr() && elem.Kind() != reflect.Ptr {
+			return elem.Addr().Interface()
+		}
+		return reflectValue.Index(index).Interface()
+	}
+
+	if len(segments) == 0 {
+		switch {
+		case len(metaors) == 0 || !reflectValue.IsValid():
+			return value, true
+		case reflectValue.Kind() == reflect.Slice:
+			values := []interface{}{}
+			for i := 0; i < reflectValue.Len(); i++ {
+				elem, _ := patchMetaValue(element(i), nil, metaors, context)
+				values = append(values, elem)
+			}
+			return values, true
+		default:
+			values := map[string]interface{}{}
+			for _, metaor := range metaors {
+				if elem, ok := patchPathValue(value, []string{metaor.GetName()}, metaors, context); ok {
+					values[metaor.GetName()] = elem
+				}
+			}
+			return values, true
+		}
+	}
+
+	if reflectValue.Kind() == reflect.Slice {
+		if index, ok := patchIndex(segments[0]); ok && index >= 0 && index < reflectValue.Len() {
+			return patchMetaValue(element(index), segments[1:], metaors, context)
+		}
+		return nil, false
+	}
+
+	if len(metaors) == 0 || !reflectValue.IsValid() {
+		return nil, false
+	}
+	return patchPathValue(value, segments, metaors, context)
+}
+
+//	'toPatchValue' convert value to the value decoded from JSON, so it could be converted to meta values like the input
+func toPatchValue(value interface{}) (interface{}, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	decoder := json.NewDecoder(strings.NewReader(string(content)))
+	decoder.UseNumber()
+	err = decoder.Decode(&result)
+	return result, err
+}
+
+//	'patchValueEqual' check value of "test" operation equals to current value
+func patchValueEqual(current interface{}, value interface{}) bool {
+	reflectValue := reflect.ValueOf(current)
+	for reflectValue.Kind() == reflect.Ptr {
+		if reflectValue.IsNil() {
+			return value == nil
+		}
+		reflectValue = reflectValue.Elem()
+	}
+
+	if !reflectValue.IsValid() || value == nil {
+		return !reflectValue.IsValid() && value == nil
+	}
+
+	if values, ok := value.(map[string]interface{}); ok {
+		currentValues, ok := current.(map[string]interface{})
+		if !ok || len(values) != len(currentValues) {
+			return false
+		}
+
+		for key, value := range values {
+			if !patchValueEqual(currentValues[key], value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if reflectValue.Kind() == reflect.Slice && reflectValue.Type().Elem().Kind() != reflect.Uint8 {
+		values, ok := value.([]interface{})
+		if !ok || len(values) != reflectValue.Len() {
+			return false
+		}
+
+		for i := 0; i < reflectValue.Len(); i++ {
+			if !patchValueEqual(reflectValue.Index(i).Interface(), values[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return fmt.Sprint(reflectValue.Interface()) == fmt.Sprint(value)
+}
+
+//	'convertElement' convert value to element of slice fields, it panics if value is invalid for the type
+func convertElement(value interface{}, elemType reflect.Type) reflect.Value {
+	element := reflect.New(elemType).Elem()
+	switch elemType.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		element.SetInt(utils.ToInt(value))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		element.SetUint(utils.ToUInt(value))
+	case reflect.Float32, reflect.Float64:
+		element.SetFloat(utils.ToFloat(value))
+	case reflect.Bool:
+		element.SetBool(utils.ToString(value) == "true")
+	case reflect.String:
+		element.SetString(utils.ToString(value))
+	default:
+		element.Set(reflect.ValueOf(value).Convert(elemType))
+	}
+	return element
+}
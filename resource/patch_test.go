@@ -0,0 +1,130 @@
+package resource
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+func TestPatch(t *testing.T) {
+	res, context := newUserResource()
+
+	var user User
+	saveUserFromJSON(t, res, context, &user, `{"Name": "jinzhu", "Profile": {"Nickname": "jz"}, "Addresses": [{"Address1": "A"}, {"Address1": "B"}, {"Address1": "C"}]}`)
+
+	patch := func(contentType string, resourceID interface{}, body string) error {
+		var (
+			result  User
+			context = &TM_EC.Context{Config: context.Config, ResourceID: fmt.Sprint(resourceID)}
+		)
+
+		context.Request = httptest.NewRequest("PATCH", "/users", strings.NewReader(body))
+		context.Request.Header.Set("Content-Type", contentType)
+		if err := Decode(context, &result, res); err != nil {
+			return err
+		}
+
+		if context.HasError() {
+			return context.Errors
+		}
+		return res.CallSave(&result, context)
+	}
+
+	if err := patch("application/merge-patch+json", user.ID, `{"Name": null, "Profile": {"Nickname": "jinzhu"}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		result   User
+		profiles int
+	)
+
+	context.GetDB().Preload("Profile").Preload("Addresses").First(&result, user.ID)
+	context.GetDB().Model(&Profile{}).Count(&profiles)
+	if result.Name != "" || result.Profile.Nickname != "jinzhu" || result.Profile.ID != user.Profile.ID || profiles != 1 || len(result.Addresses) != 3 {
+		t.Errorf("merge patch should only change submitted fields, but got %+v, %v profiles", result, profiles)
+	}
+
+	err := patch("application/json-patch+json", user.ID, `[
+		{"op": "test", "path": "/Name", "value": ""},
+		{"op": "replace", "path": "/Addresses/1/Address1", "value": "B2"},
+		{"op": "remove", "path": "/Addresses/0"},
+		{"op": "add", "path": "/Addresses/-", "value": {"Address1": "D"}},
+		{"op": "add", "path": "/Addresses/0", "value": {"Address1": "Z"}},
+		{"op": "add", "path": "/Name", "value": "jinzhu"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var addresses []Address
+	context.GetDB().Order("position").Find(&addresses, "user_id = ?", user.ID)
+	var names []string
+	for _, address := range addresses {
+		names = append(names, address.Address1)
+	}
+
+	if strings.Join(names, " ") != "Z B2 C D" {
+		t.Errorf("json patch should be applied to addresses in order, but got %+v", addresses)
+	}
+
+	if addresses[1].ID != user.Addresses[1].ID {
+		t.Errorf("replaced address should keep its primary key, but got %v", addresses[1].ID)
+	}
+
+	errorCases := []struct {
+		contentType string
+		resourceID  interface{}
+		body        string
+	}{
+		{"application/json-patch+json", user.ID, `[{"op": "test", "path": "/Name", "value": "unknown"}, {"op": "replace", "path": "/Name", "value": "changed"}]`},
+		{"application/json-patch+json", user.ID, `[{"op": "remove", "path": "/Addresses/10"}]`},
+		{"application/json-patch+json", user.ID, `[{"op": "move", "from": "/Profile", "path": "/Profile/Nickname"}]`},
+		{"application/json-patch+json", user.ID, `[{"op": "copy", "from": "/Addresses/10", "path": "/Name"}]`},
+		{"application/json-patch+json", user.ID, `[{"op": "replace", "path": "/Name", "value": "changed"}, {"op": "test", "path": "/Name", "value": "jinzhu"}]`},
+		{"application/json-patch+json", user.ID, `[{"op": "replace", "path": "/Addresses/-", "value": {"Address1": "E"}}]`},
+		{"application/merge-patch+json", user.ID + 100, `{"Name": "changed"}`},
+	}
+
+	for _, c := range errorCases {
+		if err := patch(c.contentType, c.resourceID, c.body); err == nil {
+			t.Errorf("patch %v should return error", c.body)
+		}
+	}
+
+	context.GetDB().First(&result, user.ID)
+	if result.Name != "jinzhu" {
+		t.Errorf("failed patches should not change the record, but got %v", result.Name)
+	}
+
+	//	operations are applied in order, "test", "move" and "copy" see changes of preceding operations
+	err = patch("application/json-patch+json", user.ID, `[
+		{"op": "replace", "path": "/Name", "value": "jz"},
+		{"op": "test", "path": "/Name", "value": "jz"},
+		{"op": "copy", "from": "/Name", "path": "/Profile/Nickname"},
+		{"op": "test", "path": "/Profile", "value": {"Nickname": "jz"}},
+		{"op": "move", "from": "/Addresses/0", "path": "/Addresses/-"},
+		{"op": "copy", "from": "/Addresses/1", "path": "/Addresses/0"},
+		{"op": "test", "path": "/Addresses/0/Address1", "value": "C"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result = User{}
+	context.GetDB().Preload("Profile").Preload("Addresses", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).First(&result, user.ID)
+	names = nil
+	for _, address := range result.Addresses {
+		names = append(names, address.Address1)
+	}
+
+	if result.Name != "jz" || result.Profile.Nickname != "jz" || strings.Join(names, " ") != "C B2 C D Z" {
+		t.Errorf("move, copy and test operations should be applied in order, but got %v, %v, %v", result.Name, result.Profile.Nickname, names)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/jinzhu/gorm"
 
 	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
 	"github.com/Sky-And-Hammer/roles"
 )
 
@@ -15,12 +16,14 @@ import (
 var ErrProcessorSkipLeft = errors.New("resource: skip left")
 
 type processor struct {
-	Result     interface{}
-	Resource   Resourcer
-	Context    *TM_EC.Context
-	MetaValues *MetaValues
-	SkipLeft   bool
-	newRecord  bool
+	Result      interface{}
+	Resource    Resourcer
+	Context     *TM_EC.Context
+	MetaValues  *MetaValues
+	SkipLeft    bool
+	newRecord   bool
+	nested      bool
+	parentScope func(*gorm.DB) *gorm.DB
 }
 
 //	'DecodeToResource' decode meta values to resource result
@@ -52,7 +55,23 @@ func (processor *processor) checkSkipLeft(errs ...error) bool {
 }
 
 func (processor *processor) Initialize() error {
-	err := processor.Resource.CallFindOne(processor.Resource, processor.MetaValues, processor.Context)
+	context := processor.Context
+	if processor.parentScope != nil {
+		//	only the child itself is found in the parent's scope, its nested children are not
+		context = context.Clone()
+		context.SetDB(context.GetDB().Set(parentScopeSettingKey, processor.parentScope))
+	}
+
+	err := processor.Resource.CallFindOne(processor.Result, processor.MetaValues, context)
+	if err != nil && processor.isPatch() && processor.newRecord && processor.Context.ResourceID != "" {
+		//	patches without primary key are applied to the record of the context
+		err = processor.Resource.CallFindOne(processor.Result, nil, processor.Context)
+	}
+
+	if err == nil {
+		//	record is found with submitted primary key
+		processor.newRecord = processor.Context.GetDB().NewScope(processor.Result).PrimaryKeyZero()
+	}
 	processor.checkSkipLeft(err)
 	return err
 }
@@ -70,40 +89,113 @@ func (processor *processor) Validate() error {
 			}
 		}
 	}
+
+	if !processor.checkSkipLeft() {
+		errors.AddError(processor.Resource.GetResource().validateRules(processor.Result, processor.MetaValues, processor.newRecord, processor.Context))
+	}
 	return errors
 }
 
+func (processor *processor) isPatch() bool {
+	return !processor.nested && processor.MetaValues != nil && processor.MetaValues.Patch
+}
+
+//	'applyPatch' apply "test", "move" and "copy" operations of JSON Patch with current values of the record, which are changed by preceding operations
+func (processor *processor) applyPatch(metaValue *MetaValue, loaded map[string]bool) error {
+	var (
+		patch     = metaValue.patch
+		operation = patch.operation
+	)
+
+	if operation.Op == PatchTest {
+		if current, ok := patchPathValue(processor.Result, patch.path, patch.metaors, processor.Context); !ok || !patchValueEqual(current, patch.value) {
+			return NewFieldError(processor.Result, metaValue.Name, i18n.NewError("ec.errors.patch_test_failed", operation.Path, patch.value))
+		}
+		return nil
+	}
+
+	current, ok := patchPathValue(processor.Result, patch.from, patch.metaors, processor.Context)
+	if !ok {
+		return i18n.NewError("ec.errors.invalid_patch", operation.Op, operation.From)
+	}
+
+	value, err := toPatchValue(current)
+	if err != nil {
+		return err
+	}
+
+	var metaValues []*MetaValue
+	if operation.Op == PatchMove {
+		removes, err := convertPatchOperation(patch.from, jsonPatchOperation{Op: PatchRemove, Path: operation.From}, nil, patch.metaors)
+		if err != nil {
+			return err
+		}
+		metaValues = append(metaValues, removes...)
+	}
+
+	adds, err := convertPatchOperation(patch.path, jsonPatchOperation{Op: PatchAdd, Path: operation.Path}, value, patch.metaors)
+	if err != nil {
+		return err
+	}
+
+	for _, metaValue := range append(metaValues, adds...) {
+		processor.decodeMetaValue(metaValue, loaded)
+	}
+	return nil
+}
+
+//	'loadAssociations' load associations of the meta before applying patches, so patches are applied to existing associations
+func (processor *processor) loadAssociations(meta Metaor) {
+	loadChildren(processor.Result, meta, processor.Context)
+}
+
 func (processor *processor) decode() (errors []error) {
 	if processor.checkSkipLeft() || processor.MetaValues == nil {
 		return
 	}
 
+	loaded := map[string]bool{}
 	for _, metaValue := range processor.MetaValues.Values {
-		meta := metaValue.Meta
-		if meta == nil {
+		if metaValue.patch != nil {
+			if err := processor.applyPatch(metaValue, loaded); err != nil {
+				errors = append(errors, err)
+			}
 			continue
 		}
+		processor.decodeMetaValue(metaValue, loaded)
+	}
+	return
+}
 
-		if processor.newRecord && !meta.HasPermission(roles.Create, processor.Context) {
-			continue
-		} else if !meta.HasPermission(roles.Update, processor.Context) {
-			continue
-		}
+func (processor *processor) decodeMetaValue(metaValue *MetaValue, loaded map[string]bool) {
+	meta := metaValue.Meta
+	if meta == nil || metaValue.Absent {
+		return
+	}
 
-		if setter := meta.GetSetter(); setter != nil {
-			setter(processor.Result, metaValue, processor.Context)
-			continue
-		}
+	if processor.newRecord && !meta.HasPermission(roles.Create, processor.Context) {
+		return
+	} else if !meta.HasPermission(roles.Update, processor.Context) {
+		return
+	}
 
-		res := metaValue.Meta.GetResource()
-		if res == nil {
-			continue
-		}
+	if (processor.isPatch() || metaValue.Operation != "") && !processor.newRecord && !loaded[meta.GetName()] {
+		loaded[meta.GetName()] = true
+		processor.loadAssociations(meta)
+	}
 
-		field := reflect.Indirect(reflect.ValueOf(processor.Result)).FieldByName(meta.GetFieldName())
-		decodeMetaValuesToField(res, field, metaValue, processor.Context)
+	if setter := meta.GetSetter(); setter != nil {
+		setter(processor.Result, metaValue, processor.Context)
+		return
 	}
-	return
+
+	res := metaValue.Meta.GetResource()
+	if res == nil {
+		return
+	}
+
+	field := reflect.Indirect(reflect.ValueOf(processor.Result)).FieldByName(meta.GetFieldName())
+	decodeMetaValuesToField(res, processor.Result, field, metaValue, processor.Context)
 }
 
 func (processor *processor) Commit() error {
@@ -124,11 +216,67 @@ func (processor *processor) Commit() error {
 	return errors
 }
 
+//	'snapshot' get field values of submitted metas before decoding, they are compared with decoded values to find changed metas
+//	Associations are not loaded for it, so metas of associations are always treated as changed
+func (processor *processor) snapshot() map[*MetaValue]interface{} {
+	originals := map[*MetaValue]interface{}{}
+	if processor.nested || processor.MetaValues == nil {
+		return originals
+	}
+
+	scope := processor.Context.GetDB().NewScope(processor.Result)
+	for _, metaValue := range processor.MetaValues.Values {
+		if metaValue.Meta == nil {
+			continue
+		}
+
+		if field, ok := scope.FieldByName(metaValue.Meta.GetFieldName()); ok && field.Relationship == nil && field.Field.CanInterface() {
+			originals[metaValue] = copySlice(field.Field.Interface())
+		}
+	}
+	return originals
+}
+
+//	'compare' mark metas unchanged if their field values are same as the snapshot
+func (processor *processor) compare(originals map[*MetaValue]interface{}) {
+	scope := processor.Context.GetDB().NewScope(processor.Result)
+	for metaValue, original := range originals {
+		if field, ok := scope.FieldByName(metaValue.Meta.GetFieldName()); ok {
+			metaValue.unchanged = reflect.DeepEqual(original, field.Field.Interface())
+		}
+	}
+}
+
+//	'copySlice' copy slices, so elements changed in place won't change the snapshot
+func copySlice(value interface{}) interface{} {
+	if reflectValue := reflect.ValueOf(value); reflectValue.Kind() == reflect.Slice && !reflectValue.IsNil() {
+		copied := reflect.MakeSlice(reflectValue.Type(), reflectValue.Len(), reflectValue.Len())
+		reflect.Copy(copied, reflectValue)
+		return copied.Interface()
+	}
+	return value
+}
+
 func (processor *processor) Start() error {
 	var errors TM_EC.Errors
-	processor.Initialize()
+	if !processor.nested {
+		//	make meta values accessible for validators and processors of the record while decoding, and restore the context after, use 'WithMetaValues' to pass them to save, delete callbacks
+		previous, _ := processor.Context.GetDB().Get(metaValuesSettingKey)
+		processor.Context.SetDB(processor.Context.GetDB().Set(metaValuesSettingKey, &recordMetaValues{record: processor.Result, metaValues: processor.MetaValues}))
+		defer func() {
+			processor.Context.SetDB(processor.Context.GetDB().Set(metaValuesSettingKey, previous))
+		}()
+	}
+
+	if err := processor.Initialize(); err == ErrForeignRecord || err != nil && processor.isPatch() && processor.newRecord {
+		//	patches could only be applied to existing records
+		return err
+	}
+
 	if errors.AddError(processor.Validate()); !errors.HasError() {
+		originals := processor.snapshot()
 		errors.AddError(processor.Commit())
+		processor.compare(originals)
 	}
 
 	if errors.HasError() {
@@ -1,7 +1,10 @@
 package resource
 
 import (
+	"fmt"
+	"net/url"
 	"reflect"
+	"strings"
 
 	//  The fantastic ORM library for Golang, aims to be developer friendly.
 	"github.com/jinzhu/gorm"
@@ -35,16 +38,20 @@ type ConfigureResourceInterface interface {
 
 //	'Resource' is a struct that including basic definition of EC resource
 type Resource struct {
-	Name            string
-	Value           interface{}
-	FindManyHandler func(interface{}, *TM_EC.Context) error
-	FindOneHandler  func(interface{}, *MetaValues, *TM_EC.Context) error
-	SaveHandler     func(interface{}, *TM_EC.Context) error
-	DeleteHandler   func(interface{}, *TM_EC.Context) error
-	Permission      *roles.Permission
-	Validatiors     []func(interface{}, *MetaValues, *TM_EC.Context) error
-	Processors      []func(interface{}, *MetaValues, *TM_EC.Context) error
-	primaryField    *gorm.Field
+	Name               string
+	Value              interface{}
+	FindManyHandler    func(interface{}, *TM_EC.Context) error
+	FindOneHandler     func(interface{}, *MetaValues, *TM_EC.Context) error
+	SaveHandler        func(interface{}, *TM_EC.Context) error
+	DeleteHandler      func(interface{}, *TM_EC.Context) error
+	Permission         *roles.Permission
+	Validatiors        []func(interface{}, *MetaValues, *TM_EC.Context) error
+	Processors         []func(interface{}, *MetaValues, *TM_EC.Context) error
+	Callbacks          []*Callback
+	Rules              []*Rule
+	EventBus           *EventBus
+	PrimaryFields      []*gorm.StructField
+	TranslatableFields []*gorm.StructField
 }
 
 //	'New' initialize EC resource
@@ -61,6 +68,7 @@ func New(value interface{}) *Resource {
 	res.FindManyHandler = res.findManyHandler
 	res.SaveHandler = res.saveHandler
 	res.DeleteHandler = res.deleteHandler
+	res.SetPrimaryFields()
 	return res
 }
 
@@ -98,6 +106,27 @@ func (res *Resource) GetMetas([]string) []Metaor {
 	panic("not defined")
 }
 
+//	'DefaultMetas' get metas of the resource with 'GetMetas', base resource doesn't define metas, so metas of its normal fields are built for it
+func DefaultMetas(res Resourcer) []Metaor {
+	base, ok := res.(*Resource)
+	if !ok {
+		return res.GetMetas([]string{})
+	}
+
+	var metas []Metaor
+	for _, field := range (&gorm.Scope{Value: base.Value}).GetModelStruct().StructFields {
+		if !field.IsNormal || field.IsIgnored {
+			continue
+		}
+
+		meta := &Meta{Name: field.Name, Resource: base}
+		meta.PerInitialize()
+		meta.Initialize()
+		metas = append(metas, meta)
+	}
+	return metas
+}
+
 //	'HasPermission' check permission of resource
 func (res *Resource) HasPermission(mode roles.PermissionMode, context *TM_EC.Context) bool {
 	if res == nil || res.Permission == nil {
@@ -107,32 +136,177 @@ func (res *Resource) HasPermission(mode roles.PermissionMode, context *TM_EC.Con
 	return res.Permission.HasPermission(mode, context.Roles...)
 }
 
-//	'PrimaryField' return gorm's primary field
-func (res *Resource) PrimaryField() *gorm.Field {
-	if res.primaryField == nil {
-		scope := gorm.Scope{
-			Value: res.Value,
+//	'SetPrimaryFields' set primary fields of the resource by struct field names, gorm's primary fields will be used if no names given
+//	Resources with multiple primary fields use composite primary values, refer 'EncodePrimaryValues'
+func (res *Resource) SetPrimaryFields(fields ...string) error {
+	scope := gorm.Scope{Value: res.Value}
+	res.PrimaryFields = nil
+
+	if len(fields) == 0 {
+		res.PrimaryFields = scope.GetModelStruct().PrimaryFields
+		return nil
+	}
+
+	for _, fieldName := range fields {
+		field, ok := scope.FieldByName(fieldName)
+		if !ok {
+			return fmt.Errorf("resource: %v is not a valid primary field of %v", fieldName, res.Name)
 		}
-		res.primaryField = scope.PrimaryField()
+		res.PrimaryFields = append(res.PrimaryFields, field.StructField)
 	}
+	return nil
+}
 
-	return res.primaryField
+//	'PrimaryField' return gorm's primary field, it is the first one for resources with composite primary keys
+func (res *Resource) PrimaryField() *gorm.Field {
+	if len(res.PrimaryFields) > 0 {
+		scope := gorm.Scope{Value: res.Value}
+		if field, ok := scope.FieldByName(res.PrimaryFields[0].Name); ok {
+			return field
+		}
+	}
+	return nil
 }
 
 //	'PrimaryDBName' return db column name of the resource's primary field
 func (res *Resource) PrimaryDBName() (name string) {
-	filed := res.primaryField
-	if filed != nil {
-		name = filed.Name
+	if len(res.PrimaryFields) > 0 {
+		name = res.PrimaryFields[0].DBName
 	}
 	return
 }
 
 //	'PrimaryFieldName' return struct column name of the resource's primary field
 func (res *Resource) PrimaryFieldName() (name string) {
-	field := res.primaryField
-	if field != nil {
-		name = field.Name
+	if len(res.PrimaryFields) > 0 {
+		name = res.PrimaryFields[0].Name
 	}
 	return
 }
+
+//	'EncodePrimaryValues' encode primary values to a string that could be used as 'Context.ResourceID'
+//	A single value is kept as it is, composite values are escaped and joined with comma, e.g. "1001,3"
+func EncodePrimaryValues(values ...interface{}) string {
+	if len(values) == 1 {
+		return utils.ToString(values[0])
+	}
+
+	var strs []string
+	for _, value := range values {
+		strs = append(strs, url.QueryEscape(utils.ToString(value)))
+	}
+	return strings.Join(strs, ",")
+}
+
+//	'DecodePrimaryValue' decode primary value encoded by 'EncodePrimaryValues', count is the number of primary fields
+func DecodePrimaryValue(primaryValue string, count int) ([]string, error) {
+	if count <= 1 {
+		return []string{primaryValue}, nil
+	}
+
+	strs := strings.Split(primaryValue, ",")
+	if len(strs) != count {
+		return nil, fmt.Errorf("resource: primary value %q should have %v parts", primaryValue, count)
+	}
+
+	for idx, str := range strs {
+		value, err := url.QueryUnescape(str)
+		if err != nil {
+			return nil, err
+		}
+		strs[idx] = value
+	}
+	return strs, nil
+}
+
+//	'GetPrimaryValue' get encoded primary value of the record, returns blank string only if all primary fields are blank, as zero is a valid value of composite primary keys, e.g. line number 0
+func (res *Resource) GetPrimaryValue(record interface{}) string {
+	return getPrimaryValue(record, res.PrimaryFields)
+}
+
+//	'PrimaryValueOf' get encoded primary value of the record with its gorm primary fields
+func PrimaryValueOf(record interface{}) string {
+	return getPrimaryValue(record, (&gorm.Scope{Value: record}).GetModelStruct().PrimaryFields)
+}
+
+func getPrimaryValue(record interface{}, primaryFields []*gorm.StructField) string {
+	var (
+		reflectValue = reflect.Indirect(reflect.ValueOf(record))
+		values       []interface{}
+		blank        = true
+	)
+
+	if reflectValue.Kind() != reflect.Struct || len(primaryFields) == 0 {
+		return ""
+	}
+
+	for _, primaryField := range primaryFields {
+		field := reflectValue.FieldByName(primaryField.Name)
+		if !field.IsValid() {
+			return ""
+		}
+
+		if !isBlank(field) {
+			blank = false
+		}
+		values = append(values, field.Interface())
+	}
+
+	if blank {
+		return ""
+	}
+	return EncodePrimaryValues(values...)
+}
+
+//	'ToPrimaryQueryParams' build query conditions of primary fields from encoded primary value
+func (res *Resource) ToPrimaryQueryParams(primaryValue string, context *TM_EC.Context) (string, []interface{}, error) {
+	if len(res.PrimaryFields) == 0 {
+		return "", nil, fmt.Errorf("resource: %v doesn't have primary fields", res.Name)
+	}
+
+	values, err := DecodePrimaryValue(primaryValue, len(res.PrimaryFields))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var params []interface{}
+	for _, value := range values {
+		params = append(params, value)
+	}
+	return res.primaryQuerySQL(context), params, nil
+}
+
+//	'ToPrimaryQueryParamsFromMetaValue' build query conditions of primary fields from meta values, each primary field's value should be submitted with its field name
+//	Returns blank SQL if any of them is missing or blank, e.g. when creating new records
+func (res *Resource) ToPrimaryQueryParamsFromMetaValue(metaValues *MetaValues, context *TM_EC.Context) (string, []interface{}) {
+	var params []interface{}
+	if metaValues == nil || len(res.PrimaryFields) == 0 {
+		return "", nil
+	}
+
+	for _, primaryField := range res.PrimaryFields {
+		metaValue := metaValues.Get(primaryField.Name)
+		if metaValue == nil || metaValue.Value == nil {
+			return "", nil
+		}
+
+		value := utils.ToString(metaValue.Value)
+		if value == "" {
+			return "", nil
+		}
+		params = append(params, value)
+	}
+	return res.primaryQuerySQL(context), params
+}
+
+func (res *Resource) primaryQuerySQL(context *TM_EC.Context) string {
+	var (
+		scope = context.GetDB().NewScope(res.Value)
+		sqls  []string
+	)
+
+	for _, primaryField := range res.PrimaryFields {
+		sqls = append(sqls, fmt.Sprintf("%v.%v = ?", scope.QuotedTableName(), scope.Quote(primaryField.DBName)))
+	}
+	return strings.Join(sqls, " AND ")
+}
@@ -0,0 +1,349 @@
+package resource
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+const (
+	//	'ValidationGroupCreate' validation group that is active when creating records
+	ValidationGroupCreate = "create"
+	//	'ValidationGroupUpdate' validation group that is active when updating records
+	ValidationGroupUpdate = "update"
+
+	validationGroupsSettingKey = "ec:validation_groups"
+)
+
+//	'RuleCondition' condition of a rule, the rule is only validated when it returns true
+type RuleCondition func(*RuleContext) bool
+
+//	'Rule' a validation rule of resource, it could reference other metas, nested meta values, and query database
+//	'Groups' is validation groups the rule belongs to, e.g. "create", "update", "publish", blank means it is always validated
+//	'If' is the condition of the rule, blank means it is always validated
+//		res.AddRule(&resource.Rule{
+//			Name: "shipped_at",
+//			If:   resource.ValueEquals("Status", "shipped"),
+//			Validate: func(rule *resource.RuleContext) error {
+//				return rule.Require("ShippedAt")
+//			},
+//		})
+type Rule struct {
+	Name     string
+	Groups   []string
+	If       RuleCondition
+	Validate func(*RuleContext) error
+}
+
+//	'RuleContext' information of the record that is validating, it is passed to rules
+type RuleContext struct {
+	Record     interface{}
+	MetaValues *MetaValues
+	Context    *TM_EC.Context
+	NewRecord  bool
+	Groups     []string
+}
+
+//	'AddRule' add validation rule to resource, the rule with same name will be replaced, rules are validated after validators
+func (res *Resource) AddRule(rule *Rule) *Rule {
+	for idx, r := range res.Rules {
+		if r.Name == rule.Name {
+			res.Rules[idx] = rule
+			return rule
+		}
+	}
+
+	res.Rules = append(res.Rules, rule)
+	return rule
+}
+
+//	'WithValidationGroups' activate validation groups for records decoded with the context, e.g. "publish", "create" or "update" is always active according to the record's state
+func WithValidationGroups(context *TM_EC.Context, groups ...string) *TM_EC.Context {
+	groups = append(append([]string{}, getValidationGroups(context)...), groups...)
+	context = context.Clone()
+	context.SetDB(context.GetDB().Set(validationGroupsSettingKey, groups))
+	return context
+}
+
+func getValidationGroups(context *TM_EC.Context) []string {
+	if value, ok := context.GetDB().Get(validationGroupsSettingKey); ok {
+		if groups, ok := value.([]string); ok {
+			return groups
+		}
+	}
+	return nil
+}
+
+//	'validateRules' validate rules of active validation groups
+func (res *Resource) validateRules(record interface{}, metaValues *MetaValues, newRecord bool, context *TM_EC.Context) error {
+	if len(res.Rules) == 0 {
+		return nil
+	}
+
+	var (
+		errors      TM_EC.Errors
+		ruleContext = &RuleContext{Record: record, MetaValues: metaValues, Context: context, NewRecord: newRecord}
+	)
+
+	if newRecord {
+		ruleContext.Groups = append([]string{ValidationGroupCreate}, getValidationGroups(context)...)
+	} else {
+		ruleContext.Groups = append([]string{ValidationGroupUpdate}, getValidationGroups(context)...)
+	}
+
+	for _, rule := range res.Rules {
+		if rule.Validate == nil || !ruleContext.InGroups(rule.Groups...) {
+			continue
+		}
+
+		if rule.If != nil && !rule.If(ruleContext) {
+			continue
+		}
+
+		errors.AddError(rule.Validate(ruleContext))
+	}
+
+	if errors.HasError() {
+		return errors
+	}
+	return nil
+}
+
+//	'InGroups' check any of groups is active, returns true if no group is given
+func (rule *RuleContext) InGroups(groups ...string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+
+	for _, group := range groups {
+		for _, active := range rule.Groups {
+			if group == active {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//	'Values' get values of meta, submitted values are used if present, otherwise values are got from the record
+//	Name could be path of nested metas like "Items.Quantity", values of all nested records will be returned
+func (rule *RuleContext) Values(name string) []interface{} {
+	return collectValues(rule.MetaValues, reflect.ValueOf(rule.Record), strings.Split(name, "."), rule.Context)
+}
+
+func collectValues(metaValues *MetaValues, record reflect.Value, names []string, context *TM_EC.Context) (values []interface{}) {
+	var submitted bool
+	if metaValues != nil {
+		for _, metaValue := range metaValues.Values {
+			if metaValue.Name != names[0] {
+				continue
+			}
+
+			submitted = true
+			if len(names) == 1 {
+				if metaValue.MetaValues == nil {
+					values = append(values, metaValue.Value)
+				}
+			} else if metaValue.MetaValues != nil {
+				values = append(values, collectValues(metaValue.MetaValues, childRecord(record, metaValue, context), names[1:], context)...)
+			}
+		}
+	}
+
+	if submitted {
+		return
+	}
+
+	record = reflect.Indirect(record)
+	if record.Kind() != reflect.Struct {
+		return
+	}
+
+	field := record.FieldByName(names[0])
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return
+		}
+		field = field.Elem()
+	}
+
+	switch {
+	case !field.IsValid():
+	case len(names) == 1:
+		values = append(values, field.Interface())
+	case field.Kind() == reflect.Slice:
+		for i := 0; i < field.Len(); i++ {
+			values = append(values, collectValues(nil, field.Index(i), names[1:], context)...)
+		}
+	default:
+		values = append(values, collectValues(nil, field, names[1:], context)...)
+	}
+	return
+}
+
+//	'childRecord' get the record of the submitted nested meta value, so values not submitted are got from it, children of has many are matched by primary key
+//	Children are loaded if they are not loaded, the record itself is not changed
+func childRecord(record reflect.Value, metaValue *MetaValue, context *TM_EC.Context) reflect.Value {
+	record = reflect.Indirect(record)
+	if record.Kind() != reflect.Struct || !record.CanAddr() || context == nil || context.GetDB() == nil {
+		return reflect.Value{}
+	}
+
+	scope := context.GetDB().NewScope(record.Addr().Interface())
+	field, ok := scope.FieldByName(metaValue.Name)
+	if !ok {
+		return reflect.Value{}
+	}
+
+	children := field.Field
+	if field.Relationship != nil && isBlank(children) && !scope.PrimaryKeyZero() {
+		loaded := reflect.New(children.Type())
+		if context.GetDB().Model(record.Addr().Interface()).Related(loaded.Interface(), metaValue.Name).Error != nil {
+			return reflect.Value{}
+		}
+		children = loaded.Elem()
+	}
+
+	for children.Kind() == reflect.Ptr {
+		if children.IsNil() {
+			return reflect.Value{}
+		}
+		children = children.Elem()
+	}
+
+	if children.Kind() != reflect.Slice {
+		return children
+	}
+
+	for i := 0; i < children.Len(); i++ {
+		child := reflect.Indirect(children.Index(i))
+		if primaryField := context.GetDB().NewScope(child.Addr().Interface()).PrimaryField(); primaryField != nil && !primaryField.IsBlank {
+			if value := metaValue.MetaValues.Get(primaryField.Name); value != nil && valueString(value.Value) == valueString(primaryField.Field.Interface()) {
+				return child
+			}
+		}
+	}
+	return reflect.Value{}
+}
+
+//	'Value' get first value of meta, refer 'Values'
+func (rule *RuleContext) Value(name string) interface{} {
+	if values := rule.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return nil
+}
+
+//	'String' get first value of meta as string, refer 'Values'
+func (rule *RuleContext) String(name string) string {
+	return valueString(rule.Value(name))
+}
+
+func valueString(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.TrimSpace(utils.ToString(value))
+	default:
+		if reflectValue := reflect.Indirect(reflect.ValueOf(value)); reflectValue.IsValid() {
+			return strings.TrimSpace(fmt.Sprint(reflectValue.Interface()))
+		}
+		return ""
+	}
+}
+
+//	'Sum' sum of meta's values, e.g. rule.Sum("Items.Quantity"), values are parsed with separators of the context's locale like setters, blank values are ignored, returns error if any value is not a number
+func (rule *RuleContext) Sum(name string) (float64, error) {
+	var sum decimal.Decimal
+	for _, value := range rule.Values(name) {
+		str := valueString(value)
+		if str == "" {
+			continue
+		}
+
+		number, err := utils.ToDecimal(str, rule.Context)
+		if err != nil {
+			return 0, i18n.NewError("ec.errors.not_a_number", str)
+		}
+		sum = sum.Add(number)
+	}
+	return sum.Float64(), nil
+}
+
+//	'Error' new validation error of the meta, message is a translation key that is localized when rendering, it is used as message format if it is not a translation key
+func (rule *RuleContext) Error(name string, message string, args ...interface{}) error {
+	return NewFieldError(rule.Record, name, i18n.NewError(message, args...))
+}
+
+//	'Require' returns errors for metas whose values are blank
+func (rule *RuleContext) Require(names ...string) error {
+	var errors TM_EC.Errors
+	for _, name := range names {
+		if value := rule.String(name); value == "" || isZeroTime(rule.Value(name)) {
+			errors.AddError(rule.Error(name, "ec.errors.blank", name))
+		}
+	}
+
+	if errors.HasError() {
+		return errors
+	}
+	return nil
+}
+
+func isZeroTime(value interface{}) bool {
+	if value := reflect.Indirect(reflect.ValueOf(value)); value.IsValid() {
+		if t, ok := value.Interface().(time.Time); ok {
+			return t.IsZero()
+		}
+	}
+	return false
+}
+
+//	'ValueEquals' condition that the meta's value equals to one of values
+func ValueEquals(name string, values ...string) RuleCondition {
+	return func(rule *RuleContext) bool {
+		value := rule.String(name)
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//	'ValuePresent' condition that the meta's value is not blank
+func ValuePresent(name string) RuleCondition {
+	return func(rule *RuleContext) bool {
+		return rule.String(name) != ""
+	}
+}
+
+//	'OnNewRecord' condition that the record is a new record
+func OnNewRecord(rule *RuleContext) bool {
+	return rule.NewRecord
+}
+
+//	'OnExistingRecord' condition that the record is an existing record
+func OnExistingRecord(rule *RuleContext) bool {
+	return !rule.NewRecord
+}
+
+//	'AllOf' condition that all of conditions are true
+func AllOf(conditions ...RuleCondition) RuleCondition {
+	return func(rule *RuleContext) bool {
+		for _, condition := range conditions {
+			if !condition(rule) {
+				return false
+			}
+		}
+		return true
+	}
+}
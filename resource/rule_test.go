@@ -0,0 +1,198 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+	"github.com/Sky-And-Hammer/validations"
+)
+
+type Shipment struct {
+	gorm.Model
+	Status    string
+	ShippedAt *time.Time
+	Note      string
+	Items     []ShipmentItem
+}
+
+type ShipmentItem struct {
+	gorm.Model
+	ShipmentID uint
+	Quantity   int
+}
+
+type Stock struct {
+	gorm.Model
+	Quantity int
+}
+
+func TestValidationRuleDSL(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Shipment{}, &ShipmentItem{}, &Stock{})
+	db.AutoMigrate(&Shipment{}, &ShipmentItem{}, &Stock{})
+
+	var (
+		res       = New(&Shipment{})
+		now       = time.Now()
+		shipped   = Shipment{Status: "shipped", ShippedAt: &now}
+		stock     = Stock{Quantity: 10}
+		published []bool
+	)
+	db.Save(&shipped)
+	db.Save(&stock)
+	id := res.GetPrimaryValue(&shipped)
+
+	res.AddRule(&Rule{
+		Name: "shipped_at",
+		If:   ValueEquals("Status", "shipped", "delivered"),
+		Validate: func(rule *RuleContext) error {
+			return rule.Require("ShippedAt")
+		},
+	})
+
+	res.AddRule(&Rule{
+		Name: "stock",
+		If:   OnNewRecord,
+		Validate: func(rule *RuleContext) error {
+			var current Stock
+			rule.Context.GetDB().First(&current, stock.ID)
+			if quantity, err := rule.Sum("Items.Quantity"); err != nil || quantity > float64(current.Quantity) {
+				return rule.Error("Items", "only %v items in stock", current.Quantity)
+			}
+			return nil
+		},
+	})
+
+	res.AddRule(&Rule{
+		Name:   "status",
+		Groups: []string{ValidationGroupUpdate},
+		Validate: func(rule *RuleContext) error {
+			return rule.Require("Status")
+		},
+	})
+
+	res.AddRule(&Rule{
+		Name:   "note",
+		Groups: []string{"publish"},
+		Validate: func(rule *RuleContext) error {
+			published = append(published, rule.InGroups(ValidationGroupUpdate))
+			return rule.Require("Note")
+		},
+	})
+
+	cases := []struct {
+		form    url.Values
+		groups  []string
+		errors  []string
+		publish bool
+	}{
+		{url.Values{"Status": {"shipped"}}, nil, []string{"ShippedAt"}, false},
+		{url.Values{"Status": {"pending"}, "Items[0].Quantity": {"3"}, "Items[1].Quantity": {"4"}}, nil, nil, false},
+		{url.Values{"Status": {"pending"}, "Items[0].Quantity": {"6"}, "Items[1].Quantity": {"5"}}, nil, []string{"Items"}, false},
+		//	ShippedAt of existing record is used, stock is not checked for existing records
+		{url.Values{"ID": {id}, "Items[0].Quantity": {"20"}}, nil, nil, false},
+		{url.Values{"ID": {id}, "Status": {""}}, nil, []string{"Status"}, false},
+		{url.Values{"ID": {id}}, []string{"publish"}, []string{"Note"}, true},
+		{url.Values{"ID": {id}, "Note": {"ready"}}, []string{"publish"}, nil, true},
+	}
+
+	for i, c := range cases {
+		var (
+			shipment Shipment
+			request  = &http.Request{Form: c.form}
+			context  = &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}
+		)
+
+		if len(c.groups) > 0 {
+			context = WithValidationGroups(context, c.groups...)
+		}
+
+		published = nil
+		metaValues, _ := ConvertFormToMetaValues(request, nil, "")
+		err := DecodeToResource(res, &shipment, metaValues, context).Start()
+
+		var columns []string
+		if errs, ok := err.(TM_EC.Errors); ok {
+			for _, e := range errs.GetErrors() {
+				var validationError validations.Error
+				if errors.As(e, &validationError) {
+					columns = append(columns, validationError.Column)
+				}
+			}
+		}
+
+		if len(columns) != len(c.errors) || (len(columns) > 0 && columns[0] != c.errors[0]) {
+			t.Errorf("#%v: expect errors on %v, but got %v", i+1, c.errors, err)
+		}
+
+		if c.publish && (len(published) != 1 || !published[0]) {
+			t.Errorf("#%v: publish rule should be validated with update group", i+1)
+		}
+	}
+}
+
+func TestRuleSum(t *testing.T) {
+	cases := []struct {
+		locale     string
+		quantities []string
+		sum        float64
+		err        bool
+	}{
+		{"en", []string{"1,000", "2.5", ""}, 1002.5, false},
+		{"de", []string{"1.000", "2,5"}, 1002.5, false},
+		{"en", []string{"1", "many"}, 0, true},
+	}
+
+	for _, c := range cases {
+		var (
+			form    = url.Values{}
+			request = &http.Request{Form: form, Header: http.Header{}}
+		)
+
+		request.Header.Set("Locale", c.locale)
+		for idx, quantity := range c.quantities {
+			form.Set(fmt.Sprintf("Items[%v].Quantity", idx), quantity)
+		}
+
+		metaValues, _ := ConvertFormToMetaValues(request, nil, "")
+		rule := &RuleContext{MetaValues: metaValues, Context: &TM_EC.Context{Request: request}}
+		if sum, err := rule.Sum("Items.Quantity"); sum != c.sum || (err != nil) != c.err {
+			t.Errorf("sum of %v in %v should be %v, but got %v, %v", c.quantities, c.locale, c.sum, sum, err)
+		}
+	}
+}
+
+func TestRuleNestedValues(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Shipment{}, &ShipmentItem{})
+	db.AutoMigrate(&Shipment{}, &ShipmentItem{})
+
+	shipment := Shipment{Status: "pending", Items: []ShipmentItem{{Quantity: 3}, {Quantity: 4}}}
+	db.Save(&shipment)
+
+	var (
+		found   Shipment
+		form    = url.Values{"Items[0].ID": {fmt.Sprint(shipment.Items[1].ID)}, "Items[1].Quantity": {"5"}}
+		request = &http.Request{Form: form}
+	)
+	db.First(&found, shipment.ID)
+
+	metaValues, _ := ConvertFormToMetaValues(request, nil, "")
+	rule := &RuleContext{Record: &found, MetaValues: metaValues, Context: &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}}
+	if values := rule.Values("Items.Quantity"); len(values) != 2 || values[0] != 4 || valueString(values[1]) != "5" {
+		t.Errorf("quantity not submitted should be got from existing item, but got %v", values)
+	}
+
+	if len(found.Items) != 0 {
+		t.Errorf("items of the record shouldn't be loaded, but got %v", found.Items)
+	}
+}
@@ -3,9 +3,13 @@ package resource
 import (
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/url"
+	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Sky-And-Hammer/TM_EC"
@@ -36,6 +40,15 @@ func converMapToMetaValues(values map[string]interface{}, metaors []Metaor) (*Me
 				}
 			}
 		case []interface{}:
+			if len(result) == 0 {
+				//	keep empty array, so has many children could be cleared
+				metaValue = &MetaValue{
+					Name:  key,
+					Value: result,
+					Meta:  metaor,
+				}
+			}
+
 			for idx, r := range result {
 				if mr, ok := r.(map[string]interface{}); ok {
 					if children, err := converMapToMetaValues(mr, childMeta); err == nil {
@@ -50,7 +63,7 @@ func converMapToMetaValues(values map[string]interface{}, metaors []Metaor) (*Me
 				} else {
 					metaValue := &MetaValue{
 						Name:  key,
-						Value: result,
+						Value: restoreJSONNumbers(result, metaor),
 						Meta:  metaor,
 					}
 					metaValues.Values = append(metaValues.Values, metaValue)
@@ -60,7 +73,8 @@ func converMapToMetaValues(values map[string]interface{}, metaors []Metaor) (*Me
 		default:
 			metaValue = &MetaValue{
 				Name:  key,
-				Value: value,
+				Value: restoreJSONNumbers(value, metaor),
+				Null:  value == nil,
 				Meta:  metaor,
 			}
 		}
@@ -73,7 +87,31 @@ func converMapToMetaValues(values map[string]interface{}, metaors []Metaor) (*Me
 	return metaValues, nil
 }
 
+//	'restoreJSONNumbers' convert json.Number back to float64 unless the meta is decimal, numbers are decoded as json.Number so decimals won't be rounded by float, but other metas get float64 as usual
+func restoreJSONNumbers(value interface{}, metaor Metaor) interface{} {
+	if decimalMeta, ok := metaor.(interface {
+		isDecimalField() bool
+	}); ok && decimalMeta.isDecimalField() {
+		return value
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		if number, err := v.Float64(); err == nil {
+			return number
+		}
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, elem := range v {
+			values[i] = restoreJSONNumbers(elem, metaor)
+		}
+		return values
+	}
+	return value
+}
+
 //	'ConvertJSONToMetaValues' convert json to meta values
+//	Numbers of decimal metas are kept as json.Number, so they won't be rounded by float, numbers of other metas are float64
 func ConvertJSONToMetaValues(reader io.Reader, metaors []Metaor) (*MetaValues, error) {
 	var (
 		err     error
@@ -81,6 +119,8 @@ func ConvertJSONToMetaValues(reader io.Reader, metaors []Metaor) (*MetaValues, e
 		decoder = json.NewDecoder(reader)
 	)
 
+	decoder.UseNumber()
+
 	if err = decoder.Decode(&values); err == nil {
 		return converMapToMetaValues(values, metaors)
 	}
@@ -94,6 +134,15 @@ var (
 
 //	'ConvertFormToMetaValues' convert form to meta values
 func ConvertFormToMetaValues(request *http.Request, metaors []Metaor, prefix string) (*MetaValues, error) {
+	var files map[string][]*multipart.FileHeader
+	if request.MultipartForm != nil {
+		files = request.MultipartForm.File
+	}
+	return convertValuesToMetaValues(request.Form, files, nil, metaors, prefix)
+}
+
+//	'convertValuesToMetaValues' convert form values to meta values, keys without values are absent, keys in nulls are null
+func convertValuesToMetaValues(values url.Values, files map[string][]*multipart.FileHeader, nulls map[string]bool, metaors []Metaor, prefix string) (*MetaValues, error) {
 	metaValues := &MetaValues{}
 	metaorsMap := map[string]Metaor{}
 	convertedNextLevel := map[string]bool{}
@@ -105,13 +154,15 @@ func ConvertFormToMetaValues(request *http.Request, metaors []Metaor, prefix str
 	newMetaValue := func(key string, value interface{}) {
 		if strings.HasPrefix(key, prefix) {
 			var metaValue *MetaValue
-			key = strings.TrimPrefix(key, prefix)
+			fullKey, key := key, strings.TrimPrefix(key, prefix)
 			if matches := isCurrentLevel.FindStringSubmatch(key); len(matches) > 0 {
 				name := matches[0]
 				metaValue = &MetaValue{
-					Name:  name,
-					Value: value,
-					Meta:  metaorsMap[name],
+					Name:   name,
+					Value:  value,
+					Null:   nulls[fullKey],
+					Absent: reflect.ValueOf(value).Len() == 0,
+					Meta:   metaorsMap[name],
 				}
 			} else if matches = isNextLevel.FindStringSubmatch(key); len(matches) > 0 {
 				name := matches[1]
@@ -123,9 +174,12 @@ func ConvertFormToMetaValues(request *http.Request, metaors []Metaor, prefix str
 						metaors = metaor.GetMetas()
 					}
 
-					if children, err := ConvertFormToMetaValues(request, metaors, prefix+name+"."); err == nil {
+					if children, err := convertValuesToMetaValues(values, files, nulls, metaors, prefix+name+"."); err == nil {
 						nestedName := prefix + matches[2]
-						if _, ok := nestedStructIndex[nestedName]; ok {
+						if index, err := strconv.Atoi(strings.Trim(matches[3], "[]")); err == nil {
+							//	index of has many children submitted like "Addresses[10].Address1"
+							nestedStructIndex[nestedName] = index
+						} else if _, ok := nestedStructIndex[nestedName]; ok {
 							nestedStructIndex[nestedName] += 1
 						} else {
 							nestedStructIndex[nestedName] = 0
@@ -148,44 +202,69 @@ func ConvertFormToMetaValues(request *http.Request, metaors []Metaor, prefix str
 	}
 
 	var sortedFormKeys []string
-	for key := range request.Form {
+	for key := range values {
 		sortedFormKeys = append(sortedFormKeys, key)
 	}
 
-	sort.Strings(sortedFormKeys)
+	sortFormKeys(sortedFormKeys)
 	for _, key := range sortedFormKeys {
-		newMetaValue(key, request.Form[key])
+		newMetaValue(key, values[key])
 	}
 
-	if request.MultipartForm != nil {
+	if files != nil {
 		sortedFormKeys = []string{}
-		for key := range request.MultipartForm.File {
+		for key := range files {
 			sortedFormKeys = append(sortedFormKeys, key)
 		}
 
-		sort.Strings(sortedFormKeys)
+		sortFormKeys(sortedFormKeys)
 		for _, key := range sortedFormKeys {
-			newMetaValue(key, request.MultipartForm.File[key])
+			newMetaValue(key, files[key])
 		}
 	}
 
 	return metaValues, nil
 }
 
-//	'Decode' decode context to result according to resource definition
+var formIndexRegexp = regexp.MustCompile(`\[(\d+)\]`)
+
+//	'sortFormKeys' sort form keys with indexes compared as numbers, so "Addresses[2]" is sorted before "Addresses[10]"
+func sortFormKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		for {
+			indexA, indexB := formIndexRegexp.FindStringSubmatchIndex(a), formIndexRegexp.FindStringSubmatchIndex(b)
+			if indexA == nil || indexB == nil || a[:indexA[0]] != b[:indexB[0]] {
+				return a < b
+			}
+
+			numberA, _ := strconv.Atoi(a[indexA[2]:indexA[3]])
+			numberB, _ := strconv.Atoi(b[indexB[2]:indexB[3]])
+			if numberA != numberB {
+				return numberA < numberB
+			}
+			a, b = a[indexA[1]:], b[indexB[1]:]
+		}
+	})
+}
+
+//	'Decode' decode context to result according to resource definition, request is decoded by decoder of its content type, refer 'RegisterDecoder'
+//	Meta values are kept in the context of the request for save, delete callbacks of result, use 'WithMetaValues' to pass them if records are decoded with 'DecodeToResource'
 func Decode(context *TM_EC.Context, result interface{}, res Resourcer) error {
 	var errors TM_EC.Errors
 	var err error
 	var metaValues *MetaValues
 	metaors := res.GetMetas([]string{})
-	if strings.Contains(context.Request.Header.Get("Content-Type"), "json") {
-		metaValues, err = ConvertJSONToMetaValues(context.Request.Body, metaors)
+	metaValues, err = GetDecoder(context.Request)(context.Request, metaors)
+	if context.Request.Body != nil {
 		context.Request.Body.Close()
-	} else {
-		metaValues, err = ConvertFormToMetaValues(context.Request, metaors, "ECResource.")
 	}
 
 	errors.AddError(err)
 	errors.AddError(DecodeToResource(res, result, metaValues, context).Start())
-	return errors
+	context.SetDB(WithMetaValues(context, result, metaValues).GetDB())
+	if errors.HasError() {
+		return errors
+	}
+	return nil
 }
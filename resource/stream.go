@@ -0,0 +1,276 @@
+package resource
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"unicode"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+)
+
+//	'DefaultImportBatchSize' batch size of 'ImportJSONStream' if it is not configured
+var DefaultImportBatchSize = 100
+
+//	'DefaultImportMaxFailures' max failures kept in the report of 'ImportJSONStream' if it is not configured
+var DefaultImportMaxFailures = 100
+
+//	'JSONStreamDecoder' decode records of a JSON array or NDJSON (newline delimited JSON) to meta values one by one
+//	It reads the input token by token, so memory is bounded by the size of a record instead of the whole input
+type JSONStreamDecoder struct {
+	metaors []Metaor
+	reader  *bufio.Reader
+	decoder *json.Decoder
+	array   bool
+	index   int
+	err     error
+}
+
+//	'NewJSONStreamDecoder' new stream decoder, input starts with '[' is decoded as a JSON array, otherwise as NDJSON
+func NewJSONStreamDecoder(reader io.Reader, metaors []Metaor) *JSONStreamDecoder {
+	return &JSONStreamDecoder{metaors: metaors, reader: bufio.NewReader(reader), index: -1}
+}
+
+func (stream *JSONStreamDecoder) start() error {
+	for {
+		r, _, err := stream.reader.ReadRune()
+		if err != nil {
+			return err
+		}
+
+		if !unicode.IsSpace(r) && r != '\ufeff' {
+			stream.reader.UnreadRune()
+			stream.array = r == '['
+			break
+		}
+	}
+
+	stream.decoder = json.NewDecoder(stream.reader)
+	//	keep numbers as it is, so decimals won't be rounded by float
+	stream.decoder.UseNumber()
+
+	if stream.array {
+		_, err := stream.decoder.Token()
+		return err
+	}
+	return nil
+}
+
+//	'Index' index of the record returned by last 'Next', starts from 0
+func (stream *JSONStreamDecoder) Index() int {
+	return stream.index
+}
+
+//	'Next' meta values of next record, it returns io.EOF after the last record
+//	Records that are not JSON objects are reported with an error, and the stream could be continued, other errors like invalid JSON will be returned for all following calls
+func (stream *JSONStreamDecoder) Next() (*MetaValues, error) {
+	if stream.err != nil {
+		return nil, stream.err
+	}
+
+	if stream.decoder == nil {
+		if stream.err = stream.start(); stream.err != nil {
+			return nil, stream.err
+		}
+	}
+
+	if !stream.decoder.More() {
+		if stream.array {
+			if _, err := stream.decoder.Token(); err != nil {
+				stream.err = err
+				return nil, err
+			}
+		}
+		stream.err = io.EOF
+		return nil, io.EOF
+	}
+
+	var value interface{}
+	if err := stream.decoder.Decode(&value); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		stream.err = err
+		return nil, err
+	}
+
+	stream.index++
+	if values, ok := value.(map[string]interface{}); ok {
+		return converMapToMetaValues(values, stream.metaors)
+	}
+	return nil, i18n.NewError("ec.errors.invalid_record", stream.index)
+}
+
+//	'ImportConfig' config of 'ImportJSONStream'
+//	'BatchSize' records saved in a transaction, 'DefaultImportBatchSize' is used if it is not positive
+//	'MaxFailures' failures kept in the report, 'DefaultImportMaxFailures' is used if it is not positive, more failures are only counted
+//	'Result' is called with result of every record after its batch finished, results of saved records and failures over 'MaxFailures' are not kept in the report, so use it to get them
+//	'Metas' metas used to decode records, 'DefaultMetas' of the resource is used if it is empty
+type ImportConfig struct {
+	BatchSize   int
+	MaxFailures int
+	Result      func(*ImportResult)
+	Metas       []Metaor
+}
+
+//	'ImportResult' result of a record, 'Index' is its index in the input, 'Error' is nil if it is saved
+type ImportResult struct {
+	Index        int
+	PrimaryValue string
+	Error        error
+}
+
+//	'ImportReport' report of 'ImportJSONStream', it only keeps results of first failed records up to 'MaxFailures'
+type ImportReport struct {
+	Total    int
+	Saved    int
+	Failed   int
+	Failures []*ImportResult
+}
+
+func (report *ImportReport) add(result *ImportResult, config *ImportConfig) {
+	report.Total++
+	if result.Error == nil {
+		report.Saved++
+	} else {
+		report.Failed++
+		if len(report.Failures) < config.MaxFailures {
+			report.Failures = append(report.Failures, result)
+		}
+	}
+
+	if config.Result != nil {
+		config.Result(result)
+	}
+}
+
+type importItem struct {
+	result     *ImportResult
+	metaValues *MetaValues
+	saveFailed bool
+}
+
+//	'ImportJSONStream' decode records of a JSON array or NDJSON with 'JSONStreamDecoder', and save them with 'DecodeToResource' and 'CallSave' in batches
+//	Every batch is saved in a transaction, if any record of it failed to save, the batch is rollbacked and its records are saved one by one, so failed records won't affect others
+//	If the context is already in a transaction, records are saved in it and not retried, and after commit callbacks are run when it is committed with 'CommitTransaction', if it is begun with 'BeginTransaction'
+//	Invalid records are reported in the returned report, error is only returned if the input couldn't be read
+//		report, err := resource.ImportJSONStream(context, context.Request.Body, productRes, &resource.ImportConfig{BatchSize: 500})
+func ImportJSONStream(context *TM_EC.Context, reader io.Reader, res Resourcer, config *ImportConfig) (*ImportReport, error) {
+	config = withImportDefaults(config)
+	if len(config.Metas) == 0 {
+		config.Metas = DefaultMetas(res)
+	}
+
+	var (
+		report = &ImportReport{}
+		stream = NewJSONStreamDecoder(reader, config.Metas)
+		batch  = make([]*importItem, 0, config.BatchSize)
+	)
+
+	for {
+		metaValues, err := stream.Next()
+		if err == io.EOF {
+			break
+		} else if stream.err != nil {
+			importBatch(context, res, batch, report, config)
+			return report, err
+		}
+
+		item := &importItem{result: &ImportResult{Index: stream.Index(), Error: err}, metaValues: metaValues}
+		if err != nil {
+			report.add(item.result, config)
+			continue
+		}
+
+		if batch = append(batch, item); len(batch) == config.BatchSize {
+			importBatch(context, res, batch, report, config)
+			batch = batch[:0]
+		}
+	}
+
+	importBatch(context, res, batch, report, config)
+	return report, nil
+}
+
+func withImportDefaults(config *ImportConfig) *ImportConfig {
+	var result ImportConfig
+	if config != nil {
+		result = *config
+	}
+
+	if result.BatchSize <= 0 {
+		result.BatchSize = DefaultImportBatchSize
+	}
+
+	if result.MaxFailures <= 0 {
+		result.MaxFailures = DefaultImportMaxFailures
+	}
+	return &result
+}
+
+func importBatch(context *TM_EC.Context, res Resourcer, batch []*importItem, report *ImportReport, config *ImportConfig) {
+	if len(batch) == 0 {
+		return
+	}
+
+	nested := inTransaction(context)
+	err := Transaction(context, func(context *TM_EC.Context) error {
+		for _, item := range batch {
+			if err := importRecord(context, res, item); err != nil && item.saveFailed && !nested {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		//	records saved in the rollbacked batch are not saved actually, save them one by one
+		for _, item := range batch {
+			item.result.PrimaryValue, item.result.Error, item.saveFailed = "", nil, false
+			Transaction(context, func(context *TM_EC.Context) error {
+				return importRecord(context, res, item)
+			})
+		}
+	}
+
+	for _, item := range batch {
+		report.add(item.result, config)
+	}
+}
+
+func importRecord(context *TM_EC.Context, res Resourcer, item *importItem) error {
+	var (
+		record        = res.NewStruct()
+		recordContext = context.Clone()
+	)
+
+	recordContext.Errors = TM_EC.Errors{}
+	if err := DecodeToResource(res, record, item.metaValues, recordContext).Start(); err != nil {
+		item.result.Error = err
+		return err
+	}
+
+	if recordContext.HasError() {
+		item.result.Error = recordContext.Errors
+		return item.result.Error
+	}
+
+	if err := res.CallSave(record, WithMetaValues(recordContext, record, item.metaValues)); err != nil {
+		item.result.Error, item.saveFailed = err, true
+		return err
+	}
+
+	item.result.PrimaryValue = res.GetResource().GetPrimaryValue(record)
+	return nil
+}
+
+func inTransaction(context *TM_EC.Context) bool {
+	db := context.GetDB()
+	if getAfterCommitCallbacks(db) != nil {
+		return true
+	}
+	_, ok := db.CommonDB().(*sql.Tx)
+	return ok
+}
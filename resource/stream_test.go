@@ -0,0 +1,142 @@
+package resource
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+)
+
+func TestJSONStreamDecoder(t *testing.T) {
+	res, _ := newUserResource()
+
+	cases := []struct {
+		input string
+		names []string
+		err   bool
+	}{
+		{`[{"Name": "a"}, {"Name": "b"}]`, []string{"a", "b"}, false},
+		{"\ufeff {\"Name\": \"a\"}\n{\"Name\": \"b\"}\n", []string{"a", "b"}, false},
+		{`[]`, nil, false},
+		{``, nil, false},
+		{`[{"Name": "a"}, {"Name": `, []string{"a"}, true},
+	}
+
+	for _, c := range cases {
+		var (
+			names  []string
+			err    error
+			stream = NewJSONStreamDecoder(strings.NewReader(c.input), res.GetMetas(nil))
+		)
+
+		for {
+			var metaValues *MetaValues
+			if metaValues, err = stream.Next(); err != nil {
+				break
+			}
+			names = append(names, metaValues.Get("Name").Value.(string))
+		}
+
+		if !reflect.DeepEqual(names, c.names) || (err != io.EOF) != c.err {
+			t.Errorf("decode %q: expect %v, but got %v, %v", c.input, c.names, names, err)
+		}
+	}
+}
+
+func TestImportJSONStream(t *testing.T) {
+	res, context := newUserResource()
+	res.AddValidator(func(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		if name := metaValues.Get("Name"); name == nil || name.Value == "" {
+			return i18n.NewError("ec.errors.blank", "Name")
+		}
+		return nil
+	})
+	res.AddCallback(CallbackBeforeSave, "fail", func(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		if record.(*User).Name == "fail" {
+			return errors.New("failed to save")
+		}
+		return nil
+	})
+
+	cases := []struct {
+		input       string
+		maxFailures int
+		saved       []string
+		failures    []int
+		failed      int
+		err         bool
+	}{
+		{`[{"Name": "a"}, {"Name": ""}, 1, {"Name": "fail"}, {"Name": "b"}, {"Name": "c", "Addresses": [{"Address1": "A"}]}]`, 0, []string{"a", "b", "c"}, []int{1, 2, 3}, 3, false},
+		{`[{"Name": "a"}, {"Name": ""}, 1, {"Name": "fail"}, {"Name": "b"}]`, 2, []string{"a", "b"}, []int{1, 2}, 3, false},
+		{"{\"Name\": \"a\"}\n{\"Name\": \"fail\"}\n{\"Name\": \"b\"}\n", 0, []string{"a", "b"}, []int{1}, 1, false},
+		{`[{"Name": "a"}, {"Name": "b"}, {"Name": `, 0, []string{"a", "b"}, nil, 0, true},
+	}
+
+	for _, c := range cases {
+		context.GetDB().Unscoped().Delete(&User{})
+
+		var (
+			results []*ImportResult
+			users   []User
+		)
+
+		report, err := ImportJSONStream(context, strings.NewReader(c.input), res, &ImportConfig{BatchSize: 2, MaxFailures: c.maxFailures, Result: func(result *ImportResult) {
+			results = append(results, result)
+		}})
+
+		if (err != nil) != c.err {
+			t.Errorf("import %v: expect error %v, but got %v", c.input, c.err, err)
+		}
+
+		var failures []int
+		for _, result := range report.Failures {
+			failures = append(failures, result.Index)
+		}
+
+		if report.Total != len(c.saved)+c.failed || report.Saved != len(c.saved) || report.Failed != c.failed || !reflect.DeepEqual(failures, c.failures) || len(results) != report.Total {
+			t.Errorf("import %v: expect saved %v, failures %v, but got %+v", c.input, c.saved, c.failures, report)
+		}
+
+		context.GetDB().Order("id").Find(&users)
+		var names []string
+		for _, user := range users {
+			names = append(names, user.Name)
+		}
+
+		if !reflect.DeepEqual(names, c.saved) {
+			t.Errorf("import %v: expect saved users %v, but got %v", c.input, c.saved, names)
+		}
+
+		for _, result := range results {
+			if result.Error == nil && result.PrimaryValue == "" {
+				t.Errorf("import %v: saved record %v should have primary value", c.input, result.Index)
+			}
+		}
+	}
+}
+
+func TestImportJSONStreamWithBaseResource(t *testing.T) {
+	_, context := newUserResource()
+	context.GetDB().Unscoped().Delete(&User{})
+
+	res := New(&User{})
+	report, err := ImportJSONStream(context, strings.NewReader(`[{"Name": "a"}, {"Name": "b"}]`), res, nil)
+	if err != nil || report.Saved != 2 {
+		t.Errorf("should import records with default metas of base resource, but got %+v, %v", report, err)
+	}
+
+	report, err = ImportJSONStream(context, strings.NewReader(`[{"Name": "c"}]`), res, &ImportConfig{Metas: []Metaor{newTestMeta(&Meta{Name: "Name", Resource: res}, nil)}})
+	if err != nil || report.Saved != 1 {
+		t.Errorf("should import records with metas of the config, but got %+v, %v", report, err)
+	}
+
+	var count int
+	context.GetDB().Model(&User{}).Where("name IN (?)", []string{"a", "b", "c"}).Count(&count)
+	if count != 3 {
+		t.Errorf("imported users should be saved, but got %v", count)
+	}
+}
@@ -0,0 +1,290 @@
+package resource
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+	"github.com/Sky-And-Hammer/validations"
+)
+
+var (
+	validationFormats = map[string]*regexp.Regexp{
+		"email":        regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`),
+		"url":          regexp.MustCompile(`^https?://[^\s/$.?#].[^\s]*$`),
+		"phone":        regexp.MustCompile(`^\+?[0-9][0-9\- ()]{5,}[0-9]$`),
+		"alpha":        regexp.MustCompile(`^[a-zA-Z]+$`),
+		"alphanumeric": regexp.MustCompile(`^[a-zA-Z0-9]+$`),
+		"numeric":      regexp.MustCompile(`^[-+]?[0-9]+(\.[0-9]+)?$`),
+	}
+	validationFormatsMutex sync.RWMutex
+)
+
+//	'RegisterValidationFormat' register a format that could be used with 'Format' rule, e.g. `ec:"format:zipcode"`, registered format with same name will be replaced
+func RegisterValidationFormat(name string, format *regexp.Regexp) {
+	validationFormatsMutex.Lock()
+	validationFormats[name] = format
+	validationFormatsMutex.Unlock()
+}
+
+func getValidationFormat(name string) *regexp.Regexp {
+	validationFormatsMutex.RLock()
+	defer validationFormatsMutex.RUnlock()
+	return validationFormats[name]
+}
+
+//	'FieldError' validation error of a meta, it keeps the original error like 'i18n.Error', so it is localized when rendering with 'i18n.Localize' instead of when it is created
+//	It wraps 'validations.Error' that validation errors were, get it with 'errors.As' to handle them as before
+//		var validationError validations.Error
+//		if errors.As(err, &validationError) { ... }
+type FieldError struct {
+	Resource interface{}
+	Column   string
+	Err      error
+}
+
+//	'NewFieldError' new validation error of the meta
+//		context.AddError(resource.NewFieldError(record, "Email", i18n.NewError("ec.errors.taken", "Email")))
+func NewFieldError(record interface{}, column string, err error) FieldError {
+	return FieldError{Resource: record, Column: column, Err: err}
+}
+
+//	'Label' label of the error, it is the meta's name
+func (err FieldError) Label() string {
+	return err.Column
+}
+
+//	'Error' message of the default locale
+func (err FieldError) Error() string {
+	return err.Err.Error()
+}
+
+//	'Localize' message of the locale
+func (err FieldError) Localize(locale string) string {
+	return i18n.Localize(err.Err, locale)
+}
+
+//	'Unwrap' returns the error as 'validations.Error', its message is of the default locale
+func (err FieldError) Unwrap() error {
+	return validations.NewError(err.Resource, err.Column, err.Error())
+}
+
+//	'Limit' returns pointer of the number, it is used to set 'Min', 'Max' of metas, e.g. &resource.Meta{Name: "Name", Max: resource.Limit(255)}
+func Limit(value float64) *float64 {
+	return &value
+}
+
+//	'parseValidationTag' fill validation rules from field's tag like `ec:"required;min:2;max:255;format:email;pattern:^[a-z]+$;oneof:a,b;unique"`, rules configured on meta take precedence
+func (meta *Meta) parseValidationTag() {
+	if meta.FieldStruct == nil {
+		return
+	}
+
+	tag := meta.FieldStruct.Tag.Get("ec")
+	if tag == "" {
+		return
+	}
+
+	for key, value := range utils.ParseTagOption(tag) {
+		switch key {
+		case "REQUIRED":
+			meta.Required = true
+		case "UNIQUE":
+			meta.Unique = true
+		case "MIN", "MAX":
+			number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				utils.ExitWithMsg("Invalid %v rule %v of meta %v", strings.ToLower(key), value, meta.Name)
+				continue
+			}
+
+			if key == "MIN" && meta.Min == nil {
+				meta.Min = &number
+			} else if key == "MAX" && meta.Max == nil {
+				meta.Max = &number
+			}
+		case "FORMAT":
+			if meta.Format == "" {
+				meta.Format = value
+			}
+		case "PATTERN":
+			if meta.Pattern == "" {
+				meta.Pattern = value
+			}
+		case "ONEOF":
+			if len(meta.OneOf) == 0 {
+				meta.OneOf = strings.Split(value, ",")
+			}
+		}
+	}
+}
+
+func (meta *Meta) hasValidationRules() bool {
+	return meta.Required || meta.Unique || meta.Min != nil || meta.Max != nil || meta.Format != "" || meta.Pattern != "" || len(meta.OneOf) > 0
+}
+
+//	'registerValidator' compile validation rules of the meta to a validator of its resource, the validator is registered once even if the meta is initialized again
+func (meta *Meta) registerValidator() {
+	meta.parseValidationTag()
+	if meta.validatorRegistered || meta.Resource == nil || !meta.hasValidationRules() {
+		return
+	}
+
+	var pattern *regexp.Regexp
+	if meta.Pattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(meta.Pattern); err != nil {
+			utils.ExitWithMsg("Invalid pattern %v of meta %v: %v", meta.Pattern, meta.Name, err)
+			return
+		}
+	}
+
+	if meta.Format != "" && getValidationFormat(meta.Format) == nil {
+		utils.ExitWithMsg("Unknown format %v of meta %v", meta.Format, meta.Name)
+		return
+	}
+
+	meta.validatorRegistered = true
+	meta.Resource.GetResource().AddValidator(func(record interface{}, metaValues *MetaValues, context *TM_EC.Context) error {
+		return meta.validate(record, metaValues, pattern, context)
+	})
+}
+
+//	'validate' validate submitted value of the meta, blank values only be checked by 'Required', and missing values are treated as blank for new records
+func (meta *Meta) validate(record interface{}, metaValues *MetaValues, pattern *regexp.Regexp, context *TM_EC.Context) error {
+	var (
+		metaValue *MetaValue
+		newRecord = context.GetDB().NewScope(record).PrimaryKeyZero()
+	)
+
+	if metaValues != nil {
+		metaValue = metaValues.Get(meta.Name)
+	}
+
+	if metaValue == nil && !newRecord {
+		return nil
+	}
+
+	var values []string
+	if metaValue != nil {
+		switch value := metaValue.Value.(type) {
+		case []string:
+			values = utils.ToArray(value)
+		case []interface{}:
+			values = utils.ToArray(value)
+		case nil:
+		default:
+			if str := strings.TrimSpace(fmt.Sprint(value)); str != "" {
+				values = []string{str}
+			}
+		}
+
+		if metaValue.MetaValues != nil && len(metaValue.MetaValues.Values) > 0 {
+			//	nested values are validated by validators of the nested resource
+			return nil
+		}
+	}
+
+	if len(values) == 0 {
+		if meta.Required {
+			return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.blank", meta.Name))
+		}
+		return nil
+	}
+
+	isNumber := false
+	switch meta.fieldKind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		isNumber = true
+	default:
+		if fieldType := meta.indirectFieldType(); fieldType != nil && isDecimalType(fieldType) {
+			isNumber = true
+		}
+	}
+
+	for _, value := range values {
+		if meta.Min != nil || meta.Max != nil {
+			if isNumber {
+				//	parse the value like setters, so localized numbers like "1,000" are checked as well
+				number, err := utils.ToDecimal(value, context)
+				if err != nil {
+					return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.not_a_number", meta.Name))
+				}
+
+				if meta.Min != nil && number.Cmp(decimal.NewFromFloat(*meta.Min)) < 0 {
+					return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.greater_than_or_equal_to", meta.Name, *meta.Min))
+				}
+
+				if meta.Max != nil && number.Cmp(decimal.NewFromFloat(*meta.Max)) > 0 {
+					return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.less_than_or_equal_to", meta.Name, *meta.Max))
+				}
+			} else {
+				length := float64(utf8.RuneCountInString(value))
+				if meta.Min != nil && length < *meta.Min {
+					return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.too_short", meta.Name, *meta.Min))
+				}
+
+				if meta.Max != nil && length > *meta.Max {
+					return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.too_long", meta.Name, *meta.Max))
+				}
+			}
+		}
+
+		if meta.Format != "" && !getValidationFormat(meta.Format).MatchString(value) {
+			return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.invalid_format", meta.Name, meta.Format))
+		}
+
+		if pattern != nil && !pattern.MatchString(value) {
+			return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.invalid", meta.Name))
+		}
+
+		if len(meta.OneOf) > 0 {
+			var included bool
+			for _, option := range meta.OneOf {
+				if option == value {
+					included = true
+					break
+				}
+			}
+
+			if !included {
+				return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.inclusion", meta.Name))
+			}
+		}
+	}
+
+	if meta.Unique && meta.FieldStruct != nil && !strings.Contains(meta.FieldName, ".") {
+		var (
+			res   = meta.Resource.GetResource()
+			scope = context.GetDB().NewScope(res.Value)
+			db    = context.GetDB().Model(res.NewStruct()).Where(fmt.Sprintf("%v IN (?)", scope.Quote(meta.FieldStruct.DBName)), values)
+			count int
+		)
+
+		if !newRecord {
+			//	exclude current record
+			if primaryQuerySQL, primaryParams, err := res.ToPrimaryQueryParams(res.GetPrimaryValue(record), context); err == nil {
+				db = db.Where("NOT ("+primaryQuerySQL+")", primaryParams...)
+			}
+		}
+
+		if err := db.Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count > 0 {
+			return NewFieldError(record, meta.Name, i18n.NewError("ec.errors.taken", meta.Name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package resource
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/i18n"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+	"github.com/Sky-And-Hammer/validations"
+)
+
+type Account struct {
+	gorm.Model
+	Email string `ec:"required;format:email;unique"`
+	Name  string
+	Age   int
+	Role  string
+	Code  string `ec:"pattern:^[A-Z]{2}:[0-9]+$"`
+}
+
+func TestValidationRules(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Account{})
+	db.AutoMigrate(&Account{})
+
+	var (
+		res   = New(&Account{})
+		metas = []*Meta{
+			{Name: "Email", Resource: res},
+			{Name: "Name", Resource: res, Min: Limit(2), Max: Limit(5)},
+			{Name: "Age", Resource: res, Min: Limit(18), Max: Limit(150)},
+			{Name: "Role", Resource: res, OneOf: []string{"admin", "editor"}},
+			{Name: "Code", Resource: res},
+		}
+		metaors []Metaor
+	)
+
+	for _, meta := range metas {
+		meta.PerInitialize()
+		meta.Initialize()
+		meta.Initialize()
+		metaors = append(metaors, meta)
+	}
+
+	if len(res.Validatiors) != 5 {
+		t.Fatalf("each meta with rules should register one validator, but got %v", len(res.Validatiors))
+	}
+
+	existing := Account{Email: "taken@example.com"}
+	db.Save(&existing)
+
+	cases := []struct {
+		id     uint
+		form   url.Values
+		errors []string
+	}{
+		{0, url.Values{"Email": {"jinzhu@example.com"}, "Name": {"jin"}, "Age": {"20"}, "Role": {"admin"}, "Code": {"CN:100"}}, nil},
+		{0, url.Values{"Name": {"jin"}}, []string{"Email"}},
+		{0, url.Values{"Email": {"invalid"}, "Name": {"j"}, "Age": {"16"}, "Role": {"guest"}, "Code": {"100"}}, []string{"Email", "Name", "Age", "Role", "Code"}},
+		{0, url.Values{"Email": {"taken@example.com"}, "Name": {"jinzhu"}}, []string{"Email", "Name"}},
+		//	numbers are parsed like setters
+		{0, url.Values{"Email": {"age@example.com"}, "Age": {"1,000"}}, []string{"Age"}},
+		{0, url.Values{"Email": {"age@example.com"}, "Age": {"twenty"}}, []string{"Age"}},
+		//	current record is excluded from unique check, missing values are not validated for existing records
+		{existing.ID, url.Values{"Email": {"taken@example.com"}}, nil},
+		{existing.ID, url.Values{"Email": {""}}, []string{"Email"}},
+	}
+
+	for i, c := range cases {
+		var (
+			account Account
+			request = &http.Request{Form: c.form}
+			context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Request: request}
+		)
+
+		if c.id != 0 {
+			c.form.Set("ID", res.GetPrimaryValue(&existing))
+		}
+
+		metaValues, _ := ConvertFormToMetaValues(request, metaors, "")
+		err := DecodeToResource(res, &account, metaValues, context).Start()
+
+		var columns []string
+		if errs, ok := err.(TM_EC.Errors); ok {
+			for _, e := range errs.GetErrors() {
+				var validationError validations.Error
+				if errors.As(e, &validationError) {
+					columns = append(columns, validationError.Column)
+				}
+			}
+		}
+
+		if len(columns) != len(c.errors) {
+			t.Errorf("#%v: expect errors on %v, but got %v", i+1, c.errors, err)
+			continue
+		}
+
+		for idx, column := range columns {
+			if column != c.errors[idx] {
+				t.Errorf("#%v: expect errors on %v, but got %v", i+1, c.errors, columns)
+				break
+			}
+		}
+	}
+}
+
+func TestFieldErrorLocalize(t *testing.T) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Account{})
+	db.AutoMigrate(&Account{})
+
+	var (
+		res     = New(&Account{})
+		email   = &Meta{Name: "Email", Resource: res}
+		account Account
+		context = &TM_EC.Context{Config: &TM_EC.Config{DB: db}, Locale: "zh-CN"}
+	)
+	email.PerInitialize()
+	email.Initialize()
+
+	err := DecodeToResource(res, &account, &MetaValues{Values: []*MetaValue{{Name: "Email", Value: ""}}}, context).Start()
+
+	cases := []struct {
+		locale   string
+		messages []string
+	}{
+		{"en", []string{"Email can't be blank"}},
+		{"zh-CN", []string{"Email不能为空"}},
+	}
+
+	for _, c := range cases {
+		if messages := i18n.LocalizeErrors(err, c.locale); !reflect.DeepEqual(messages, c.messages) {
+			t.Errorf("errors should be localized to %v when rendering, but got %v", c.locale, messages)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//	'Schedule' a parsed cron expression
+type Schedule struct {
+	Expression string
+	minute     uint64
+	hour       uint64
+	dom        uint64
+	month      uint64
+	dow        uint64
+	anyDom     bool
+	anyDow     bool
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	domField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	dowField = cronField{min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+
+	descriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+)
+
+//	'ParseCron' parse standard 5 fields cron expression "minute hour day-of-month month day-of-week"
+//	Supports "*", lists "1,15", ranges "1-5", steps "*/15" or "1-30/5", month and weekday names, and descriptors like "@daily"
+func ParseCron(expression string) (*Schedule, error) {
+	spec := strings.TrimSpace(expression)
+	if descriptor, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("scheduler: cron expression %q should have 5 fields", expression)
+	}
+
+	var (
+		schedule = &Schedule{Expression: expression}
+		err      error
+	)
+
+	if schedule.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+
+	if schedule.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if schedule.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if schedule.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+
+	if schedule.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+
+	//	7 is sunday too
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+
+	schedule.anyDom = fields[2] == "*" || fields[2] == "?"
+	schedule.anyDow = fields[4] == "*" || fields[4] == "?"
+	return schedule, nil
+}
+
+func (field cronField) parse(spec string) (bits uint64, err error) {
+	for _, part := range strings.Split(spec, ",") {
+		var (
+			rangeSpec = part
+			step      = 1
+			start     int
+			end       int
+		)
+
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			rangeSpec = part[:idx]
+			if step, err = strconv.Atoi(part[idx+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("scheduler: invalid step in %q", part)
+			}
+		}
+
+		switch {
+		case rangeSpec == "*" || rangeSpec == "?":
+			start, end = field.min, field.max
+		case strings.Contains(rangeSpec, "-"):
+			bounds := strings.SplitN(rangeSpec, "-", 2)
+			if start, err = field.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if end, err = field.value(bounds[1]); err != nil {
+				return 0, err
+			}
+		default:
+			if start, err = field.value(rangeSpec); err != nil {
+				return 0, err
+			}
+			end = start
+			if strings.Contains(part, "/") {
+				end = field.max
+			}
+		}
+
+		if start < field.min || end > field.max || start > end {
+			return 0, fmt.Errorf("scheduler: %q is out of range %v-%v", part, field.min, field.max)
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func (field cronField) value(str string) (int, error) {
+	if value, ok := field.names[strings.ToUpper(str)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		return 0, fmt.Errorf("scheduler: invalid value %q", str)
+	}
+	return value, nil
+}
+
+func (schedule *Schedule) matchDay(t time.Time) bool {
+	var (
+		domMatch = schedule.dom&(1<<uint(t.Day())) != 0
+		dowMatch = schedule.dow&(1<<uint(t.Weekday())) != 0
+	)
+
+	//	like standard cron, if both day of month and day of week are restricted, match any of them
+	if schedule.anyDom || schedule.anyDow {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+//	'Next' get next activation time after t in the time zone of loc
+func (schedule *Schedule) Next(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = t.Location()
+	}
+
+	t = t.In(loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		if schedule.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !schedule.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if schedule.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if schedule.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNext(t *testing.T) {
+	var (
+		shanghai = time.FixedZone("CST", 8*3600)
+		layout   = "2006-01-02 15:04 Mon"
+	)
+
+	cases := []struct {
+		expression string
+		from       string
+		want       string
+	}{
+		{"* * * * *", "2017-05-10 10:30 Wed", "2017-05-10 10:31 Wed"},
+		{"*/15 * * * *", "2017-05-10 10:31 Wed", "2017-05-10 10:45 Wed"},
+		{"0 2 * * *", "2017-05-10 10:30 Wed", "2017-05-11 02:00 Thu"},
+		{"@daily", "2017-12-31 23:59 Sun", "2018-01-01 00:00 Mon"},
+		{"30 9 * * MON-FRI", "2017-05-12 10:00 Fri", "2017-05-15 09:30 Mon"},
+		{"0 0 1,15 * *", "2017-05-02 00:00 Tue", "2017-05-15 00:00 Mon"},
+		{"0 0 29 FEB *", "2017-03-01 00:00 Wed", "2020-02-29 00:00 Sat"},
+		{"0 12 13 * 5", "2017-05-10 00:00 Wed", "2017-05-12 12:00 Fri"},
+		{"0 0 * * 7", "2017-05-10 00:00 Wed", "2017-05-14 00:00 Sun"},
+	}
+
+	for _, c := range cases {
+		schedule, err := ParseCron(c.expression)
+		if err != nil {
+			t.Errorf("ParseCron(%q) got error %v", c.expression, err)
+			continue
+		}
+
+		from, _ := time.ParseInLocation(layout, c.from, shanghai)
+		if got := schedule.Next(from, shanghai).Format(layout); got != c.want {
+			t.Errorf("Next(%q, %v) = %v, want %v", c.expression, c.from, got, c.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *", "* * * FOO *"} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("ParseCron(%q) should return error", expression)
+		}
+	}
+}
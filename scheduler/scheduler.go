@@ -0,0 +1,348 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+)
+
+//	'MissedRunPolicy' decides what to do with runs that are missed, e.g. when all instances are down
+type MissedRunPolicy int
+
+const (
+	//	'SkipMissed' skip missed runs, only runs due in current tick will be run
+	SkipMissed MissedRunPolicy = iota
+	//	'RunOnce' run once for all missed runs
+	RunOnce
+	//	'RunAll' run every missed runs, up to 'MaxCatchUp'
+	RunAll
+)
+
+const (
+	//	'StatusRunning' task run is running
+	StatusRunning = "running"
+	//	'StatusSucceeded' task run is finished without error
+	StatusSucceeded = "succeeded"
+	//	'StatusFailed' task run returned an error
+	StatusFailed = "failed"
+)
+
+//	'Task' a recurring task
+//	'Schedule' is a cron expression, it is interpreted in 'TimeZone', e.g. "Asia/Shanghai", blank means the scheduler's location
+type Task struct {
+	Name            string
+	Schedule        string
+	TimeZone        string
+	MissedRunPolicy MissedRunPolicy
+	MaxCatchUp      int
+	Handler         func(*TM_EC.Context) error
+	schedule        *Schedule
+	location        *time.Location
+	running         bool
+}
+
+//	'Lock' lock row of a task, only the instance that owns an unexpired lock could run the task
+//	'LastScheduledAt' is the scheduled time of last run, it is used to find missed runs
+type Lock struct {
+	Name            string `sql:"size:191" gorm:"primary_key"`
+	Owner           string
+	LockedUntil     time.Time
+	LastScheduledAt *time.Time
+}
+
+//	'TableName' table name of locks
+func (Lock) TableName() string {
+	return "scheduler_locks"
+}
+
+//	'TaskRun' run history of tasks
+type TaskRun struct {
+	gorm.Model
+	Task        string `gorm:"index"`
+	Owner       string
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	FinishedAt  *time.Time
+	Status      string
+	Error       string `sql:"type:text"`
+}
+
+//	'SystemUser' current user of tasks' context
+type SystemUser struct {
+	Name string
+}
+
+//	'DisplayName' display name of the system user
+func (user SystemUser) DisplayName() string {
+	return user.Name
+}
+
+//	'Config' scheduler config
+//	'LockTTL' is how long a lock is kept after acquired or renewed, other instances could take over the task after it expired
+type Config struct {
+	DB           *gorm.DB
+	Location     *time.Location
+	Interval     time.Duration
+	LockTTL      time.Duration
+	CurrentUser  TM_EC.CurrentUser
+	Roles        []string
+	InstanceName string
+}
+
+//	'Scheduler' run registered tasks by their cron schedules, use lock rows to elect one instance to run each task
+type Scheduler struct {
+	Config *Config
+	Tasks  []*Task
+	mutex  sync.Mutex
+	wg     sync.WaitGroup
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+//	'New' initialize scheduler with config, and migrate its tables
+func New(config *Config) *Scheduler {
+	if config.Location == nil {
+		config.Location = time.Local
+	}
+
+	if config.Interval == 0 {
+		config.Interval = 15 * time.Second
+	}
+
+	if config.LockTTL == 0 {
+		config.LockTTL = 4 * config.Interval
+	}
+
+	if config.CurrentUser == nil {
+		config.CurrentUser = SystemUser{Name: "System"}
+	}
+
+	if config.InstanceName == "" {
+		hostname, _ := os.Hostname()
+		config.InstanceName = fmt.Sprintf("%v-%v-%v", hostname, os.Getpid(), rand.Int63())
+	}
+
+	config.DB.AutoMigrate(&Lock{}, &TaskRun{})
+	return &Scheduler{Config: config}
+}
+
+//	'RegisterTask' register a task, returns error if its cron expression or time zone is invalid
+func (scheduler *Scheduler) RegisterTask(task *Task) error {
+	schedule, err := ParseCron(task.Schedule)
+	if err != nil {
+		return err
+	}
+
+	task.schedule = schedule
+	task.location = scheduler.Config.Location
+	if task.TimeZone != "" {
+		if task.location, err = time.LoadLocation(task.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	if task.MaxCatchUp <= 0 {
+		task.MaxCatchUp = 100
+	}
+
+	scheduler.mutex.Lock()
+	scheduler.Tasks = append(scheduler.Tasks, task)
+	scheduler.mutex.Unlock()
+	return nil
+}
+
+//	'NextRun' get next run time of the task after t
+func (task *Task) NextRun(t time.Time) time.Time {
+	return task.schedule.Next(t, task.location)
+}
+
+//	'Start' start checking tasks every 'Interval'
+func (scheduler *Scheduler) Start() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	if scheduler.stop != nil {
+		return
+	}
+
+	scheduler.stop = make(chan struct{})
+	scheduler.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(scheduler.Config.Interval)
+		defer ticker.Stop()
+
+		for {
+			scheduler.Tick(time.Now())
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}(scheduler.stop, scheduler.done)
+}
+
+//	'Stop' stop the scheduler, wait running tasks finished, and release locks
+func (scheduler *Scheduler) Stop() {
+	scheduler.mutex.Lock()
+	stop, done := scheduler.stop, scheduler.done
+	scheduler.stop, scheduler.done = nil, nil
+	scheduler.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	scheduler.wg.Wait()
+
+	scheduler.Config.DB.Model(&Lock{}).Where("owner = ?", scheduler.Config.InstanceName).UpdateColumn("locked_until", time.Time{})
+}
+
+//	'Tick' check all tasks at now, run due tasks that this instance is the leader of
+func (scheduler *Scheduler) Tick(now time.Time) {
+	scheduler.mutex.Lock()
+	tasks := scheduler.Tasks
+	scheduler.mutex.Unlock()
+
+	for _, task := range tasks {
+		lock, ok := scheduler.acquire(task, now)
+		if !ok {
+			continue
+		}
+
+		scheduler.mutex.Lock()
+		running := task.running
+		scheduler.mutex.Unlock()
+		if running {
+			continue
+		}
+
+		if lock.LastScheduledAt == nil {
+			//	first time, start counting from now
+			scheduler.Config.DB.Model(&Lock{}).Where("name = ?", task.Name).UpdateColumn("last_scheduled_at", now)
+			continue
+		}
+
+		var due []time.Time
+		for t := task.NextRun(*lock.LastScheduledAt); !t.IsZero() && !t.After(now); t = task.NextRun(t) {
+			due = append(due, t)
+			if task.MissedRunPolicy == RunAll && len(due) >= task.MaxCatchUp {
+				break
+			}
+		}
+
+		if len(due) == 0 {
+			continue
+		}
+
+		switch task.MissedRunPolicy {
+		case SkipMissed:
+			last := due[len(due)-1]
+			if now.Sub(last) > 2*scheduler.Config.Interval {
+				//	all runs are missed
+				scheduler.Config.DB.Model(&Lock{}).Where("name = ?", task.Name).UpdateColumn("last_scheduled_at", last)
+				continue
+			}
+			due = due[len(due)-1:]
+		case RunOnce:
+			due = due[len(due)-1:]
+		}
+
+		scheduler.mutex.Lock()
+		task.running = true
+		scheduler.mutex.Unlock()
+
+		scheduler.wg.Add(1)
+		go func(task *Task, due []time.Time) {
+			defer scheduler.wg.Done()
+			defer func() {
+				scheduler.mutex.Lock()
+				task.running = false
+				scheduler.mutex.Unlock()
+			}()
+
+			for _, scheduledAt := range due {
+				scheduler.run(task, scheduledAt)
+			}
+		}(task, due)
+	}
+}
+
+//	'acquire' acquire or renew the lock of the task
+func (scheduler *Scheduler) acquire(task *Task, now time.Time) (*Lock, bool) {
+	var (
+		db   = scheduler.Config.DB
+		lock Lock
+	)
+
+	if db.Where("name = ?", task.Name).First(&lock).RecordNotFound() {
+		//	may fail if created by other instances at the same time, it is fine
+		db.Create(&Lock{Name: task.Name})
+	}
+
+	//	don't check affected rows, MySQL reports 0 if the lock is renewed with same values, read it back to check the owner instead
+	if err := db.Model(&Lock{}).Where("name = ? AND (owner = ? OR locked_until < ?)", task.Name, scheduler.Config.InstanceName, now).
+		UpdateColumns(map[string]interface{}{"owner": scheduler.Config.InstanceName, "locked_until": now.Add(scheduler.Config.LockTTL)}).Error; err != nil {
+		return nil, false
+	}
+
+	lock = Lock{}
+	if err := db.Where("name = ?", task.Name).First(&lock).Error; err != nil || lock.Owner != scheduler.Config.InstanceName {
+		return nil, false
+	}
+	return &lock, true
+}
+
+//	'NewContext' context for tasks, it uses the configured system user and roles
+func (scheduler *Scheduler) NewContext() *TM_EC.Context {
+	return &TM_EC.Context{
+		Config:      &TM_EC.Config{DB: scheduler.Config.DB},
+		CurrentUser: scheduler.Config.CurrentUser,
+		Roles:       scheduler.Config.Roles,
+	}
+}
+
+func (scheduler *Scheduler) run(task *Task, scheduledAt time.Time) {
+	var (
+		db  = scheduler.Config.DB
+		err error
+		run = TaskRun{Task: task.Name, Owner: scheduler.Config.InstanceName, ScheduledAt: scheduledAt, StartedAt: time.Now(), Status: StatusRunning}
+	)
+
+	db.Create(&run)
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("scheduler: task panicked: %v", r)
+			}
+		}()
+		err = task.Handler(scheduler.NewContext())
+	}()
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = StatusSucceeded
+	if err != nil {
+		run.Status = StatusFailed
+		run.Error = err.Error()
+	}
+
+	db.Save(&run)
+	db.Model(&Lock{}).Where("name = ?", task.Name).UpdateColumn("last_scheduled_at", scheduledAt)
+}
+
+//	'RunHistoryResource' resource of task runs, it could be used to query run history
+func RunHistoryResource() *resource.Resource {
+	res := resource.New(&TaskRun{})
+	res.Name = "Task Run"
+	return res
+}
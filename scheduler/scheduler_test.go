@@ -0,0 +1,141 @@
+package scheduler
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+)
+
+var start = time.Date(2017, 5, 10, 10, 0, 0, 0, time.UTC)
+
+func newScheduler(config *Config) *Scheduler {
+	if config.DB == nil {
+		config.DB = utils.TestDB()
+		config.DB.DropTableIfExists(&Lock{}, &TaskRun{})
+	}
+	config.Location = time.UTC
+	config.Interval = time.Second
+	config.LockTTL = time.Minute
+	return New(config)
+}
+
+func taskRuns(scheduler *Scheduler, name string) (runs []TaskRun) {
+	scheduler.wg.Wait()
+	scheduler.Config.DB.Where("task = ?", name).Order("id").Find(&runs)
+	return
+}
+
+func TestLeaderElection(t *testing.T) {
+	var (
+		first  = newScheduler(&Config{InstanceName: "first"})
+		second = newScheduler(&Config{InstanceName: "second", DB: first.Config.DB})
+	)
+
+	for _, scheduler := range []*Scheduler{first, second} {
+		scheduler.RegisterTask(&Task{Name: "report", Schedule: "* * * * *", Handler: func(*TM_EC.Context) error { return nil }})
+	}
+
+	cases := []struct {
+		scheduler *Scheduler
+		now       time.Time
+		runs      int
+		owner     string
+	}{
+		{first, start, 0, ""},
+		{second, start.Add(time.Minute), 0, ""},
+		{first, start.Add(time.Minute), 1, "first"},
+		//	renew with same values
+		{first, start.Add(time.Minute), 1, "first"},
+		{first, start.Add(2 * time.Minute), 2, "first"},
+		{second, start.Add(2 * time.Minute), 2, "first"},
+		//	lock of first is expired
+		{second, start.Add(4 * time.Minute), 3, "second"},
+		{first, start.Add(5 * time.Minute), 3, "second"},
+	}
+
+	for i, c := range cases {
+		c.scheduler.Tick(c.now)
+		if runs := taskRuns(c.scheduler, "report"); len(runs) != c.runs || (len(runs) > 0 && runs[len(runs)-1].Owner != c.owner) {
+			t.Errorf("#%v: tick %v at %v, expect %v runs by %v, but got %+v", i, c.scheduler.Config.InstanceName, c.now.Format("15:04"), c.runs, c.owner, runs)
+		}
+	}
+
+	var lock Lock
+	first.Config.DB.Where("name = ?", "report").First(&lock)
+	if lock.Owner != "second" {
+		t.Errorf("lock should be taken over by second, but got %+v", lock)
+	}
+}
+
+func TestMissedRunPolicies(t *testing.T) {
+	cases := []struct {
+		policy    MissedRunPolicy
+		now       time.Time
+		scheduled []string
+	}{
+		{SkipMissed, start.Add(5 * time.Minute), []string{"10:05"}},
+		{SkipMissed, start.Add(5*time.Minute + 30*time.Second), nil},
+		{RunOnce, start.Add(5*time.Minute + 30*time.Second), []string{"10:05"}},
+		{RunAll, start.Add(5*time.Minute + 30*time.Second), []string{"10:01", "10:02", "10:03"}},
+	}
+
+	for _, c := range cases {
+		scheduler := newScheduler(&Config{})
+		scheduler.RegisterTask(&Task{Name: "sync", Schedule: "* * * * *", MissedRunPolicy: c.policy, MaxCatchUp: 3, Handler: func(*TM_EC.Context) error { return nil }})
+		scheduler.Tick(start)
+		scheduler.Tick(c.now)
+
+		var scheduled []string
+		for _, run := range taskRuns(scheduler, "sync") {
+			scheduled = append(scheduled, run.ScheduledAt.In(time.UTC).Format("15:04"))
+		}
+
+		var lock Lock
+		scheduler.Config.DB.Where("name = ?", "sync").First(&lock)
+		if !reflect.DeepEqual(scheduled, c.scheduled) || lock.LastScheduledAt == nil || lock.LastScheduledAt.Equal(start) {
+			t.Errorf("policy %v at %v: expect runs %v, but got %v, lock %+v", c.policy, c.now.Format("15:04:05"), c.scheduled, scheduled, lock)
+		}
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	scheduler := newScheduler(&Config{})
+
+	handlers := map[string]func(*TM_EC.Context) error{
+		"succeeded": func(context *TM_EC.Context) error {
+			if context.CurrentUser.DisplayName() != "System" || context.GetDB() == nil {
+				return errors.New("invalid context")
+			}
+			return nil
+		},
+		"failed":   func(*TM_EC.Context) error { return errors.New("failed to sync") },
+		"panicked": func(*TM_EC.Context) error { panic("boom") },
+	}
+	for name, handler := range handlers {
+		scheduler.RegisterTask(&Task{Name: name, Schedule: "* * * * *", Handler: handler})
+	}
+
+	scheduler.Tick(start)
+	scheduler.Tick(start.Add(time.Minute))
+
+	cases := []struct {
+		task   string
+		status string
+		err    string
+	}{
+		{"succeeded", StatusSucceeded, ""},
+		{"failed", StatusFailed, "failed to sync"},
+		{"panicked", StatusFailed, "scheduler: task panicked: boom"},
+	}
+
+	for _, c := range cases {
+		runs := taskRuns(scheduler, c.task)
+		if len(runs) != 1 || runs[0].Status != c.status || runs[0].Error != c.err || runs[0].FinishedAt == nil || !runs[0].ScheduledAt.Equal(start.Add(time.Minute)) {
+			t.Errorf("task %v: expect run %v with error %q, but got %+v", c.task, c.status, c.err, runs)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package utils
+
+//	Formats below are derived from CLDR, they are bundled so no locale data is loaded at runtime
+//	Add or overwrite locales with
+//		utils.LocaleFormats["pt-BR"] = &utils.LocaleFormat{...}
+
+var englishRelativeTime = RelativeTimeFormat{
+	Now:    "now",
+	Past:   "%v ago",
+	Future: "in %v",
+	Units: map[string]PluralForms{
+		"second": {One: "%d second", Other: "%d seconds"},
+		"minute": {One: "%d minute", Other: "%d minutes"},
+		"hour":   {One: "%d hour", Other: "%d hours"},
+		"day":    {One: "%d day", Other: "%d days"},
+		"week":   {One: "%d week", Other: "%d weeks"},
+		"month":  {One: "%d month", Other: "%d months"},
+		"year":   {One: "%d year", Other: "%d years"},
+	},
+}
+
+var chineseRelativeTime = RelativeTimeFormat{
+	Now:    "现在",
+	Past:   "%v前",
+	Future: "%v后",
+	Units: map[string]PluralForms{
+		"second": {Other: "%d秒钟"},
+		"minute": {Other: "%d分钟"},
+		"hour":   {Other: "%d小时"},
+		"day":    {Other: "%d天"},
+		"week":   {Other: "%d周"},
+		"month":  {Other: "%d个月"},
+		"year":   {Other: "%d年"},
+	},
+}
+
+//	'LocaleFormats' formats of locales, locales are matched by full name first, then by language
+var LocaleFormats = map[string]*LocaleFormat{
+	"en": {
+		CurrencyPattern: "¤#",
+		CurrencySymbols: map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "CN¥", "CAD": "CA$", "AUD": "A$"},
+		DateLayouts:     map[DateStyle]string{DateShort: "1/2/06", DateMedium: "Jan 2, 2006", DateLong: "January 2, 2006", DateTime: "Jan 2, 2006, 3:04 PM"},
+		RelativeTime:    englishRelativeTime,
+	},
+	"en-GB": {
+		CurrencyPattern: "¤#",
+		CurrencySymbols: map[string]string{"USD": "US$", "EUR": "€", "GBP": "£", "JPY": "JP¥", "CNY": "CN¥"},
+		DateLayouts:     map[DateStyle]string{DateShort: "02/01/2006", DateMedium: "2 Jan 2006", DateLong: "2 January 2006", DateTime: "2 Jan 2006, 15:04"},
+		RelativeTime:    englishRelativeTime,
+	},
+	"de": {
+		CurrencyPattern: "#\u00a0¤",
+		CurrencySymbols: map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "CN¥"},
+		DateLayouts:     map[DateStyle]string{DateShort: "02.01.06", DateMedium: "02.01.2006", DateLong: "2. January 2006", DateTime: "02.01.2006, 15:04"},
+		Months:          []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortMonths:     []string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		RelativeTime: RelativeTimeFormat{
+			Now:    "jetzt",
+			Past:   "vor %v",
+			Future: "in %v",
+			Units: map[string]PluralForms{
+				"second": {One: "%d Sekunde", Other: "%d Sekunden"},
+				"minute": {One: "%d Minute", Other: "%d Minuten"},
+				"hour":   {One: "%d Stunde", Other: "%d Stunden"},
+				"day":    {One: "%d Tag", Other: "%d Tagen"},
+				"week":   {One: "%d Woche", Other: "%d Wochen"},
+				"month":  {One: "%d Monat", Other: "%d Monaten"},
+				"year":   {One: "%d Jahr", Other: "%d Jahren"},
+			},
+		},
+	},
+	"fr": {
+		CurrencyPattern: "#\u00a0¤",
+		CurrencySymbols: map[string]string{"USD": "$US", "EUR": "€", "GBP": "£GB", "JPY": "JPY", "CNY": "CNY"},
+		DateLayouts:     map[DateStyle]string{DateShort: "02/01/2006", DateMedium: "2 Jan 2006", DateLong: "2 January 2006", DateTime: "02/01/2006 15:04"},
+		Months:          []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		ShortMonths:     []string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		RelativeTime: RelativeTimeFormat{
+			Now:    "maintenant",
+			Past:   "il y a %v",
+			Future: "dans %v",
+			Units: map[string]PluralForms{
+				"second": {One: "%d seconde", Other: "%d secondes"},
+				"minute": {One: "%d minute", Other: "%d minutes"},
+				"hour":   {One: "%d heure", Other: "%d heures"},
+				"day":    {One: "%d jour", Other: "%d jours"},
+				"week":   {One: "%d semaine", Other: "%d semaines"},
+				"month":  {One: "%d mois", Other: "%d mois"},
+				"year":   {One: "%d an", Other: "%d ans"},
+			},
+		},
+		PluralOne: func(n int64) bool { return n == 0 || n == 1 },
+	},
+	"es": {
+		CurrencyPattern: "#\u00a0¤",
+		CurrencySymbols: map[string]string{"USD": "US$", "EUR": "€", "GBP": "GBP", "JPY": "JPY", "CNY": "CNY"},
+		DateLayouts:     map[DateStyle]string{DateShort: "2/1/06", DateMedium: "2 Jan 2006", DateLong: "2 de January de 2006", DateTime: "2 Jan 2006, 15:04"},
+		Months:          []string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		ShortMonths:     []string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		RelativeTime: RelativeTimeFormat{
+			Now:    "ahora",
+			Past:   "hace %v",
+			Future: "dentro de %v",
+			Units: map[string]PluralForms{
+				"second": {One: "%d segundo", Other: "%d segundos"},
+				"minute": {One: "%d minuto", Other: "%d minutos"},
+				"hour":   {One: "%d hora", Other: "%d horas"},
+				"day":    {One: "%d día", Other: "%d días"},
+				"week":   {One: "%d semana", Other: "%d semanas"},
+				"month":  {One: "%d mes", Other: "%d meses"},
+				"year":   {One: "%d año", Other: "%d años"},
+			},
+		},
+	},
+	"ja": {
+		CurrencyPattern: "¤#",
+		CurrencySymbols: map[string]string{"USD": "$", "EUR": "€", "GBP": "£", "JPY": "￥", "CNY": "元"},
+		DateLayouts:     map[DateStyle]string{DateShort: "2006/01/02", DateMedium: "2006/01/02", DateLong: "2006年1月2日", DateTime: "2006/01/02 15:04"},
+		RelativeTime: RelativeTimeFormat{
+			Now:    "今",
+			Past:   "%v前",
+			Future: "%v後",
+			Units: map[string]PluralForms{
+				"second": {Other: "%d 秒"},
+				"minute": {Other: "%d 分"},
+				"hour":   {Other: "%d 時間"},
+				"day":    {Other: "%d 日"},
+				"week":   {Other: "%d 週間"},
+				"month":  {Other: "%d か月"},
+				"year":   {Other: "%d 年"},
+			},
+		},
+	},
+	"zh": {
+		CurrencyPattern: "¤#",
+		CurrencySymbols: map[string]string{"USD": "US$", "EUR": "€", "GBP": "£", "JPY": "JP¥", "CNY": "¥"},
+		DateLayouts:     map[DateStyle]string{DateShort: "2006/1/2", DateMedium: "2006年1月2日", DateLong: "2006年1月2日", DateTime: "2006/1/2 15:04"},
+		RelativeTime:    chineseRelativeTime,
+	},
+	"zh-TW": {
+		CurrencyPattern: "¤#",
+		CurrencySymbols: map[string]string{"USD": "US$", "EUR": "€", "GBP": "£", "JPY": "¥", "CNY": "CN¥", "TWD": "$"},
+		DateLayouts:     map[DateStyle]string{DateShort: "2006/1/2", DateMedium: "2006年1月2日", DateLong: "2006年1月2日", DateTime: "2006/1/2 15:04"},
+		RelativeTime: RelativeTimeFormat{
+			Now:    "現在",
+			Past:   "%v前",
+			Future: "%v後",
+			Units: map[string]PluralForms{
+				"second": {Other: "%d 秒"},
+				"minute": {Other: "%d 分鐘"},
+				"hour":   {Other: "%d 小時"},
+				"day":    {Other: "%d 天"},
+				"week":   {Other: "%d 週"},
+				"month":  {Other: "%d 個月"},
+				"year":   {Other: "%d 年"},
+			},
+		},
+	},
+}
@@ -0,0 +1,354 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+)
+
+//	'DateStyle' style of localized dates, layouts of styles are defined by 'LocaleFormat'
+type DateStyle string
+
+const (
+	DateShort  DateStyle = "short"
+	DateMedium DateStyle = "medium"
+	DateLong   DateStyle = "long"
+	DateTime   DateStyle = "datetime"
+)
+
+//	'PluralForms' singular and plural forms of a message, "%d" is replaced with the count, 'Other' is used if 'One' is blank
+type PluralForms struct {
+	One   string
+	Other string
+}
+
+//	'RelativeTimeFormat' messages of relative time, e.g. "3 hours ago", 'Units' are keyed by second, minute, hour, day, week, month, year
+type RelativeTimeFormat struct {
+	Now    string
+	Past   string
+	Future string
+	Units  map[string]PluralForms
+}
+
+//	'LocaleFormat' formats of a locale, separators of numbers are defined by 'decimal.LocaleSeparators'
+//	'CurrencyPattern' is pattern of currency, "¤" is replaced with the currency symbol, "#" is replaced with the amount, e.g. "¤#" -> "$1.00"
+//	'DateLayouts' are layouts of date styles, "January" and "Jan" in layouts are replaced with 'Months' and 'ShortMonths' if they are not blank
+//	'PluralOne' reports if a count uses the singular form, it is n == 1 if blank
+type LocaleFormat struct {
+	CurrencyPattern string
+	CurrencySymbols map[string]string
+	DateLayouts     map[DateStyle]string
+	Months          []string
+	ShortMonths     []string
+	RelativeTime    RelativeTimeFormat
+	PluralOne       func(n int64) bool
+}
+
+//	'GetLocaleFormat' get formats of the locale, locales are matched by full name first, then by language, e.g. "de-AT" -> "de", fallback to "en"
+func GetLocaleFormat(locale string) *LocaleFormat {
+	locale = strings.Replace(locale, "_", "-", -1)
+	for _, name := range []string{locale, strings.SplitN(locale, "-", 2)[0], "en"} {
+		for key, format := range LocaleFormats {
+			if strings.EqualFold(key, name) {
+				return format
+			}
+		}
+	}
+	return LocaleFormats["en"]
+}
+
+//	'ToDecimal' convert numbers, decimals and money to decimal, strings are parsed with separators of the context's locale, nil and blank values are zero
+func ToDecimal(value interface{}, context *TM_EC.Context) (decimal.Decimal, error) {
+	reflectValue := reflect.ValueOf(value)
+	for reflectValue.Kind() == reflect.Ptr {
+		if reflectValue.IsNil() {
+			return decimal.Decimal{}, nil
+		}
+		reflectValue = reflectValue.Elem()
+	}
+
+	if !reflectValue.IsValid() {
+		return decimal.Decimal{}, nil
+	}
+
+	switch v := reflectValue.Interface().(type) {
+	case decimal.Decimal:
+		return v, nil
+	case decimal.Money:
+		return v.Amount, nil
+	case json.Number:
+		return decimal.NewFromString(v.String())
+	case driver.Valuer:
+		if result, err := v.Value(); err == nil {
+			if str, ok := result.(string); ok {
+				return decimal.NewFromString(str)
+			}
+			return ToDecimal(result, context)
+		}
+	}
+
+	switch reflectValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return decimal.New(reflectValue.Int(), 0), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return decimal.NewFromString(strconv.FormatUint(reflectValue.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		if f := reflectValue.Float(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return decimal.NewFromFloat(f), nil
+		}
+		return decimal.Decimal{}, fmt.Errorf("%v is not a number", value)
+	}
+
+	str := strings.TrimSpace(ToString(reflectValue.Interface()))
+	if str == "" {
+		return decimal.Decimal{}, nil
+	}
+	return decimal.Parse(str, GetLocale(context))
+}
+
+//	'FormatNumber' format number with separators of the context's locale, e.g. "1,234.5" for "en", "1.234,5" for "de"
+//	It is rounded to precision digits after decimal point, digits are kept if precision is negative, values that are not numbers are formatted with fmt.Sprint
+func FormatNumber(value interface{}, precision int, context *TM_EC.Context) string {
+	if ToString(value) == "" {
+		return ""
+	}
+
+	d, err := ToDecimal(value, context)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	if precision >= 0 {
+		d = d.Rescale(precision)
+	}
+	return d.Format(GetLocale(context))
+}
+
+//	'CurrencyDigits' digits after decimal point of currencies, currencies not in the map have 2 digits
+var CurrencyDigits = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"CLP": 0,
+	"KWD": 3,
+	"BHD": 3,
+}
+
+//	'FormatCurrency' format amount with symbol and pattern of the context's locale, e.g. "$1,234.50" for "en", "1.234,50 €" for "de"
+//	Currency of money values is used if currency is blank, currency code is used if the locale has no symbol for it
+func FormatCurrency(value interface{}, currency string, context *TM_EC.Context) string {
+	if currency == "" {
+		switch money := value.(type) {
+		case decimal.Money:
+			currency = money.Currency
+		case *decimal.Money:
+			if money != nil {
+				currency = money.Currency
+			}
+		}
+	}
+
+	d, err := ToDecimal(value, context)
+	if err != nil || ToString(value) == "" {
+		return FormatNumber(value, -1, context)
+	}
+
+	currency = strings.ToUpper(currency)
+	digits, ok := CurrencyDigits[currency]
+	if !ok {
+		digits = 2
+	}
+
+	var (
+		locale = GetLocale(context)
+		format = GetLocaleFormat(locale)
+		symbol = currency
+		sign   string
+	)
+
+	if s, ok := format.CurrencySymbols[currency]; ok {
+		symbol = s
+	}
+
+	if d = d.Rescale(digits); d.Sign() < 0 {
+		sign, d = "-", decimal.New(0, 0).Sub(d)
+	}
+
+	if symbol == "" {
+		return sign + d.Format(locale)
+	}
+	return sign + strings.TrimSpace(strings.NewReplacer("#", d.Format(locale), "¤", symbol).Replace(format.CurrencyPattern))
+}
+
+//	'FormatDate' format time with layout of the style in the context's locale and time zone, e.g. "January 2, 2006" for "en", "2006年1月2日" for "zh"
+//	Style not defined by the locale is used as layout, month names in it are still localized
+func FormatDate(date time.Time, style DateStyle, context *TM_EC.Context) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	var (
+		format = GetLocaleFormat(GetLocale(context))
+		layout = string(style)
+	)
+
+	if l, ok := format.DateLayouts[style]; ok {
+		layout = l
+	} else if l, ok := LocaleFormats["en"].DateLayouts[style]; ok {
+		layout = l
+	}
+
+	date = date.In(GetTimeLocation(context))
+	return formatMonths(date, layout, "January", format.Months, func(date time.Time, layout string) string {
+		return formatMonths(date, layout, "Jan", format.ShortMonths, func(date time.Time, layout string) string {
+			return date.Format(layout)
+		})
+	})
+}
+
+//	'formatMonths' format layout pieces around month token separately, and join them with localized month name, so month names won't be parsed as layout
+func formatMonths(date time.Time, layout string, token string, months []string, format func(time.Time, string) string) string {
+	if len(months) != 12 {
+		return format(date, layout)
+	}
+
+	var pieces []string
+	for _, piece := range strings.Split(layout, token) {
+		pieces = append(pieces, format(date, piece))
+	}
+	return strings.Join(pieces, months[date.Month()-1])
+}
+
+var relativeUnits = []struct {
+	name     string
+	duration time.Duration
+	limit    time.Duration
+}{
+	{"second", time.Second, time.Minute},
+	{"minute", time.Minute, time.Hour},
+	{"hour", time.Hour, 24 * time.Hour},
+	{"day", 24 * time.Hour, 7 * 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour, 30 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour, 365 * 24 * time.Hour},
+	{"year", 365 * 24 * time.Hour, math.MaxInt64},
+}
+
+//	'FormatRelativeTime' format time relative to now in the context's locale, e.g. "3 hours ago", "in 2 days", times within 10 seconds are "now"
+func FormatRelativeTime(date time.Time, context *TM_EC.Context) string {
+	if date.IsZero() {
+		return ""
+	}
+
+	var (
+		format   = GetLocaleFormat(GetLocale(context))
+		relative = format.RelativeTime
+		diff     = time.Since(date)
+		pattern  = relative.Past
+	)
+
+	if diff < 0 {
+		diff, pattern = -diff, relative.Future
+	}
+
+	if diff < 10*time.Second {
+		return relative.Now
+	}
+
+	for _, unit := range relativeUnits {
+		if diff < unit.limit {
+			var (
+				count = int64(diff / unit.duration)
+				forms = relative.Units[unit.name]
+				form  = forms.Other
+			)
+
+			if isOne := format.PluralOne; forms.One != "" && (isOne == nil && count == 1 || isOne != nil && isOne(count)) {
+				form = forms.One
+			}
+			return fmt.Sprintf(pattern, fmt.Sprintf(form, count))
+		}
+	}
+	return ""
+}
+
+//	'Formatter' format a value in the context's locale
+type Formatter func(value interface{}, context *TM_EC.Context) string
+
+//	'NumberFormatter' formatter of 'FormatNumber'
+func NumberFormatter(precision int) Formatter {
+	return func(value interface{}, context *TM_EC.Context) string {
+		return FormatNumber(value, precision, context)
+	}
+}
+
+//	'CurrencyFormatter' formatter of 'FormatCurrency'
+func CurrencyFormatter(currency string) Formatter {
+	return func(value interface{}, context *TM_EC.Context) string {
+		return FormatCurrency(value, currency, context)
+	}
+}
+
+//	'DateFormatter' formatter of 'FormatDate'
+func DateFormatter(style DateStyle) Formatter {
+	return func(value interface{}, context *TM_EC.Context) string {
+		if date, ok := toTime(value); ok {
+			return FormatDate(date, style, context)
+		}
+		return ""
+	}
+}
+
+//	'RelativeTimeFormatter' formatter of 'FormatRelativeTime'
+func RelativeTimeFormatter() Formatter {
+	return func(value interface{}, context *TM_EC.Context) string {
+		if date, ok := toTime(value); ok {
+			return FormatRelativeTime(date, context)
+		}
+		return ""
+	}
+}
+
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		if v != nil {
+			return *v, true
+		}
+	}
+	return time.Time{}, false
+}
+
+//	'FormattedValuer' formatted valuer of meta, it formats field of records with formatter, nested fields like "Price.Amount" are supported
+//		res.Meta(&resource.Meta{Name: "Price", FormattedValuer: utils.FormattedValuer("Price", utils.CurrencyFormatter("USD"))})
+func FormattedValuer(fieldName string, formatter Formatter) func(interface{}, *TM_EC.Context) interface{} {
+	return func(record interface{}, context *TM_EC.Context) interface{} {
+		value := reflect.ValueOf(record)
+		for _, name := range strings.Split(fieldName, ".") {
+			for value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					return ""
+				}
+				value = value.Elem()
+			}
+
+			if value.Kind() != reflect.Struct {
+				return ""
+			}
+
+			if value = value.FieldByName(name); !value.IsValid() {
+				return ""
+			}
+		}
+		return formatter(value.Interface(), context)
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/decimal"
+)
+
+func newLocaleContext(locale string) *TM_EC.Context {
+	return &TM_EC.Context{Request: httptest.NewRequest("GET", "/", nil), Locale: locale, TimeLocation: time.UTC}
+}
+
+func TestFormatNumberAndCurrency(t *testing.T) {
+	cases := []struct {
+		locale string
+		got    string
+		want   string
+	}{
+		{"en", FormatNumber(1234567.891, 2, newLocaleContext("en")), "1,234,567.89"},
+		{"de", FormatNumber(-1234.5, -1, newLocaleContext("de")), "-1.234,5"},
+		{"fr", FormatNumber(uint(1234), 0, newLocaleContext("fr")), "1\u202f234"},
+		{"en", FormatNumber("abc", 2, newLocaleContext("en")), "abc"},
+		{"de", FormatNumber("1.234,5", -1, newLocaleContext("de")), "1.234,5"},
+		{"en", FormatNumber("", 2, newLocaleContext("en")), ""},
+		{"", FormatNumber(1234.5, 1, nil), "1,234.5"},
+		{"en-US", FormatCurrency(1234.5, "USD", newLocaleContext("en-US")), "$1,234.50"},
+		{"en", FormatCurrency(-3, "usd", newLocaleContext("en")), "-$3.00"},
+		{"de-AT", FormatCurrency(decimal.MustFromString("1234.5"), "EUR", newLocaleContext("de-AT")), "1.234,50\u00a0€"},
+		{"ja", FormatCurrency(1234.56, "JPY", newLocaleContext("ja")), "￥1,235"},
+		{"zh-CN", FormatCurrency(&decimal.Money{Amount: decimal.MustFromString("99.9"), Currency: "CNY"}, "", newLocaleContext("zh-CN")), "¥99.90"},
+		{"en", FormatCurrency(10, "XYZ", newLocaleContext("en")), "XYZ10.00"},
+	}
+
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("formatted value in %v should be %q, but got %q", c.locale, c.want, c.got)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2020, 3, 5, 14, 30, 0, 0, time.UTC)
+	cases := []struct {
+		locale string
+		style  DateStyle
+		want   string
+	}{
+		{"en", DateShort, "3/5/20"},
+		{"en", DateLong, "March 5, 2020"},
+		{"en-GB", DateTime, "5 Mar 2020, 14:30"},
+		{"de", DateLong, "5. März 2020"},
+		{"fr", DateMedium, "5 mars 2020"},
+		{"es", DateLong, "5 de marzo de 2020"},
+		{"zh-CN", DateMedium, "2020年3月5日"},
+		{"de", "January 2006", "März 2020"},
+	}
+
+	for _, c := range cases {
+		if got := FormatDate(date, c.style, newLocaleContext(c.locale)); got != c.want {
+			t.Errorf("%v date in %v should be %q, but got %q", c.style, c.locale, c.want, got)
+		}
+	}
+
+	context := newLocaleContext("en")
+	context.TimeLocation, _ = time.LoadLocation("Asia/Shanghai")
+	if got := FormatDate(date, DateTime, context); got != "Mar 5, 2020, 10:30 PM" {
+		t.Errorf("date should be formatted in time zone of the context, but got %q", got)
+	}
+}
+
+func TestFormatRelativeTime(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		locale string
+		date   time.Time
+		want   string
+	}{
+		{"en", now.Add(-3*time.Hour - time.Minute), "3 hours ago"},
+		{"en", now.Add(25 * time.Hour), "in 1 day"},
+		{"en", now.Add(-2 * time.Second), "now"},
+		{"fr", now.Add(-90 * time.Second), "il y a 1 minute"},
+		{"de", now.Add(-40 * 24 * time.Hour), "vor 1 Monat"},
+		{"zh-CN", now.Add(-3 * 7 * 24 * time.Hour), "3周前"},
+		{"ja", now.Add(-800 * 24 * time.Hour), "2 年前"},
+	}
+
+	for _, c := range cases {
+		if got := FormatRelativeTime(c.date, newLocaleContext(c.locale)); got != c.want {
+			t.Errorf("relative time in %v should be %q, but got %q", c.locale, c.want, got)
+		}
+	}
+}
+
+func TestFormattedValuer(t *testing.T) {
+	type Price struct {
+		Amount float64
+	}
+
+	type Product struct {
+		Price     *Price
+		CreatedAt time.Time
+	}
+
+	var (
+		product = Product{Price: &Price{Amount: 1999.5}, CreatedAt: time.Date(2020, 3, 5, 0, 0, 0, 0, time.UTC)}
+		context = newLocaleContext("de")
+	)
+
+	if got := FormattedValuer("Price.Amount", CurrencyFormatter("EUR"))(&product, context); got != "1.999,50\u00a0€" {
+		t.Errorf("should format nested field, but got %q", got)
+	}
+
+	if got := FormattedValuer("CreatedAt", DateFormatter(DateShort))(product, context); got != "05.03.20" {
+		t.Errorf("should format date field, but got %q", got)
+	}
+
+	if got := FormattedValuer("Price.Amount", NumberFormatter(0))(&Product{}, context); got != "" {
+		t.Errorf("should be blank for nil nested field, but got %q", got)
+	}
+}
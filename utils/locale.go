@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+type languageRange struct {
+	tag     string
+	quality float64
+}
+
+//	'ParseAcceptLanguage' parse Accept-Language header as RFC 7231, language ranges are sorted by quality, ranges with quality 0 are excluded
+//		ParseAcceptLanguage("da, en-GB;q=0.8, en;q=0.7") -> ["da", "en-GB", "en"]
+func ParseAcceptLanguage(header string) (tags []string) {
+	var ranges []languageRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		tag := strings.TrimSpace(params[0])
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			if param = strings.TrimSpace(param); strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil && q >= 0 && q <= 1 {
+					quality = q
+				}
+			}
+		}
+
+		if quality > 0 {
+			ranges = append(ranges, languageRange{tag: tag, quality: quality})
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		tags = append(tags, r.tag)
+	}
+	return
+}
+
+//	'NegotiateLocale' choose the most acceptable locale of supported locales for Accept-Language header, returns blank if none of them is acceptable
+//	A language range matches locales with it as prefix, e.g. "en" matches "en-US", and a locale matches ranges with it as prefix, e.g. "zh-CN-x" matches "zh-CN"
+//	Returns the most acceptable language range if supported locales is blank
+func NegotiateLocale(header string, supported []string) string {
+	tags := ParseAcceptLanguage(header)
+	if len(supported) == 0 {
+		for _, tag := range tags {
+			if tag != "*" {
+				return tag
+			}
+		}
+		return ""
+	}
+
+	normalize := func(tag string) string {
+		return strings.ToLower(strings.Replace(tag, "_", "-", -1))
+	}
+
+	for _, tag := range tags {
+		if tag == "*" {
+			return supported[0]
+		}
+
+		tag = normalize(tag)
+		for _, locale := range supported {
+			if normalize(locale) == tag {
+				return locale
+			}
+		}
+
+		//	fallback to more general or more specific locales
+		for _, locale := range supported {
+			if l := normalize(locale); strings.HasPrefix(tag, l+"-") || strings.HasPrefix(l, tag+"-") {
+				return locale
+			}
+		}
+	}
+	return ""
+}
+
+//	'GetTimeLocation' get time zone of the context, it is resolved from current user, "Time-Zone" header, "timezone" cookie, and config in order, resolved location will be kept in the context
+//	Current user could provide its time zone by implementing interface { GetTimeZone() string }, time zone should be IANA name like "Asia/Shanghai"
+//	Overwrite the default logic with
+//		utils.GetTimeLocation = func(context *TM_EC.Context) *time.Location {
+//			//	.....
+//		}
+var GetTimeLocation = func(context *TM_EC.Context) *time.Location {
+	if context == nil {
+		return time.Local
+	}
+
+	if context.TimeLocation != nil {
+		return context.TimeLocation
+	}
+
+	var names []string
+	if user, ok := context.CurrentUser.(interface {
+		GetTimeZone() string
+	}); ok {
+		names = append(names, user.GetTimeZone())
+	}
+
+	if context.Request != nil {
+		names = append(names, context.Request.Header.Get("Time-Zone"))
+		if cookie, err := context.Request.Cookie("timezone"); err == nil {
+			names = append(names, cookie.Value)
+		}
+	}
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+
+		if location, err := time.LoadLocation(name); err == nil {
+			context.TimeLocation = location
+			return location
+		}
+	}
+
+	if context.Config != nil && context.Config.TimeLocation != nil {
+		return context.Config.TimeLocation
+	}
+	return time.Local
+}
+
+//	'SetTimeZoneCookie' save time zone to cookie, so following requests will use it
+func SetTimeZoneCookie(name string, context *TM_EC.Context) error {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+
+	context.TimeLocation = location
+	SetCookie(http.Cookie{Name: "timezone", Value: name, Expires: time.Now().AddDate(1, 0, 0)}, context)
+	return nil
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	supported := []string{"en-US", "zh-CN", "ja"}
+	cases := []struct {
+		header    string
+		supported []string
+		want      string
+	}{
+		{"zh-CN,zh;q=0.9,en;q=0.8", supported, "zh-CN"},
+		{"fr;q=0.9, ja;q=0.5, en", supported, "en-US"},
+		{"zh-cn-x-private;q=0.2, fr", supported, "zh-CN"},
+		{"en;q=0, ja;q=0.1", supported, "ja"},
+		{"fr, de", supported, ""},
+		{"fr, *;q=0.1", supported, "en-US"},
+		{"fr;q=0.5, de", nil, "de"},
+		{"", supported, ""},
+	}
+
+	for _, c := range cases {
+		if got := NegotiateLocale(c.header, c.supported); got != c.want {
+			t.Errorf("NegotiateLocale(%q, %v) = %q, want %q", c.header, c.supported, got, c.want)
+		}
+	}
+
+	if tags := ParseAcceptLanguage("da, en-GB;q=0.8, en;q=0.7, de;q=invalid"); !reflect.DeepEqual(tags, []string{"da", "de", "en-GB", "en"}) {
+		t.Errorf("ParseAcceptLanguage should sort ranges by quality, but got %v", tags)
+	}
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.Header.Set("Accept-Language", "ja;q=0.8, zh-CN")
+	context := &TM_EC.Context{Request: request, Config: &TM_EC.Config{Locales: supported}}
+	if locale := GetLocale(context); locale != "zh-CN" || context.Locale != "zh-CN" {
+		t.Errorf("locale should be negotiated with Accept-Language header, but got %v", locale)
+	}
+}
+
+type timeZoneUser struct {
+	timeZone string
+}
+
+func (user timeZoneUser) DisplayName() string {
+	return "user"
+}
+
+func (user timeZoneUser) GetTimeZone() string {
+	return user.timeZone
+}
+
+func TestGetTimeLocation(t *testing.T) {
+	cases := []struct {
+		user   string
+		header string
+		cookie string
+		want   string
+	}{
+		{"Europe/Paris", "Asia/Shanghai", "America/New_York", "Europe/Paris"},
+		{"", "Asia/Shanghai", "America/New_York", "Asia/Shanghai"},
+		{"", "Unknown/Zone", "America/New_York", "America/New_York"},
+		{"", "", "", "UTC"},
+	}
+
+	for _, c := range cases {
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Header.Set("Time-Zone", c.header)
+		if c.cookie != "" {
+			request.AddCookie(&http.Cookie{Name: "timezone", Value: c.cookie})
+		}
+
+		context := &TM_EC.Context{Request: request, CurrentUser: timeZoneUser{c.user}, Config: &TM_EC.Config{TimeLocation: time.UTC}}
+		if location := GetTimeLocation(context); location.String() != c.want {
+			t.Errorf("time zone of %+v should be %v, but got %v", c, c.want, location)
+		}
+	}
+}
@@ -39,8 +39,9 @@ func ToArray(value interface{}) (values []string) {
 		for _, v := range value {
 			values = append(values, fmt.Sprint(v))
 		}
+	case nil:
 	default:
-		if value := fmt.Sprint(values); value != "" {
+		if value := fmt.Sprint(value); value != "" {
 			values = []string{value}
 		}
 	}
@@ -48,7 +49,7 @@ func ToArray(value interface{}) (values []string) {
 	return
 }
 
-//	'ToString' get string from value, if passed value is a slice, will use the first element
+//	'ToString' get string from value, if passed value is a slice, will use the first element, nil is blank string
 func ToString(value interface{}) string {
 	if v, ok := value.([]string); ok {
 		if len(v) > 0 {
@@ -59,9 +60,11 @@ func ToString(value interface{}) string {
 		return v
 	} else if v, ok := value.([]interface{}); ok {
 		if len(v) > 0 {
-			return fmt.Sprint("%v", v[0])
+			return fmt.Sprint(v[0])
 		}
 		return ""
+	} else if value == nil {
+		return ""
 	}
 
 	return fmt.Sprintf("%v", value)
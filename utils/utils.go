@@ -162,8 +162,13 @@ func ParseTagOption(str string) map[string]string {
 	tags := strings.Split(str, ";")
 	setting := map[string]string{}
 	for _, value := range tags {
-		v := strings.Split(value, ":")
+		//	only split by first colon, so values like patterns could contain colons
+		v := strings.SplitN(value, ":", 2)
 		k := strings.TrimSpace(strings.ToUpper(v[0]))
+		if k == "" {
+			continue
+		}
+
 		if len(v) == 2 {
 			setting[k] = v[1]
 		} else {
@@ -209,46 +214,71 @@ func filenameWithLineNum() string {
 	return ""
 }
 
-//	'GetLocale' get locale from reqeust, cookie, after get the locale, will write the locale to the cookie if possible
+//	'GetLocale' get locale of the context, it is resolved from "Locale" header, "locale" query, cookie, and Accept-Language header in order, resolved locale will be kept in the context
+//	Accept-Language header is negotiated with 'Locales' of the config, it is blank if none of them is acceptable, locale passed by query will be written to the cookie if possible
+//	It is blank if the context is nil
 //	Overwrite the default logic with
 //		utils.GetLocal = func(context *TM_EC.Context) string {
 //			//	.....
 //		}
 var GetLocale = func(context *TM_EC.Context) string {
+	if context == nil {
+		return ""
+	}
+
+	if context.Locale != "" || context.Request == nil {
+		return context.Locale
+	}
+
+	context.Locale = getRequestLocale(context)
+	return context.Locale
+}
+
+func getRequestLocale(context *TM_EC.Context) string {
 	if locale := context.Request.Header.Get("Locale"); locale != "" {
 		return locale
 	}
 
-	if locale := context.Request.URL.Query().Get("locale"); locale != "" {
-		if context.Writer != nil {
-			context.Request.Header.Set("Locale", locale)
-			SetCookie(http.Cookie{Name: "locale", Value: locale, Expires: time.Now().AddDate(1, 0, 0)}, context)
-		}
+	if context.Request.URL != nil {
+		if locale := context.Request.URL.Query().Get("locale"); locale != "" {
+			if context.Writer != nil {
+				context.Request.Header.Set("Locale", locale)
+				SetCookie(http.Cookie{Name: "locale", Value: locale, Expires: time.Now().AddDate(1, 0, 0)}, context)
+			}
 
-		return locale
+			return locale
+		}
 	}
 
 	if locale, err := context.Request.Cookie("locale"); err == nil {
 		return locale.Value
 	}
 
+	if acceptLanguage := context.Request.Header.Get("Accept-Language"); acceptLanguage != "" {
+		var locales []string
+		if context.Config != nil {
+			locales = context.Config.Locales
+		}
+		return NegotiateLocale(acceptLanguage, locales)
+	}
+
 	return ""
 }
 
-//	'ParseTime' parse time from string
+//	'ParseTime' parse time from string, time without zone is parsed in time zone of the context
 //	Overwrite the default logic with
 //		utils.ParseTime = func(timeStr string, context *TM_EC.Context) (time.Time, error) {
 //			//	.....
 //		}
 var ParseTime = func(timeStr string, context *TM_EC.Context) (time.Time, error) {
-	return now.Parse(timeStr)
+	return now.New(time.Now().In(GetTimeLocation(context))).Parse(timeStr)
 }
 
-//	'FormatTime' format time to string
+//	'FormatTime' format time to string in time zone of the context
 //	Overwrite the default logic with
 //		utils.FormatTime = func(date time.Time, format string, context *TM_EC.Context) string {
 //			//	.....
 //		}
 var FormatTime = func(date time.Time, format string, context *TM_EC.Context) string {
-	return date.Format(format)
+	return date.In(GetTimeLocation(context)).Format(format)
 }
@@ -0,0 +1,325 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	//	'StatusPending' delivery is waiting to be sent or retried
+	StatusPending = "pending"
+	//	'StatusSucceeded' delivery is accepted by the subscriber
+	StatusSucceeded = "succeeded"
+	//	'StatusFailed' delivery is failed after max attempts
+	StatusFailed = "failed"
+)
+
+const (
+	//	'SignatureHeader' header of the HMAC-SHA256 signature, its value looks like "sha256=<hex>"
+	SignatureHeader = "X-EC-Signature"
+	//	'TimestampHeader' header of the unix timestamp that used in signature
+	TimestampHeader = "X-EC-Timestamp"
+	//	'EventHeader' header of the event name
+	EventHeader = "X-EC-Event"
+	//	'DeliveryHeader' header of the delivery id, it won't change when retry, subscribers could use it to de-duplicate deliveries
+	DeliveryHeader = "X-EC-Delivery"
+)
+
+//	'ErrSubscriptionNotFound' error of deliveries whose subscription is deleted
+var ErrSubscriptionNotFound = errors.New("webhook: subscription not found")
+
+//	'Delivery' a webhook delivery of an event to a subscription
+//	'Attempts' counts all attempts including redelivered ones, the delivery is failed when it reaches 'MaxAttempts'
+type Delivery struct {
+	gorm.Model
+	SubscriptionID uint
+	Event          string
+	Resource       string
+	PrimaryKey     string
+	Payload        string `sql:"type:text"`
+	Status         string
+	Attempts       int
+	MaxAttempts    int
+	NextAttemptAt  *time.Time
+	DeliveredAt    *time.Time
+	Logs           []DeliveryLog
+}
+
+//	'DeliveryLog' log of a delivery attempt
+type DeliveryLog struct {
+	gorm.Model
+	DeliveryID   uint
+	Attempt      int
+	ResponseCode int
+	ResponseBody string `sql:"type:text"`
+	Error        string `sql:"type:text"`
+	Duration     time.Duration
+}
+
+//	'Sign' sign payload with secret, the signature is hex encoded HMAC-SHA256 of "<timestamp>.<payload>"
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//	'VerifyRequest' verify the signature of a delivery request, subscribers could use it to check the request is sent from us
+func VerifyRequest(secret string, request *http.Request, payload []byte) bool {
+	timestamp, err := strconv.ParseInt(request.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(request.Header.Get(SignatureHeader)))
+}
+
+//	'Start' start a goroutine to send pending deliveries, it will check deliveries every 'PollInterval' or when registered resources changed
+func (webhooks *Webhooks) Start() {
+	webhooks.mutex.Lock()
+	defer webhooks.mutex.Unlock()
+
+	if webhooks.stop != nil {
+		return
+	}
+
+	webhooks.stop = make(chan struct{})
+	webhooks.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(webhooks.Config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			webhooks.DeliverPending()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			case <-webhooks.wakeup:
+			}
+		}
+	}(webhooks.stop, webhooks.done)
+}
+
+//	'Stop' stop sending deliveries, it will wait current deliveries finished
+func (webhooks *Webhooks) Stop() {
+	webhooks.mutex.Lock()
+	defer webhooks.mutex.Unlock()
+
+	if webhooks.stop == nil {
+		return
+	}
+
+	close(webhooks.stop)
+	<-webhooks.done
+	webhooks.stop, webhooks.done = nil, nil
+}
+
+//	'Wakeup' notify the started goroutine to send pending deliveries now
+func (webhooks *Webhooks) Wakeup() {
+	select {
+	case webhooks.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+//	'DeliverPending' send all pending deliveries that reached their next attempt time
+//	Deliveries of different subscriptions are sent concurrently up to 'Concurrency', so a slow subscriber won't block others
+//	Every delivery is claimed before sent, so it won't be sent twice when deliveries are sent by several processes at the same time
+func (webhooks *Webhooks) DeliverPending() error {
+	var deliveries []Delivery
+	if err := webhooks.Config.DB.Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now()).Order("id").Find(&deliveries).Error; err != nil {
+		return err
+	}
+
+	var (
+		subscriptionIDs []uint
+		groups          = map[uint][]*Delivery{}
+	)
+	for i := range deliveries {
+		id := deliveries[i].SubscriptionID
+		if _, ok := groups[id]; !ok {
+			subscriptionIDs = append(subscriptionIDs, id)
+		}
+		groups[id] = append(groups[id], &deliveries[i])
+	}
+
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, webhooks.Config.Concurrency)
+	)
+	for _, id := range subscriptionIDs {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(deliveries []*Delivery) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			for _, delivery := range deliveries {
+				if webhooks.claim(delivery) {
+					webhooks.Deliver(delivery)
+				}
+			}
+		}(groups[id])
+	}
+	wg.Wait()
+	return nil
+}
+
+//	'claim' lease the delivery by moving its next attempt time after the attempt's timeout, it may be claimed by other processes
+//	The lease is replaced by the result of the attempt, if the process crashed during the attempt, the delivery will be sent again after the lease expired
+func (webhooks *Webhooks) claim(delivery *Delivery) bool {
+	var (
+		now   = time.Now()
+		lease = now.Add(2 * webhooks.Config.Timeout)
+	)
+
+	if webhooks.Config.DB.Model(&Delivery{}).Where("id = ? AND status = ? AND attempts = ? AND next_attempt_at <= ?", delivery.ID, StatusPending, delivery.Attempts, now).UpdateColumn("next_attempt_at", lease).RowsAffected == 0 {
+		return false
+	}
+
+	delivery.NextAttemptAt = &lease
+	return true
+}
+
+//	'Deliver' send the delivery to its subscription, and log the attempt, failed delivery will be retried with exponential backoff until reach 'MaxAttempts'
+//	Delivery of a deleted subscription is failed without sending
+func (webhooks *Webhooks) Deliver(delivery *Delivery) error {
+	var (
+		db           = webhooks.Config.DB
+		subscription Subscription
+		log          = DeliveryLog{DeliveryID: delivery.ID}
+	)
+
+	if delivery.MaxAttempts == 0 {
+		delivery.MaxAttempts = webhooks.Config.MaxAttempts
+	}
+
+	if scope := db.First(&subscription, delivery.SubscriptionID); scope.RecordNotFound() {
+		delivery.Status = StatusFailed
+		log.Attempt, log.Error = delivery.Attempts, ErrSubscriptionNotFound.Error()
+		db.Create(&log)
+		if err := db.Model(delivery).UpdateColumn("status", StatusFailed).Error; err != nil {
+			return err
+		}
+		return ErrSubscriptionNotFound
+	} else if scope.Error != nil {
+		return scope.Error
+	}
+
+	delivery.Attempts++
+	log.Attempt = delivery.Attempts
+
+	var (
+		startAt   = time.Now()
+		payload   = []byte(delivery.Payload)
+		err       error
+		request   *http.Request
+		response  *http.Response
+		succeeded bool
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhooks.Config.Timeout)
+	defer cancel()
+
+	if request, err = http.NewRequest("POST", subscription.URL, bytes.NewReader(payload)); err == nil {
+		request = request.WithContext(ctx)
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set(EventHeader, delivery.Event)
+		request.Header.Set(DeliveryHeader, fmt.Sprint(delivery.ID))
+		request.Header.Set(TimestampHeader, strconv.FormatInt(startAt.Unix(), 10))
+		request.Header.Set(SignatureHeader, Sign(subscription.Secret, startAt.Unix(), payload))
+
+		if response, err = webhooks.Config.Client.Do(request); err == nil {
+			body, _ := ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			log.ResponseCode = response.StatusCode
+			log.ResponseBody = string(body)
+			succeeded = response.StatusCode >= 200 && response.StatusCode < 300
+			if !succeeded {
+				err = fmt.Errorf("unexpected response status %v", response.StatusCode)
+			}
+		}
+	}
+
+	log.Duration = time.Since(startAt)
+	if err != nil {
+		log.Error = err.Error()
+	}
+
+	if succeeded {
+		now := time.Now()
+		delivery.Status = StatusSucceeded
+		delivery.DeliveredAt = &now
+	} else if delivery.Attempts >= delivery.MaxAttempts {
+		delivery.Status = StatusFailed
+	} else {
+		next := time.Now().Add(webhooks.backoff(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+
+	db.Create(&log)
+	if e := db.Model(delivery).Updates(map[string]interface{}{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"max_attempts":    delivery.MaxAttempts,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"delivered_at":    delivery.DeliveredAt,
+	}).Error; e != nil {
+		return e
+	}
+	return err
+}
+
+func (webhooks *Webhooks) backoff(attempts int) time.Duration {
+	duration := webhooks.Config.Backoff
+	for i := 1; i < attempts; i++ {
+		duration *= 2
+		if duration >= webhooks.Config.MaxBackoff {
+			return webhooks.Config.MaxBackoff
+		}
+	}
+	return duration
+}
+
+//	'Redeliver' reset a delivery to pending, it will be sent again immediately with another 'MaxAttempts' attempts
+//	Its attempts are kept, so attempt logs are numbered continuously and retries continue backing off from its last attempt
+func (webhooks *Webhooks) Redeliver(deliveryID uint) error {
+	var delivery Delivery
+	if err := webhooks.Config.DB.First(&delivery, deliveryID).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := webhooks.Config.DB.Model(&delivery).Updates(map[string]interface{}{
+		"status":          StatusPending,
+		"max_attempts":    delivery.Attempts + webhooks.Config.MaxAttempts,
+		"next_attempt_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+
+	webhooks.Wakeup()
+	return nil
+}
+
+//	'GetDeliveryLogs' get attempt logs of a delivery
+func (webhooks *Webhooks) GetDeliveryLogs(deliveryID uint) (logs []DeliveryLog, err error) {
+	err = webhooks.Config.DB.Where("delivery_id = ?", deliveryID).Order("id").Find(&logs).Error
+	return
+}
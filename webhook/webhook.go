@@ -0,0 +1,208 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+)
+
+const (
+	//	'EventCreated' event name when a record created
+	EventCreated = "created"
+	//	'EventUpdated' event name when a record updated
+	EventUpdated = "updated"
+	//	'EventDeleted' event name when a record deleted
+	EventDeleted = "deleted"
+)
+
+//	'Subscription' a webhook subscription, deliveries will be sent to 'URL' when 'Events' of 'Resource' happened
+//	'Events' is a comma separated event names, blank means all events
+type Subscription struct {
+	gorm.Model
+	URL      string
+	Secret   string
+	Resource string
+	Events   string
+	Disabled bool
+}
+
+//	'Subscribed' check the subscription subscribed the event of resource or not
+func (subscription Subscription) Subscribed(resourceName, event string) bool {
+	if subscription.Disabled || subscription.Resource != resourceName {
+		return false
+	}
+
+	if strings.TrimSpace(subscription.Events) == "" {
+		return true
+	}
+
+	for _, e := range strings.Split(subscription.Events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
+
+//	'Payload' content of a webhook delivery
+type Payload struct {
+	Event      string                 `json:"event"`
+	Resource   string                 `json:"resource"`
+	PrimaryKey string                 `json:"primary_key"`
+	Data       map[string]interface{} `json:"data"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+//	'Config' webhooks config
+//	'Timeout' is the max duration of a delivery attempt, it applies even if 'Client' has no timeout
+//	'Concurrency' is how many subscriptions are sent to at the same time, deliveries of a subscription are sent one by one in order
+type Config struct {
+	DB           *gorm.DB
+	Client       *http.Client
+	Timeout      time.Duration
+	Concurrency  int
+	MaxAttempts  int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+}
+
+//	'Webhooks' webhooks manager, it enqueue deliveries when registered resources changed, and send them with retries
+type Webhooks struct {
+	Config *Config
+	wakeup chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	mutex  sync.Mutex
+}
+
+//	'New' initialize webhooks with config, and migrate its tables
+func New(config *Config) *Webhooks {
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: config.Timeout}
+	}
+
+	if config.Concurrency <= 0 {
+		config.Concurrency = 8
+	}
+
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 8
+	}
+
+	if config.Backoff == 0 {
+		config.Backoff = 30 * time.Second
+	}
+
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = 6 * time.Hour
+	}
+
+	if config.PollInterval == 0 {
+		config.PollInterval = 10 * time.Second
+	}
+
+	config.DB.AutoMigrate(&Subscription{}, &Delivery{}, &DeliveryLog{})
+	return &Webhooks{Config: config, wakeup: make(chan struct{}, 1)}
+}
+
+//	'Register' enqueue deliveries when records of the resource created, updated or deleted, payload data is built from metas' formatted valuer
+//	if no metas passed, will use metas returned by 'resource.DefaultMetas'
+func (webhooks *Webhooks) Register(res resource.Resourcer, metas ...resource.Metaor) {
+	if len(metas) == 0 {
+		metas = resource.DefaultMetas(res)
+	}
+
+	var (
+		name   = res.GetResource().Name
+		wakeup = func(interface{}, *resource.MetaValues, *TM_EC.Context) error {
+			webhooks.Wakeup()
+			return nil
+		}
+	)
+
+	res.GetResource().AddCallback(resource.CallbackAfterSave, "webhook:enqueue", func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		if resource.IsNewRecord(context) {
+			return webhooks.Enqueue(name, EventCreated, record, metas, context)
+		}
+		return webhooks.Enqueue(name, EventUpdated, record, metas, context)
+	})
+	res.GetResource().AddCallback(resource.CallbackAfterDelete, "webhook:enqueue", func(record interface{}, metaValues *resource.MetaValues, context *TM_EC.Context) error {
+		return webhooks.Enqueue(name, EventDeleted, record, metas, context)
+	})
+	res.GetResource().AddCallback(resource.CallbackAfterSaveCommit, "webhook:wakeup", wakeup)
+	res.GetResource().AddCallback(resource.CallbackAfterDeleteCommit, "webhook:wakeup", wakeup)
+}
+
+//	'Enqueue' create pending deliveries for subscriptions of the event, it use the context's db, so deliveries will be rollbacked with the transaction
+func (webhooks *Webhooks) Enqueue(resourceName, event string, record interface{}, metas []resource.Metaor, context *TM_EC.Context) error {
+	var (
+		db            = context.GetDB()
+		subscriptions []Subscription
+	)
+
+	if err := db.Where("resource = ?", resourceName).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+
+	var matched []Subscription
+	for _, subscription := range subscriptions {
+		if subscription.Subscribed(resourceName, event) {
+			matched = append(matched, subscription)
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil
+	}
+
+	payload := Payload{
+		Event:      event,
+		Resource:   resourceName,
+		PrimaryKey: resource.PrimaryValueOf(record),
+		Data:       map[string]interface{}{},
+		CreatedAt:  time.Now(),
+	}
+
+	for _, meta := range metas {
+		if valuer := meta.GetFormattedValuer(); valuer != nil {
+			payload.Data[meta.GetName()] = valuer(record, context)
+		}
+	}
+
+	content, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, subscription := range matched {
+		delivery := Delivery{
+			SubscriptionID: subscription.ID,
+			Event:          event,
+			Resource:       resourceName,
+			PrimaryKey:     payload.PrimaryKey,
+			Payload:        string(content),
+			Status:         StatusPending,
+			MaxAttempts:    webhooks.Config.MaxAttempts,
+			NextAttemptAt:  &now,
+		}
+
+		if err := db.Create(&delivery).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+	"github.com/Sky-And-Hammer/TM_EC/webhook"
+)
+
+type Order struct {
+	gorm.Model
+	Code string
+}
+
+func newWebhooks(t *testing.T) (*webhook.Webhooks, *resource.Resource, *TM_EC.Context) {
+	db := utils.TestDB()
+	db.DropTableIfExists(&Order{}, &webhook.Subscription{}, &webhook.Delivery{}, &webhook.DeliveryLog{})
+	db.AutoMigrate(&Order{})
+
+	webhooks := webhook.New(&webhook.Config{DB: db, Backoff: time.Millisecond, MaxAttempts: 3})
+	res := resource.New(&Order{})
+	webhooks.Register(res)
+	return webhooks, res, &TM_EC.Context{Config: &TM_EC.Config{DB: db}}
+}
+
+func TestDeliverySigned(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		payloads []webhook.Payload
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !webhook.VerifyRequest("secret", r, body) {
+			t.Errorf("invalid signature %v", r.Header.Get(webhook.SignatureHeader))
+		}
+
+		var payload webhook.Payload
+		json.Unmarshal(body, &payload)
+		mutex.Lock()
+		payloads = append(payloads, payload)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	webhooks, res, context := newWebhooks(t)
+	webhooks.Config.DB.Create(&webhook.Subscription{URL: server.URL, Secret: "secret", Resource: res.Name, Events: "created,deleted"})
+
+	order := Order{Code: "O001"}
+	if err := res.CallSave(&order, context); err != nil {
+		t.Fatal(err)
+	}
+
+	order.Code = "O002"
+	if err := res.CallSave(&order, context); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := webhooks.DeliverPending(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(payloads) != 1 {
+		t.Fatalf("should only deliver subscribed events, but got %v", len(payloads))
+	}
+
+	if payloads[0].Event != webhook.EventCreated || payloads[0].Data["Code"] != "O001" {
+		t.Errorf("unexpected payload %+v", payloads[0])
+	}
+}
+
+func TestDeliveryRetry(t *testing.T) {
+	var failed = true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failed {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	webhooks, res, context := newWebhooks(t)
+	webhooks.Config.DB.Create(&webhook.Subscription{URL: server.URL, Secret: "secret", Resource: res.Name})
+
+	if err := res.CallSave(&Order{Code: "O001"}, context); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+		webhooks.DeliverPending()
+	}
+
+	var delivery webhook.Delivery
+	webhooks.Config.DB.First(&delivery)
+	if delivery.Status != webhook.StatusFailed || delivery.Attempts != 3 {
+		t.Fatalf("delivery should be failed after max attempts, but got %v with %v attempts", delivery.Status, delivery.Attempts)
+	}
+
+	webhooks.Redeliver(delivery.ID)
+	webhooks.DeliverPending()
+
+	webhooks.Config.DB.First(&delivery, delivery.ID)
+	if delivery.Status != webhook.StatusPending || delivery.Attempts != 4 || delivery.NextAttemptAt.Sub(delivery.UpdatedAt) < 4*time.Millisecond {
+		t.Fatalf("redelivered delivery should be retried with its attempts and backoff, but got %v with %v attempts", delivery.Status, delivery.Attempts)
+	}
+
+	failed = false
+	time.Sleep(20 * time.Millisecond)
+	webhooks.DeliverPending()
+
+	webhooks.Config.DB.First(&delivery, delivery.ID)
+	if delivery.Status != webhook.StatusSucceeded {
+		t.Errorf("delivery should be succeeded after redeliver, but got %v", delivery.Status)
+	}
+
+	logs, _ := webhooks.GetDeliveryLogs(delivery.ID)
+	if len(logs) != 5 {
+		t.Fatalf("should log all attempts, but got %v", len(logs))
+	}
+
+	for i, log := range logs {
+		if log.Attempt != i+1 {
+			t.Errorf("attempts should be numbered continuously, but got %v for log %v", log.Attempt, i)
+		}
+	}
+}
+
+func TestDeliverConcurrently(t *testing.T) {
+	var (
+		fast    = make(chan struct{})
+		servers = map[string]http.HandlerFunc{
+			//	only succeed if the fast subscription is sent to at the same time
+			"slow": func(w http.ResponseWriter, r *http.Request) {
+				ioutil.ReadAll(r.Body)
+				select {
+				case <-fast:
+				case <-r.Context().Done():
+				}
+			},
+			"fast": func(w http.ResponseWriter, r *http.Request) {
+				close(fast)
+			},
+			"hung": func(w http.ResponseWriter, r *http.Request) {
+				//	closed connection is only noticed after the body is read
+				ioutil.ReadAll(r.Body)
+				<-r.Context().Done()
+			},
+		}
+	)
+
+	webhooks, res, context := newWebhooks(t)
+	webhooks.Config.Timeout = 200 * time.Millisecond
+
+	subscriptions := map[string]uint{}
+	for _, name := range []string{"slow", "fast", "hung"} {
+		server := httptest.NewServer(servers[name])
+		defer server.Close()
+
+		subscription := webhook.Subscription{URL: server.URL, Resource: res.Name}
+		webhooks.Config.DB.Create(&subscription)
+		subscriptions[name] = subscription.ID
+	}
+
+	res.CallSave(&Order{Code: "O001"}, context)
+
+	startAt := time.Now()
+	webhooks.DeliverPending()
+	if duration := time.Since(startAt); duration > time.Second {
+		t.Errorf("deliveries should be sent with timeout, but took %v", duration)
+	}
+
+	cases := []struct {
+		name   string
+		status string
+	}{
+		{"slow", webhook.StatusSucceeded},
+		{"fast", webhook.StatusSucceeded},
+		{"hung", webhook.StatusPending},
+	}
+
+	for _, c := range cases {
+		var delivery webhook.Delivery
+		webhooks.Config.DB.Where("subscription_id = ?", subscriptions[c.name]).First(&delivery)
+		logs, _ := webhooks.GetDeliveryLogs(delivery.ID)
+		if delivery.Status != c.status || len(logs) != 1 || (logs[0].Error == "") != (c.status == webhook.StatusSucceeded) {
+			t.Errorf("%v: expect delivery %v, but got %v with logs %+v", c.name, c.status, delivery.Status, logs)
+		}
+	}
+}
+
+func TestDeliverDeletedSubscription(t *testing.T) {
+	webhooks, res, context := newWebhooks(t)
+
+	subscription := webhook.Subscription{URL: "http://localhost", Resource: res.Name}
+	webhooks.Config.DB.Create(&subscription)
+	res.CallSave(&Order{Code: "O001"}, context)
+	webhooks.Config.DB.Delete(&subscription)
+
+	webhooks.DeliverPending()
+
+	var delivery webhook.Delivery
+	webhooks.Config.DB.First(&delivery)
+	logs, _ := webhooks.GetDeliveryLogs(delivery.ID)
+	if delivery.Status != webhook.StatusFailed || delivery.Attempts != 0 || len(logs) != 1 || logs[0].Error != webhook.ErrSubscriptionNotFound.Error() {
+		t.Errorf("delivery of deleted subscription should be failed, but got %+v with logs %+v", delivery, logs)
+	}
+}
+
+func TestDeliverClaimed(t *testing.T) {
+	var (
+		webhooks, res, context = newWebhooks(t)
+		requests               int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		//	deliveries sent by other processes at the same time should skip the claimed delivery
+		webhooks.DeliverPending()
+	}))
+	defer server.Close()
+
+	webhooks.Config.DB.Create(&webhook.Subscription{URL: server.URL, Resource: res.Name})
+	res.CallSave(&Order{Code: "O001"}, context)
+	webhooks.DeliverPending()
+
+	var delivery webhook.Delivery
+	webhooks.Config.DB.First(&delivery)
+	if requests != 1 || delivery.Status != webhook.StatusSucceeded || delivery.Attempts != 1 {
+		t.Errorf("claimed delivery should be sent once, but got %v requests, %+v", requests, delivery)
+	}
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+)
+
+const (
+	//	'StatusQueued' job is waiting to be run
+	StatusQueued = "queued"
+	//	'StatusRunning' job is running
+	StatusRunning = "running"
+	//	'StatusSucceeded' job is finished without error
+	StatusSucceeded = "succeeded"
+	//	'StatusFailed' job is failed after all retries
+	StatusFailed = "failed"
+	//	'StatusCancelling' job is requested to cancel, but it is still running
+	StatusCancelling = "cancelling"
+	//	'StatusCancelled' job is cancelled
+	StatusCancelled = "cancelled"
+)
+
+//	'Job' job definition
+//	'Resource' is used to decode job's argument from meta values, its 'Value' is the argument struct, it could be nil if the job doesn't need argument
+//	'Concurrency' limits how many this job could run at the same time in a worker, zero means only limited by worker's concurrency
+type Job struct {
+	Name        string
+	Resource    *resource.Resource
+	Handler     func(argument interface{}, job *JobContext) error
+	MaxRetries  int
+	Backoff     time.Duration
+	Concurrency int
+	running     int
+}
+
+//	'JobRecord' a queued job, it is also the job history
+type JobRecord struct {
+	gorm.Model
+	Name        string `gorm:"index"`
+	Status      string `gorm:"index"`
+	Argument    string `sql:"type:text"`
+	Progress    uint
+	Log         string `sql:"type:text"`
+	Error       string `sql:"type:text"`
+	Attempts    int
+	RunAt       *time.Time
+	StartedAt   *time.Time
+	HeartbeatAt *time.Time
+	FinishedAt  *time.Time
+	UserKey     string
+	UserName    string
+	Roles       string
+	Locale      string
+}
+
+//	'GetRoles' get roles of the user that enqueued the job
+func (record JobRecord) GetRoles() []string {
+	if record.Roles == "" {
+		return nil
+	}
+	return strings.Split(record.Roles, ",")
+}
+
+//	'JobContext' is passed to job handlers, used to report progress, write logs, and check cancellation
+//	'Context' is reconstructed from the request that enqueued the job, includes user, roles and locale
+type JobContext struct {
+	Record    *JobRecord
+	Context   *TM_EC.Context
+	worker    *Worker
+	cancelled chan struct{}
+	once      sync.Once
+}
+
+//	'SetProgress' set job's progress, progress should between 0 and 100
+func (job *JobContext) SetProgress(progress uint) error {
+	if progress > 100 {
+		progress = 100
+	}
+
+	job.Record.Progress = progress
+	return job.worker.Config.DB.Model(job.Record).UpdateColumn("progress", progress).Error
+}
+
+//	'AddLog' append a log line to the job
+func (job *JobContext) AddLog(format string, values ...interface{}) error {
+	line := fmt.Sprintf("[%v] %v\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, values...))
+	job.worker.mutex.Lock()
+	job.Record.Log += line
+	log := job.Record.Log
+	job.worker.mutex.Unlock()
+	return job.worker.Config.DB.Model(job.Record).UpdateColumn("log", log).Error
+}
+
+//	'Cancelled' returns a channel that is closed when the job is requested to cancel
+func (job *JobContext) Cancelled() <-chan struct{} {
+	return job.cancelled
+}
+
+//	'IsCancelled' check the job is requested to cancel or not, long running handlers should check it and return 'ErrCancelled'
+func (job *JobContext) IsCancelled() bool {
+	select {
+	case <-job.cancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (job *JobContext) cancel() {
+	job.once.Do(func() { close(job.cancelled) })
+}
+
+//	'HistoryResource' resource of job records, it could be used to query job history
+func HistoryResource() *resource.Resource {
+	res := resource.New(&JobRecord{})
+	res.Name = "Job History"
+	return res
+}
@@ -0,0 +1,419 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	//  The fantastic ORM library for Golang, aims to be developer friendly.
+	"github.com/jinzhu/gorm"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/resource"
+	"github.com/Sky-And-Hammer/TM_EC/utils"
+)
+
+var (
+	//	'ErrCancelled' job handlers should return it when they stopped because of cancellation
+	ErrCancelled = errors.New("worker: job cancelled")
+	//	'ErrJobNotFound' returned when enqueue a job that is not registered
+	ErrJobNotFound = errors.New("worker: job not found")
+	//	'ErrLeaseExpired' error of jobs recovered as their workers stopped sending heartbeats
+	ErrLeaseExpired = errors.New("worker: job lease expired")
+)
+
+//	'Config' worker config
+//	'FindUser' is used to reconstruct current user of job's context from the key of the user that enqueued it, the key is primary key if the user is a gorm model, otherwise it is the display name
+//	'LeaseTimeout' running jobs send heartbeats every third of it, jobs without heartbeat longer than it are treated as crashed, and will be retried or failed by any worker
+type Config struct {
+	DB           *gorm.DB
+	Concurrency  int
+	PollInterval time.Duration
+	LeaseTimeout time.Duration
+	FindUser     func(key string, context *TM_EC.Context) TM_EC.CurrentUser
+}
+
+//	'Worker' run registered jobs from a database backed queue with a pool of goroutines
+type Worker struct {
+	Config  *Config
+	Jobs    []*Job
+	running map[uint]*JobContext
+	mutex   sync.Mutex
+	wg      sync.WaitGroup
+	wakeup  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+//	'New' initialize worker with config, and migrate its table
+func New(config *Config) *Worker {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 4
+	}
+
+	if config.PollInterval == 0 {
+		config.PollInterval = 5 * time.Second
+	}
+
+	if config.LeaseTimeout == 0 {
+		config.LeaseTimeout = time.Minute
+	}
+
+	config.DB.AutoMigrate(&JobRecord{})
+	return &Worker{Config: config, running: map[uint]*JobContext{}, wakeup: make(chan struct{}, 1)}
+}
+
+//	'RegisterJob' register a job definition
+func (worker *Worker) RegisterJob(job *Job) {
+	if job.Backoff == 0 {
+		job.Backoff = 10 * time.Second
+	}
+	worker.Jobs = append(worker.Jobs, job)
+}
+
+//	'GetJob' get registered job by name
+func (worker *Worker) GetJob(name string) *Job {
+	for _, job := range worker.Jobs {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+//	'Enqueue' decode job's argument from meta values, and add it to the queue, the user, roles and locale of current context will be saved with it
+func (worker *Worker) Enqueue(name string, metaValues *resource.MetaValues, context *TM_EC.Context) (*JobRecord, error) {
+	job := worker.GetJob(name)
+	if job == nil {
+		return nil, ErrJobNotFound
+	}
+
+	record := &JobRecord{Name: name, Status: StatusQueued, Roles: strings.Join(context.Roles, ",")}
+	if job.Resource != nil {
+		argument := job.Resource.NewStruct()
+		if metaValues != nil {
+			if err := resource.DecodeToResource(job.Resource, argument, metaValues, context).Start(); err != nil {
+				return nil, err
+			}
+		}
+
+		content, err := json.Marshal(argument)
+		if err != nil {
+			return nil, err
+		}
+		record.Argument = string(content)
+	}
+
+	if user := context.CurrentUser; user != nil {
+		record.UserName = user.DisplayName()
+		record.UserKey = user.DisplayName()
+		if scope := (&gorm.Scope{Value: user}); scope.PrimaryField() != nil {
+			record.UserKey = fmt.Sprint(scope.PrimaryKeyValue())
+		}
+	}
+
+	record.Locale = utils.GetLocale(context)
+	if err := worker.Config.DB.Create(record).Error; err != nil {
+		return nil, err
+	}
+
+	worker.Wakeup()
+	return record, nil
+}
+
+//	'Cancel' cancel a job, queued job will be cancelled immediately, running job will be marked as cancelling, and its handler will be notified
+func (worker *Worker) Cancel(id uint) error {
+	db := worker.Config.DB
+	if db.Model(&JobRecord{}).Where("id = ? AND status = ?", id, StatusQueued).UpdateColumn("status", StatusCancelled).RowsAffected > 0 {
+		return nil
+	}
+
+	if err := db.Model(&JobRecord{}).Where("id = ? AND status = ?", id, StatusRunning).UpdateColumn("status", StatusCancelling).Error; err != nil {
+		return err
+	}
+
+	worker.mutex.Lock()
+	if job, ok := worker.running[id]; ok {
+		job.cancel()
+	}
+	worker.mutex.Unlock()
+	return nil
+}
+
+//	'Start' start polling the queue and run jobs
+func (worker *Worker) Start() {
+	worker.mutex.Lock()
+	defer worker.mutex.Unlock()
+
+	if worker.stop != nil {
+		return
+	}
+
+	worker.stop = make(chan struct{})
+	worker.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(worker.Config.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			worker.checkCancelling()
+			worker.recoverStale()
+			worker.dispatch()
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			case <-worker.wakeup:
+			}
+		}
+	}(worker.stop, worker.done)
+}
+
+//	'Stop' stop polling the queue, and wait running jobs finished
+func (worker *Worker) Stop() {
+	worker.mutex.Lock()
+	stop, done := worker.stop, worker.done
+	worker.stop, worker.done = nil, nil
+	worker.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	worker.wg.Wait()
+}
+
+//	'Wakeup' notify the worker to check the queue now
+func (worker *Worker) Wakeup() {
+	select {
+	case worker.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+func (worker *Worker) checkCancelling() {
+	worker.mutex.Lock()
+	var ids []uint
+	for id := range worker.running {
+		ids = append(ids, id)
+	}
+	worker.mutex.Unlock()
+
+	if len(ids) == 0 {
+		return
+	}
+
+	var records []JobRecord
+	worker.Config.DB.Select("id").Where("id IN (?) AND status = ?", ids, StatusCancelling).Find(&records)
+
+	worker.mutex.Lock()
+	for _, record := range records {
+		if job, ok := worker.running[record.ID]; ok {
+			job.cancel()
+		}
+	}
+	worker.mutex.Unlock()
+}
+
+func (worker *Worker) dispatch() {
+	worker.mutex.Lock()
+	free := worker.Config.Concurrency - len(worker.running)
+	worker.mutex.Unlock()
+
+	if free <= 0 || len(worker.Jobs) == 0 {
+		return
+	}
+
+	var (
+		names   []string
+		records []JobRecord
+		db      = worker.Config.DB
+		now     = time.Now()
+	)
+
+	for _, job := range worker.Jobs {
+		names = append(names, job.Name)
+	}
+
+	db.Where("status = ? AND name IN (?) AND (run_at IS NULL OR run_at <= ?)", StatusQueued, names, now).Order("id").Limit(free * 2).Find(&records)
+
+	for idx := range records {
+		record := &records[idx]
+		job := worker.GetJob(record.Name)
+
+		worker.mutex.Lock()
+		available := len(worker.running) < worker.Config.Concurrency && (job.Concurrency <= 0 || job.running < job.Concurrency)
+		worker.mutex.Unlock()
+		if !available {
+			continue
+		}
+
+		//	claim the job, it may be claimed by other workers
+		if db.Model(&JobRecord{}).Where("id = ? AND status = ?", record.ID, StatusQueued).UpdateColumns(map[string]interface{}{
+			"status":       StatusRunning,
+			"started_at":   now,
+			"heartbeat_at": now,
+			"attempts":     gorm.Expr("attempts + 1"),
+		}).RowsAffected == 0 {
+			continue
+		}
+
+		record.Status = StatusRunning
+		record.StartedAt = &now
+		record.HeartbeatAt = &now
+		record.Attempts++
+
+		jobContext := &JobContext{Record: record, Context: worker.newContext(record), worker: worker, cancelled: make(chan struct{})}
+		worker.mutex.Lock()
+		worker.running[record.ID] = jobContext
+		job.running++
+		worker.mutex.Unlock()
+
+		worker.wg.Add(1)
+		go worker.run(job, jobContext)
+	}
+}
+
+func (worker *Worker) newContext(record *JobRecord) *TM_EC.Context {
+	context := &TM_EC.Context{
+		Config: &TM_EC.Config{DB: worker.Config.DB},
+		Roles:  record.GetRoles(),
+		Locale: record.Locale,
+	}
+
+	if record.UserKey != "" && worker.Config.FindUser != nil {
+		context.CurrentUser = worker.Config.FindUser(record.UserKey, context)
+	}
+	return context
+}
+
+func (worker *Worker) run(job *Job, jobContext *JobContext) {
+	var (
+		err    error
+		record = jobContext.Record
+	)
+
+	defer worker.wg.Done()
+	defer func() {
+		worker.mutex.Lock()
+		delete(worker.running, record.ID)
+		job.running--
+		worker.mutex.Unlock()
+		worker.Wakeup()
+	}()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go worker.heartbeat(record, finished)
+
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("worker: job panicked: %v", r)
+			}
+		}()
+
+		var argument interface{}
+		if job.Resource != nil {
+			argument = job.Resource.NewStruct()
+			if err = json.Unmarshal([]byte(record.Argument), argument); err != nil {
+				return
+			}
+		}
+		err = job.Handler(argument, jobContext)
+	}()
+
+	var (
+		now     = time.Now()
+		updates = map[string]interface{}{"finished_at": now}
+	)
+
+	switch {
+	case err == nil:
+		updates["status"] = StatusSucceeded
+		updates["progress"] = 100
+		updates["error"] = ""
+	case err == ErrCancelled || jobContext.IsCancelled():
+		updates["status"] = StatusCancelled
+		updates["error"] = err.Error()
+	case record.Attempts <= job.MaxRetries:
+		backoff := job.Backoff
+		for i := 1; i < record.Attempts; i++ {
+			backoff *= 2
+		}
+		updates["status"] = StatusQueued
+		updates["run_at"] = now.Add(backoff)
+		updates["error"] = err.Error()
+		jobContext.AddLog("attempt %v failed: %v, retry in %v", record.Attempts, err, backoff)
+	default:
+		updates["status"] = StatusFailed
+		updates["error"] = err.Error()
+	}
+
+	//	attempts is changed if the job is recovered by other workers as its lease expired, don't overwrite it then
+	worker.leased(record).UpdateColumns(updates)
+}
+
+//	'leased' scope of the job record if it is still leased by current attempt
+func (worker *Worker) leased(record *JobRecord) *gorm.DB {
+	return worker.Config.DB.Model(&JobRecord{}).Where("id = ? AND attempts = ? AND status IN (?)", record.ID, record.Attempts, []string{StatusRunning, StatusCancelling})
+}
+
+func (worker *Worker) heartbeat(record *JobRecord, finished chan struct{}) {
+	ticker := time.NewTicker(worker.Config.LeaseTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-finished:
+			return
+		case now := <-ticker.C:
+			worker.leased(record).UpdateColumn("heartbeat_at", now)
+		}
+	}
+}
+
+//	'recoverStale' recover jobs whose lease expired, e.g. the worker running them crashed, they are retried if they have retries left, otherwise they are failed
+func (worker *Worker) recoverStale() {
+	var (
+		names   []string
+		records []JobRecord
+		now     = time.Now()
+	)
+
+	for _, job := range worker.Jobs {
+		names = append(names, job.Name)
+	}
+
+	if len(names) == 0 {
+		return
+	}
+
+	expired := now.Add(-worker.Config.LeaseTimeout)
+	worker.Config.DB.Where("status IN (?) AND name IN (?) AND (heartbeat_at < ? OR heartbeat_at IS NULL AND started_at < ?)", []string{StatusRunning, StatusCancelling}, names, expired, expired).Find(&records)
+
+	for idx := range records {
+		var (
+			record  = &records[idx]
+			job     = worker.GetJob(record.Name)
+			updates = map[string]interface{}{"finished_at": now, "error": ErrLeaseExpired.Error()}
+		)
+
+		switch {
+		case record.Status == StatusCancelling:
+			updates["status"] = StatusCancelled
+		case record.Attempts <= job.MaxRetries:
+			updates["status"] = StatusQueued
+			updates["run_at"] = now
+			updates["finished_at"] = nil
+		default:
+			updates["status"] = StatusFailed
+		}
+
+		worker.leased(record).UpdateColumns(updates)
+	}
+}
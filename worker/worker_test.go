@@ -0,0 +1,195 @@
+package worker_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Sky-And-Hammer/TM_EC"
+	"github.com/Sky-And-Hammer/TM_EC/test/utils"
+	"github.com/Sky-And-Hammer/TM_EC/worker"
+)
+
+func newWorker(config *worker.Config) *worker.Worker {
+	if config.DB == nil {
+		config.DB = utils.TestDB()
+		config.DB.DropTableIfExists(&worker.JobRecord{})
+	}
+	config.PollInterval = 10 * time.Millisecond
+	return worker.New(config)
+}
+
+func waitFor(t *testing.T, w *worker.Worker, id uint, statuses ...string) worker.JobRecord {
+	var record worker.JobRecord
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		w.Config.DB.First(&record, id)
+		for _, status := range statuses {
+			if record.Status == status {
+				return record
+			}
+		}
+	}
+	t.Fatalf("job %v should be %v, but got %+v", id, statuses, record)
+	return record
+}
+
+func TestRunJob(t *testing.T) {
+	var (
+		w       = newWorker(&worker.Config{})
+		calls   int32
+		locales = make(chan string, 2)
+	)
+
+	w.RegisterJob(&worker.Job{
+		Name:       "export",
+		MaxRetries: 1,
+		Backoff:    time.Millisecond,
+		Handler: func(argument interface{}, job *worker.JobContext) error {
+			locales <- job.Context.Locale
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return errors.New("temporary error")
+			}
+			return job.SetProgress(50)
+		},
+	})
+	w.Start()
+	defer w.Stop()
+
+	record, err := w.Enqueue("export", nil, &TM_EC.Context{Locale: "zh-CN", Roles: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Locale != "zh-CN" {
+		t.Errorf("locale of context should be saved, but got %q", record.Locale)
+	}
+
+	result := waitFor(t, w, record.ID, worker.StatusSucceeded, worker.StatusFailed)
+	if result.Status != worker.StatusSucceeded || result.Attempts != 2 || result.Progress != 100 || result.Log == "" {
+		t.Errorf("job should succeed after retry, but got %+v", result)
+	}
+
+	for i := 0; i < 2; i++ {
+		if locale := <-locales; locale != "zh-CN" {
+			t.Errorf("job context should have locale zh-CN, but got %q", locale)
+		}
+	}
+
+	if _, err := w.Enqueue("unknown", nil, &TM_EC.Context{}); err != worker.ErrJobNotFound {
+		t.Errorf("unknown job should not be enqueued, but got %v", err)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	var (
+		w       = newWorker(&worker.Config{})
+		started = make(chan struct{})
+	)
+
+	w.RegisterJob(&worker.Job{
+		Name: "import",
+		Handler: func(argument interface{}, job *worker.JobContext) error {
+			close(started)
+			<-job.Cancelled()
+			return worker.ErrCancelled
+		},
+	})
+	w.Start()
+	defer w.Stop()
+
+	record, _ := w.Enqueue("import", nil, &TM_EC.Context{})
+	<-started
+	if err := w.Cancel(record.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := waitFor(t, w, record.ID, worker.StatusCancelled, worker.StatusFailed, worker.StatusSucceeded); result.Status != worker.StatusCancelled {
+		t.Errorf("job should be cancelled, but got %+v", result)
+	}
+}
+
+func TestRecoverStaleJobs(t *testing.T) {
+	var (
+		w     = newWorker(&worker.Config{LeaseTimeout: time.Second})
+		stale = time.Now().Add(-time.Minute)
+		runs  = map[string]*int32{"retry": new(int32), "fail": new(int32), "cancel": new(int32)}
+	)
+
+	for name, maxRetries := range map[string]int{"retry": 1, "fail": 0, "cancel": 1} {
+		count := runs[name]
+		w.RegisterJob(&worker.Job{Name: name, MaxRetries: maxRetries, Handler: func(argument interface{}, job *worker.JobContext) error {
+			atomic.AddInt32(count, 1)
+			return nil
+		}})
+	}
+
+	records := map[string]*worker.JobRecord{
+		"retry":  {Name: "retry", Status: worker.StatusRunning, Attempts: 1, StartedAt: &stale, HeartbeatAt: &stale},
+		"fail":   {Name: "fail", Status: worker.StatusRunning, Attempts: 1, StartedAt: &stale},
+		"cancel": {Name: "cancel", Status: worker.StatusCancelling, Attempts: 1, StartedAt: &stale, HeartbeatAt: &stale},
+	}
+	for _, record := range records {
+		w.Config.DB.Create(record)
+	}
+
+	fresh := time.Now()
+	running := worker.JobRecord{Name: "retry", Status: worker.StatusRunning, Attempts: 1, StartedAt: &stale, HeartbeatAt: &fresh}
+	w.Config.DB.Create(&running)
+
+	w.Start()
+	defer w.Stop()
+
+	expects := map[string]string{"retry": worker.StatusSucceeded, "fail": worker.StatusFailed, "cancel": worker.StatusCancelled}
+	for name, status := range expects {
+		result := waitFor(t, w, records[name].ID, worker.StatusSucceeded, worker.StatusFailed, worker.StatusCancelled)
+		if result.Status != status {
+			t.Errorf("stale job %v should be %v, but got %+v", name, status, result)
+		}
+
+		if status != worker.StatusSucceeded && result.Error != worker.ErrLeaseExpired.Error() {
+			t.Errorf("stale job %v should be recovered with lease expired error, but got %q", name, result.Error)
+		}
+	}
+
+	if *runs["retry"] != 1 || *runs["fail"] != 0 || *runs["cancel"] != 0 {
+		t.Errorf("only stale job with retries left should be run again, but got retry %v, fail %v, cancel %v", *runs["retry"], *runs["fail"], *runs["cancel"])
+	}
+
+	w.Config.DB.First(&running, running.ID)
+	if running.Status != worker.StatusRunning || running.Attempts != 1 {
+		t.Errorf("job with fresh heartbeat should not be recovered, but got %+v", running)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	var (
+		runs    int32
+		workers []*worker.Worker
+		config  = &worker.Config{LeaseTimeout: 150 * time.Millisecond}
+	)
+
+	for i := 0; i < 2; i++ {
+		w := newWorker(&worker.Config{DB: config.DB, LeaseTimeout: config.LeaseTimeout})
+		config.DB = w.Config.DB
+		w.RegisterJob(&worker.Job{Name: "sync", Handler: func(argument interface{}, job *worker.JobContext) error {
+			atomic.AddInt32(&runs, 1)
+			time.Sleep(600 * time.Millisecond)
+			return nil
+		}})
+		workers = append(workers, w)
+	}
+
+	record, _ := workers[0].Enqueue("sync", nil, &TM_EC.Context{})
+	workers[0].Start()
+	defer workers[0].Stop()
+
+	waitFor(t, workers[0], record.ID, worker.StatusRunning)
+	workers[1].Start()
+	defer workers[1].Stop()
+
+	result := waitFor(t, workers[1], record.ID, worker.StatusSucceeded, worker.StatusFailed, worker.StatusQueued)
+	if result.Status != worker.StatusSucceeded || result.Attempts != 1 || atomic.LoadInt32(&runs) != 1 {
+		t.Errorf("job with heartbeats should not be recovered by other workers, but got %+v, runs %v", result, runs)
+	}
+}